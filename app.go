@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wangyuheng/richman/internal/task"
+	"github.com/wangyuheng/richman/internal/usecase"
+)
+
+// App 命令行使用的 use case 集合，由 wire 构建
+type App struct {
+	BillUseCase   usecase.BillUseCase
+	LedgerUseCase usecase.LedgerUseCase
+	UserUseCase   usecase.UserUseCase
+	ExportUseCase usecase.ExportUseCase
+	ImportUseCase usecase.ImportUseCase
+}
+
+// Server HTTP 服务及后台任务，由同一个 wire 依赖图构建，共享仓储缓存及消息推送的 access_token
+type Server struct {
+	Engine *gin.Engine
+	Tasker task.Tasker
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const cliDateLayout = "2006-01-02"
+
+const usage = `richman 记账机器人
+
+用法:
+  richman [serve]                启动服务
+  richman bill add -uid UID [-category 分类] [-date yyyy-mm-dd] 名称 金额
+                                 记账，金额以 + 开头为收入
+  richman bill list -uid UID [-start yyyy-mm-dd] [-end yyyy-mm-dd] [-expenses 收入|支出] [-category 分类] [-json]
+                                 查询账单
+  richman ledger prepare         预生成待分配的账本
+  richman ledger assign -uid UID 为用户分配账本
+  richman user show -uid UID     查看用户及账本
+  richman export -uid UID [-start yyyy-mm-dd] [-end yyyy-mm-dd] [-format csv|xlsx|json|beancount|ledger|hledger] [-o 文件]
+                                 导出账单，未指定时间范围时导出全部
+  richman import -uid UID [-dry-run] 文件
+                                 导入支付宝、微信支付账单 CSV，按交易单号排重
+
+存储、飞书等配置与服务相同，通过环境变量设置。
+`
+
+type command func(app *App, args []string) error
+
+var commands = map[string]map[string]command{
+	"bill": {
+		"add":  billAdd,
+		"list": billList,
+	},
+	"ledger": {
+		"prepare": ledgerPrepare,
+		"assign":  ledgerAssign,
+	},
+	"user": {
+		"show": userShow,
+	},
+	"export": {
+		"": exportBills,
+	},
+	"import": {
+		"": importBills,
+	},
+}
+
+// run 解析子命令，未指定时启动服务
+func run(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] == "serve" {
+		return serve(cfg)
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Print(usage)
+		return nil
+	}
+	subs, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+	cmd, rest := subs[""], args[1:]
+	if cmd == nil {
+		if len(args) < 2 || subs[args[1]] == nil {
+			return fmt.Errorf("unknown command %q\n\n%s", strings.Join(args, " "), usage)
+		}
+		cmd, rest = subs[args[1]], args[2:]
+	}
+	app, err := initializeApp(cfg)
+	if err != nil {
+		return err
+	}
+	return cmd(app, rest)
+}
+
+func billAdd(app *App, args []string) error {
+	fs := flag.NewFlagSet("bill add", flag.ContinueOnError)
+	uid := fs.String("uid", "", "用户ID")
+	category := fs.String("category", "其他", "分类")
+	date := fs.String("date", "", "账单日期 yyyy-mm-dd，默认今天")
+	currency := fs.String("currency", "", "币种，如 USD，默认账本本位币")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: richman bill add -uid UID [-category 分类] [-date yyyy-mm-dd] [-currency USD] 名称 金额")
+	}
+	operator, err := requireUser(app, *uid)
+	if err != nil {
+		return err
+	}
+	amount := common.ParseAmount(fs.Arg(1))
+	if amount == 0 {
+		return errors.New(common.AmountIllegal)
+	}
+	bill := &domain.Bill{
+		Remark:     fs.Arg(0),
+		Categories: []string{*category},
+		Amount:     amount,
+		Expenses:   string(common.ConfirmExpenses(fs.Arg(1))),
+		AuthorID:   operator.UID,
+		AuthorName: operator.Name,
+	}
+	if *date != "" {
+		d, err := time.ParseInLocation(cliDateLayout, *date, time.Local)
+		if err != nil {
+			return err
+		}
+		bill.Date = d.UnixNano() / 1e6
+	}
+	ledger, err := ledgerOf(app, operator)
+	if err != nil {
+		return err
+	}
+	bill.Currency = ledger.BillCurrency("")
+	if *currency != "" {
+		code, ok := domain.ParseCurrency(*currency)
+		if !ok {
+			return errors.New(common.CurrencyIllegal)
+		}
+		bill.Currency = ledger.BillCurrency(code)
+	}
+	if err = app.BillUseCase.Save(ledger.AppToken, ledger.TableToken, bill); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", bill.ID, common.BillDesc(bill.Remark, bill.Categories, bill.Amount, bill.Currency, bill.Expenses))
+	return nil
+}
+
+func billList(app *App, args []string) error {
+	fs := flag.NewFlagSet("bill list", flag.ContinueOnError)
+	uid := fs.String("uid", "", "用户ID")
+	start := fs.String("start", "", "开始日期 yyyy-mm-dd")
+	end := fs.String("end", "", "结束日期 yyyy-mm-dd")
+	expenses := fs.String("expenses", "", "收入或支出")
+	category := fs.String("category", "", "分类")
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter, err := parseFilter(*start, *end)
+	if err != nil {
+		return err
+	}
+	filter.Expenses = common.Expenses(*expenses)
+	filter.Category = *category
+	bills, err := listBills(app, *uid, filter)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, bills)
+	}
+	return printBills(bills)
+}
+
+func ledgerPrepare(app *App, args []string) error {
+	ledgers := app.LedgerUseCase.PreparedAllocated()
+	fmt.Printf("待分配账本 %d 个\n", len(ledgers))
+	return printLedgers(ledgers)
+}
+
+func ledgerAssign(app *App, args []string) error {
+	fs := flag.NewFlagSet("ledger assign", flag.ContinueOnError)
+	uid := fs.String("uid", "", "用户ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	operator, err := requireUser(app, *uid)
+	if err != nil {
+		return err
+	}
+	ledger, err := ledgerOf(app, operator)
+	if err != nil {
+		return err
+	}
+	return printLedgers([]*domain.Ledger{ledger})
+}
+
+func userShow(app *App, args []string) error {
+	fs := flag.NewFlagSet("user show", flag.ContinueOnError)
+	uid := fs.String("uid", "", "用户ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	operator, err := requireUser(app, *uid)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("UID: %s\n称呼: %s\n", operator.UID, operator.Name)
+	if ledger, exists := app.LedgerUseCase.Active(operator.UID); exists {
+		return printLedgers([]*domain.Ledger{ledger})
+	}
+	fmt.Println("尚未分配账本")
+	return nil
+}
+
+func exportBills(app *App, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	uid := fs.String("uid", "", "用户ID")
+	start := fs.String("start", "", "开始日期 yyyy-mm-dd")
+	end := fs.String("end", "", "结束日期 yyyy-mm-dd")
+	format := fs.String("format", "json", "导出格式 "+strings.Join(app.ExportUseCase.Formats(), "|"))
+	output := fs.String("o", "", "输出文件，默认标准输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, ok := app.ExportUseCase.ContentType(*format); !ok {
+		return fmt.Errorf("unsupported format %s", *format)
+	}
+	filter, err := parseFilter(*start, *end)
+	if err != nil {
+		return err
+	}
+	operator, err := requireUser(app, *uid)
+	if err != nil {
+		return err
+	}
+	ledger, exists := app.LedgerUseCase.Active(operator.UID)
+	if !exists {
+		return fmt.Errorf("user %s has no ledger", operator.UID)
+	}
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return app.ExportUseCase.Export(context.Background(), w, ledger, filter, *format)
+}
+
+func importBills(app *App, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	uid := fs.String("uid", "", "用户ID")
+	dryRun := fs.Bool("dry-run", false, "只预览，不保存")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: richman import -uid UID [-dry-run] 文件")
+	}
+	operator, err := requireUser(app, *uid)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ledger, err := ledgerOf(app, operator)
+	if err != nil {
+		return err
+	}
+	res, err := app.ImportUseCase.Import(context.Background(), ledger, *operator, f, *dryRun)
+	if err != nil {
+		return err
+	}
+	if len(res.Bills) > 0 {
+		if err = printBills(res.Bills); err != nil {
+			return err
+		}
+	}
+	action := "导入"
+	if res.DryRun {
+		action = "待导入"
+	}
+	fmt.Printf("来源: %s，%s %d 笔，重复 %d 笔，忽略 %d 笔\n", res.Source, action, len(res.Bills), res.Duplicated, res.Skipped)
+	return nil
+}
+
+func listBills(app *App, uid string, filter usecase.BillFilter) ([]*domain.Bill, error) {
+	operator, err := requireUser(app, uid)
+	if err != nil {
+		return nil, err
+	}
+	ledger, exists := app.LedgerUseCase.Active(operator.UID)
+	if !exists {
+		return nil, fmt.Errorf("user %s has no ledger", operator.UID)
+	}
+	return app.BillUseCase.List(context.Background(), ledger, filter), nil
+}
+
+func requireUser(app *App, uid string) (*domain.User, error) {
+	if uid == "" {
+		return nil, fmt.Errorf("-uid is required")
+	}
+	operator, exists := app.UserUseCase.GetByID(uid)
+	if !exists {
+		return nil, fmt.Errorf("user %s not found", uid)
+	}
+	return operator, nil
+}
+
+// ledgerOf 查询用户的账本，不存在时分配
+func ledgerOf(app *App, operator *domain.User) (*domain.Ledger, error) {
+	if ledger, exists := app.LedgerUseCase.Active(operator.UID); exists {
+		return ledger, nil
+	}
+	return app.LedgerUseCase.Allocated(*operator)
+}
+
+func parseFilter(start, end string) (usecase.BillFilter, error) {
+	var filter usecase.BillFilter
+	if start != "" {
+		d, err := time.ParseInLocation(cliDateLayout, start, time.Local)
+		if err != nil {
+			return filter, err
+		}
+		filter.StartDate = d
+	}
+	if end != "" {
+		d, err := time.ParseInLocation(cliDateLayout, end, time.Local)
+		if err != nil {
+			return filter, err
+		}
+		filter.EndDate = d
+	}
+	return filter, nil
+}
+
+func printBills(bills []*domain.Bill) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t日期\t收支\t名称\t分类\t金额")
+	for _, b := range bills {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", b.ID, time.Unix(0, b.Date*1e6).Format(cliDateLayout), b.Expenses,
+			b.Remark, strings.Join(b.Categories, ","), common.Money(b.Amount, b.Currency))
+	}
+	return w.Flush()
+}
+
+func printLedgers(ledgers []*domain.Ledger) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t名称\tAppToken\t创建人\tURL")
+	for _, l := range ledgers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.ID, l.Name, l.AppToken, l.CreatorName, l.URL)
+	}
+	return w.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
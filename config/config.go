@@ -3,37 +3,181 @@ package config
 import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var cfg = &Config{}
 
 const (
-	LogLevel             = "LOG_LEVEL"
-	AiURL                = "AI_URL"
-	AiKey                = "AI_KEY"
-	LarkAppId            = "LARK_APP_ID"
-	LarkAppSecret        = "LARK_APP_SECRET"
-	WechatToken          = "WECHAT_TOKEN"
-	TemplateAppToken     = "TEMPLATE_APP_TOKEN"
-	TargetFolderAppToken = "TARGET_FOLDER_APP_TOKEN"
-	DBAppToken           = "DB_APP_TOKEN"
-	DBTableToken         = "DB_TABLE_TOKEN"
-	AuditLogDBToken      = "AUDIT_LOG_DB_TOKEN"
-	AuditLogTableToken   = "AUDIT_LOG_TABLE_TOKEN"
+	LogLevel               = "LOG_LEVEL"
+	AiURL                  = "AI_URL"
+	AiKey                  = "AI_KEY"
+	AiProvider             = "AI_PROVIDER"
+	AiModel                = "AI_MODEL"
+	AiAPIVersion           = "AI_API_VERSION"
+	AiTimeout              = "AI_TIMEOUT"
+	AiRuleFirst            = "AI_RULE_FIRST"
+	LarkAppId              = "LARK_APP_ID"
+	LarkAppSecret          = "LARK_APP_SECRET"
+	LarkVerificationToken  = "LARK_VERIFICATION_TOKEN"
+	LarkEncryptKey         = "LARK_ENCRYPT_KEY"
+	LarkWorkers            = "LARK_WORKERS"
+	WechatToken            = "WECHAT_TOKEN"
+	WechatAppID            = "WECHAT_APP_ID"
+	WechatAppSecret        = "WECHAT_APP_SECRET"
+	WechatEncodingAESKey   = "WECHAT_ENCODING_AES_KEY"
+	WechatAPIURL           = "WECHAT_API_URL"
+	WechatAsync            = "WECHAT_ASYNC"
+	WechatWorkers          = "WECHAT_WORKERS"
+	WechatMock             = "WECHAT_MOCK"
+	TemplateAppToken       = "TEMPLATE_APP_TOKEN"
+	TargetFolderAppToken   = "TARGET_FOLDER_APP_TOKEN"
+	DBAppToken             = "DB_APP_TOKEN"
+	DBTableToken           = "DB_TABLE_TOKEN"
+	MemberTableToken       = "MEMBER_TABLE_TOKEN"
+	InviteTableToken       = "INVITE_TABLE_TOKEN"
+	AuditLogDBToken        = "AUDIT_LOG_DB_TOKEN"
+	AuditLogTableToken     = "AUDIT_LOG_TABLE_TOKEN"
+	RecurringDBToken       = "RECURRING_DB_TOKEN"
+	RecurringTableToken    = "RECURRING_TABLE_TOKEN"
+	SubscriptionDBToken    = "SUBSCRIPTION_DB_TOKEN"
+	SubscriptionTableToken = "SUBSCRIPTION_TABLE_TOKEN"
+	ReportDefaultHour      = "REPORT_DEFAULT_HOUR"
+	ReminderTableToken     = "REMINDER_TABLE_TOKEN"
+	ReminderInactiveDays   = "REMINDER_INACTIVE_DAYS"
+	ReminderInterval       = "REMINDER_INTERVAL"
+	ReminderQuietHours     = "REMINDER_QUIET_HOURS"
+	ReminderLookback       = "REMINDER_LOOKBACK"
+	DBDriver               = "DB_DRIVER"
+	SqlitePath             = "SQLITE_PATH"
+	APISecret              = "API_SECRET"
+	APITokenTTL            = "API_TOKEN_TTL"
+	ServerURL              = "SERVER_URL"
+	JournalAccountsPath    = "JOURNAL_ACCOUNTS_PATH"
+	JournalAssetAccount    = "JOURNAL_ASSET_ACCOUNT"
+	JournalCurrency        = "JOURNAL_CURRENCY"
+	BudgetAlertThresholds  = "BUDGET_ALERT_THRESHOLDS"
+	RateProvider           = "RATE_PROVIDER"
+	RateFile               = "RATE_FILE"
+	RateAPIURL             = "RATE_API_URL"
+	RateTimeout            = "RATE_TIMEOUT"
+)
+
+const (
+	DriverBitable = "bitable"
+	DriverSqlite  = "sqlite"
 )
 
 type Config struct {
 	LogLevel logrus.Level
 	LarkConfig
 	AIConfig
+	WechatConfig
 	LarkDBConfig
 	AuditLogDBToken    string
 	AuditLogTableToken string
+	// RecurringDBToken 周期账单规则所在的多维表格，sqlite 存储时无需配置
+	RecurringDBToken    string
+	RecurringTableToken string
+	ReportConfig
+	ReminderConfig
+	StorageConfig
+	APIConfig
+	JournalConfig
+	BudgetConfig
+	RateConfig
+}
+
+type APIConfig struct {
+	// APISecret 开放接口令牌的签名密钥，为空时不开放接口
+	APISecret string
+	// APITokenTTL 开放接口令牌的有效期
+	APITokenTTL time.Duration
+	// ServerURL 服务公网地址，用于生成下载链接
+	ServerURL string
+}
+
+// JournalConfig 导出 Beancount、ledger-cli 等复式记账格式的科目配置
+type JournalConfig struct {
+	// JournalAccountsPath 分类与科目的映射文件，每行为 分类 = 科目
+	JournalAccountsPath string
+	// JournalAssetAccount 收支的对方科目，如 Assets:Cash
+	JournalAssetAccount string
+	JournalCurrency     string
+}
+
+type BudgetConfig struct {
+	// BudgetAlertThresholds 预算使用达到的百分比时提醒，如 80,100
+	BudgetAlertThresholds []int
+}
+
+// RateConfig 外币账单换算为本位币的汇率来源
+type RateConfig struct {
+	// RateProvider none、file、online，none 时不支持外币记账
+	RateProvider string
+	// RateFile 离线汇率文件，file 时使用
+	RateFile string
+	// RateAPIURL 在线汇率接口，支持 {date}、{from}、{to} 占位符
+	RateAPIURL  string
+	RateTimeout time.Duration
+}
+
+type ReportConfig struct {
+	// SubscriptionDBToken 报告订阅所在的多维表格，sqlite 存储时无需配置
+	SubscriptionDBToken    string
+	SubscriptionTableToken string
+	// ReportDefaultHour 订阅时未指定时间的默认推送时间，0 到 23 点
+	ReportDefaultHour int
+}
+
+type ReminderConfig struct {
+	// ReminderTableToken 记账提醒设置所在的数据表，与订阅使用同一个多维表格
+	ReminderTableToken string
+	// ReminderInactiveDays 超过多少天没有记账时提醒
+	ReminderInactiveDays int
+	// ReminderInterval 两次提醒的最小间隔
+	ReminderInterval time.Duration
+	// ReminderLookback 查询最近账单的时间范围，范围内没有账单的用户每个范围内只提醒一次
+	ReminderLookback time.Duration
+	// ReminderQuietStart ReminderQuietEnd 免打扰时段，如 22 点至次日 8 点，相等时不限制
+	ReminderQuietStart int
+	ReminderQuietEnd   int
+}
+
+type StorageConfig struct {
+	// DBDriver 存储方式，bitable 或 sqlite
+	DBDriver   string
+	SqlitePath string
 }
 
 type AIConfig struct {
+	// AiURL 服务地址，openai-functions 为完整的接口地址，其他 provider 为 base URL
 	AiURL string
 	AiKey string
+	// AiProvider openai-functions, openai, azure, local
+	AiProvider string
+	// AiModel 模型名称，azure 为 deployment 名称
+	AiModel      string
+	AiAPIVersion string
+	AiTimeout    time.Duration
+	// AiRuleFirst 优先使用规则解析，无法识别时再调用 AI
+	AiRuleFirst bool
+}
+
+type WechatConfig struct {
+	WechatAppID     string
+	WechatAppSecret string
+	// WechatEncodingAESKey 安全模式及兼容模式的消息加解密密钥
+	WechatEncodingAESKey string
+	// WechatAPIURL 公众号接口地址，测试时可指向 /devbox/wx 模拟接口
+	WechatAPIURL string
+	// WechatAsync 立即应答微信回调，处理结果通过客服消息接口推送
+	WechatAsync   bool
+	WechatWorkers int
+	// WechatMock 注册 /devbox/wx 模拟接口，仅用于本地测试，接口没有鉴权
+	WechatMock bool
 }
 
 type LarkConfig struct {
@@ -42,11 +186,20 @@ type LarkConfig struct {
 	WechatToken          string
 	TemplateAppToken     string
 	TargetFolderAppToken string
+	// LarkVerificationToken 机器人事件订阅的 Verification Token
+	LarkVerificationToken string
+	// LarkEncryptKey 事件订阅的 Encrypt Key，配置后事件体加密推送
+	LarkEncryptKey string
+	// LarkWorkers 处理机器人消息的并发数
+	LarkWorkers int
 }
 
 type LarkDBConfig struct {
-	DBAppToken           string
-	DBTableToken         string
+	DBAppToken   string
+	DBTableToken string
+	// MemberTableToken InviteTableToken 账本成员及邀请码所在的数据表，与账本使用同一个多维表格
+	MemberTableToken     string
+	InviteTableToken     string
 	TemplateAppToken     string
 	TargetFolderAppToken string
 }
@@ -55,30 +208,133 @@ func Load() *Config {
 	v := viper.New()
 	v.AutomaticEnv()
 	v.SetDefault(LogLevel, logrus.InfoLevel.String())
+	v.SetDefault(DBDriver, DriverBitable)
+	v.SetDefault(AiProvider, "openai-functions")
+	v.SetDefault(AiTimeout, "30s")
+	v.SetDefault(APITokenTTL, "720h")
+	v.SetDefault(WechatAPIURL, "https://api.weixin.qq.com")
+	v.SetDefault(WechatWorkers, 8)
+	v.SetDefault(LarkWorkers, 8)
+	v.SetDefault(SqlitePath, "richman.db")
+	v.SetDefault(JournalAssetAccount, "Assets:Cash")
+	v.SetDefault(JournalCurrency, "CNY")
+	v.SetDefault(BudgetAlertThresholds, "80,100")
+	v.SetDefault(RateProvider, "none")
+	v.SetDefault(RateAPIURL, "https://api.frankfurter.app/{date}?from={from}&to={to}")
+	v.SetDefault(RateTimeout, "10s")
+	v.SetDefault(ReportDefaultHour, 21)
+	v.SetDefault(ReminderInactiveDays, 3)
+	v.SetDefault(ReminderInterval, "72h")
+	v.SetDefault(ReminderQuietHours, "22-8")
+	v.SetDefault(ReminderLookback, "720h")
 
 	_ = v.BindEnv(AiURL)
 	_ = v.BindEnv(AiKey)
+	_ = v.BindEnv(AiProvider)
+	_ = v.BindEnv(AiModel)
+	_ = v.BindEnv(AiAPIVersion)
+	_ = v.BindEnv(AiTimeout)
+	_ = v.BindEnv(AiRuleFirst)
 	_ = v.BindEnv(LarkAppId)
 	_ = v.BindEnv(LarkAppSecret)
+	_ = v.BindEnv(LarkVerificationToken)
+	_ = v.BindEnv(LarkEncryptKey)
+	_ = v.BindEnv(LarkWorkers)
 	_ = v.BindEnv(WechatToken)
+	_ = v.BindEnv(WechatAppID)
+	_ = v.BindEnv(WechatAppSecret)
+	_ = v.BindEnv(WechatEncodingAESKey)
+	_ = v.BindEnv(WechatAPIURL)
+	_ = v.BindEnv(WechatAsync)
+	_ = v.BindEnv(WechatWorkers)
+	_ = v.BindEnv(WechatMock)
 	_ = v.BindEnv(TemplateAppToken)
 	_ = v.BindEnv(TargetFolderAppToken)
 	_ = v.BindEnv(DBAppToken)
 	_ = v.BindEnv(DBTableToken)
+	_ = v.BindEnv(MemberTableToken)
+	_ = v.BindEnv(InviteTableToken)
 	_ = v.BindEnv(AuditLogDBToken)
 	_ = v.BindEnv(AuditLogTableToken)
+	_ = v.BindEnv(RecurringDBToken)
+	_ = v.BindEnv(RecurringTableToken)
+	_ = v.BindEnv(SubscriptionDBToken)
+	_ = v.BindEnv(SubscriptionTableToken)
+	_ = v.BindEnv(ReportDefaultHour)
+	_ = v.BindEnv(ReminderTableToken)
+	_ = v.BindEnv(ReminderInactiveDays)
+	_ = v.BindEnv(ReminderInterval)
+	_ = v.BindEnv(ReminderQuietHours)
+	_ = v.BindEnv(ReminderLookback)
+	_ = v.BindEnv(DBDriver)
+	_ = v.BindEnv(SqlitePath)
+	_ = v.BindEnv(APISecret)
+	_ = v.BindEnv(APITokenTTL)
+	_ = v.BindEnv(ServerURL)
+	_ = v.BindEnv(JournalAccountsPath)
+	_ = v.BindEnv(JournalAssetAccount)
+	_ = v.BindEnv(JournalCurrency)
+	_ = v.BindEnv(BudgetAlertThresholds)
+	_ = v.BindEnv(RateProvider)
+	_ = v.BindEnv(RateFile)
+	_ = v.BindEnv(RateAPIURL)
+	_ = v.BindEnv(RateTimeout)
 
 	cfg.AuditLogDBToken = v.GetString(AuditLogDBToken)
 	cfg.AuditLogTableToken = v.GetString(AuditLogTableToken)
+	cfg.RecurringDBToken = v.GetString(RecurringDBToken)
+	cfg.RecurringTableToken = v.GetString(RecurringTableToken)
+	cfg.ReportConfig.SubscriptionDBToken = v.GetString(SubscriptionDBToken)
+	cfg.ReportConfig.SubscriptionTableToken = v.GetString(SubscriptionTableToken)
+	cfg.ReportConfig.ReportDefaultHour = v.GetInt(ReportDefaultHour)
+	cfg.ReminderConfig.ReminderTableToken = v.GetString(ReminderTableToken)
+	cfg.ReminderConfig.ReminderInactiveDays = v.GetInt(ReminderInactiveDays)
+	cfg.ReminderConfig.ReminderInterval = v.GetDuration(ReminderInterval)
+	cfg.ReminderConfig.ReminderLookback = v.GetDuration(ReminderLookback)
+	// 免打扰时段格式为 开始-结束，如 22-8
+	if quiet := parseInts(strings.Replace(v.GetString(ReminderQuietHours), "-", ",", 1)); len(quiet) == 2 {
+		cfg.ReminderConfig.ReminderQuietStart = quiet[0]
+		cfg.ReminderConfig.ReminderQuietEnd = quiet[1]
+	}
+	cfg.StorageConfig.DBDriver = v.GetString(DBDriver)
+	cfg.StorageConfig.SqlitePath = v.GetString(SqlitePath)
+	cfg.APIConfig.APISecret = v.GetString(APISecret)
+	cfg.APIConfig.APITokenTTL = v.GetDuration(APITokenTTL)
+	cfg.APIConfig.ServerURL = v.GetString(ServerURL)
+	cfg.JournalConfig.JournalAccountsPath = v.GetString(JournalAccountsPath)
+	cfg.JournalConfig.JournalAssetAccount = v.GetString(JournalAssetAccount)
+	cfg.JournalConfig.JournalCurrency = v.GetString(JournalCurrency)
+	cfg.BudgetConfig.BudgetAlertThresholds = parseInts(v.GetString(BudgetAlertThresholds))
+	cfg.RateConfig.RateProvider = v.GetString(RateProvider)
+	cfg.RateConfig.RateFile = v.GetString(RateFile)
+	cfg.RateConfig.RateAPIURL = v.GetString(RateAPIURL)
+	cfg.RateConfig.RateTimeout = v.GetDuration(RateTimeout)
 	cfg.AIConfig.AiURL = v.GetString(AiURL)
 	cfg.AIConfig.AiKey = v.GetString(AiKey)
+	cfg.AIConfig.AiProvider = v.GetString(AiProvider)
+	cfg.AIConfig.AiModel = v.GetString(AiModel)
+	cfg.AIConfig.AiAPIVersion = v.GetString(AiAPIVersion)
+	cfg.AIConfig.AiTimeout = v.GetDuration(AiTimeout)
+	cfg.AIConfig.AiRuleFirst = v.GetBool(AiRuleFirst)
 	cfg.LarkConfig.DbAppId = v.GetString(LarkAppId)
 	cfg.LarkConfig.DbAppSecret = v.GetString(LarkAppSecret)
+	cfg.LarkConfig.LarkVerificationToken = v.GetString(LarkVerificationToken)
+	cfg.LarkConfig.LarkEncryptKey = v.GetString(LarkEncryptKey)
+	cfg.LarkConfig.LarkWorkers = v.GetInt(LarkWorkers)
 	cfg.LarkConfig.WechatToken = v.GetString(WechatToken)
+	cfg.WechatConfig.WechatAppID = v.GetString(WechatAppID)
+	cfg.WechatConfig.WechatAppSecret = v.GetString(WechatAppSecret)
+	cfg.WechatConfig.WechatEncodingAESKey = v.GetString(WechatEncodingAESKey)
+	cfg.WechatConfig.WechatAPIURL = v.GetString(WechatAPIURL)
+	cfg.WechatConfig.WechatAsync = v.GetBool(WechatAsync)
+	cfg.WechatConfig.WechatWorkers = v.GetInt(WechatWorkers)
+	cfg.WechatConfig.WechatMock = v.GetBool(WechatMock)
 	cfg.LarkConfig.TemplateAppToken = v.GetString(TemplateAppToken)
 	cfg.LarkConfig.TargetFolderAppToken = v.GetString(TargetFolderAppToken)
 	cfg.LarkDBConfig.DBAppToken = v.GetString(DBAppToken)
 	cfg.LarkDBConfig.DBTableToken = v.GetString(DBTableToken)
+	cfg.LarkDBConfig.MemberTableToken = v.GetString(MemberTableToken)
+	cfg.LarkDBConfig.InviteTableToken = v.GetString(InviteTableToken)
 	cfg.LarkDBConfig.TemplateAppToken = v.GetString(TemplateAppToken)
 	cfg.LarkDBConfig.TargetFolderAppToken = v.GetString(TargetFolderAppToken)
 	if l, err := logrus.ParseLevel(v.GetString(LogLevel)); err == nil {
@@ -88,6 +344,17 @@ func Load() *Config {
 	return cfg
 }
 
+// parseInts 解析逗号分隔的整数，忽略非法值
+func parseInts(v string) []int {
+	res := make([]int, 0)
+	for _, it := range strings.Split(v, ",") {
+		if i, err := strconv.Atoi(strings.TrimSpace(it)); err == nil {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
 func GetConfig() *Config {
 	return cfg
 }
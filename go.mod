@@ -13,7 +13,9 @@ require (
 	github.com/hashicorp/golang-lru v0.5.4
 	github.com/larksuite/oapi-sdk-go/v3 v3.0.17
 	github.com/magiconair/properties v1.8.6
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/viper v1.11.0
 	go.uber.org/zap v1.19.1
+	golang.org/x/text v0.3.7
 )
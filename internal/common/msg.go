@@ -6,9 +6,37 @@ import (
 )
 
 const (
-	NotFoundUserName = "欢迎使用飞书记账，请先告诉我你的名字"
-	AmountIllegal    = "金额格式错误"
-	NotSupport       = "往昔已逝，旧我已非。\r\n直接和我对话吧"
+	NotFoundUserName    = "欢迎使用飞书记账，请先告诉我你的名字"
+	AmountIllegal       = "金额格式错误"
+	NotSupport          = "往昔已逝，旧我已非。\r\n直接和我对话吧"
+	BillNotFound        = "没有找到对应的账单"
+	Subscribe           = "欢迎关注 Richman 记账。\r\n请先告诉我你的名字，如：我叫小王"
+	UnsupportedMsgType  = "暂时只支持文字和语音消息哦，直接告诉我花了多少钱吧，如：包子 15"
+	VoiceNotRecognized  = "没有听清，请再说一次或直接输入文字"
+	SystemBusy          = "系统繁忙，请稍后再试"
+	APIDisabled         = "开放接口未开启"
+	ExportDisabled      = "导出未开启，请配置 SERVER_URL 及 API_SECRET"
+	RecurringDisabled   = "周期账单未开启，请配置 RECURRING_DB_TOKEN 及 RECURRING_TABLE_TOKEN"
+	RecurringDayIllegal = "记账日期错误，请输入每月 1 到 31 号"
+	RecurringEmpty      = "还没有周期账单，可以这样添加：每月1号房租 3000"
+	RecurringNotFound   = "没有找到对应的周期账单"
+	ReportDisabled      = "报告订阅未开启，请配置 SUBSCRIPTION_DB_TOKEN 及 SUBSCRIPTION_TABLE_TOKEN"
+	ReportIllegal       = "请选择日报、周报或月报，推送时间为 0 到 23 点，如：订阅日报 21点"
+	ReportEmpty         = "还没有订阅报告，可以这样订阅：订阅日报 21点"
+	ReportNotFound      = "没有订阅对应的报告"
+	ReminderDisabled    = "记账提醒未开启，请配置 SUBSCRIPTION_DB_TOKEN 及 REMINDER_TABLE_TOKEN"
+	ReminderOn          = "已开启记账提醒，长时间没有记账时会提醒你"
+	ReminderOff         = "已关闭记账提醒，回复「开启提醒」可重新开启"
+	MemberDisabled      = "共享账本及多账本未开启，请配置 MEMBER_TABLE_TOKEN 及 INVITE_TABLE_TOKEN"
+	InviteForbidden     = "只有账本创建者可以邀请成员"
+	InviteInvalid       = "邀请码无效或已过期，请让账本创建者重新回复「邀请家人」"
+	LedgerReadOnly      = "你在当前账本只能查看账单，不能记账"
+	RenameForbidden     = "只有账本创建者可以修改账本名称"
+	CurrencyForbidden   = "只有账本创建者可以设置本位币"
+	LedgerNameRequired  = "请输入账本名称，如：新建账本 家庭"
+	LedgerNotFound      = "没有找到对应的账本，回复「账本列表」查看所有账本"
+	CurrencyIllegal     = "无法识别的币种，请使用美元、日元等名称或 USD、JPY 等代码"
+	SplitIllegal        = "分摊参与人或金额错误，可以这样分摊：AA 晚饭 300 我和小王、AA 房租 3000 我和小王 按2:1"
 )
 
 func RecordSuccess(f float64, expenses Expenses) string {
@@ -26,6 +54,10 @@ func Analysis(in, out float64) string {
 	return strings.Join(msg, "\r\n")
 }
 
+func LocalLedger(name string) string {
+	return fmt.Sprintf("%s 保存在服务器本地，暂不支持在线查看", name)
+}
+
 func Err(err error) string {
 	return fmt.Sprintf("发生了一个错误！ %s", err.Error())
 }
@@ -33,3 +65,258 @@ func Err(err error) string {
 func Welcome(name string) string {
 	return fmt.Sprintf("欢迎：%s 使用飞书记账 \r\n 可以回复 [查看账本] 来看为你创建的账本", name)
 }
+
+func QueryResult(start, end string, in, out float64, details []string, unconverted int) string {
+	msg := make([]string, 0)
+	msg = append(msg, fmt.Sprintf("%s - %s", start, end))
+	msg = append(msg, fmt.Sprintf("收入 %.2f", in))
+	msg = append(msg, fmt.Sprintf("支出 %.2f", out))
+	if len(details) > 0 {
+		msg = append(msg, "分类明细:")
+		msg = append(msg, details...)
+	}
+	if unconverted > 0 {
+		msg = append(msg, UnconvertedBills(unconverted))
+	}
+	return strings.Join(msg, "\r\n")
+}
+
+// UnconvertedBills 缺少汇率的外币账单不计入合计，提示用户统计不完整
+func UnconvertedBills(count int) string {
+	return fmt.Sprintf("另有 %d 笔外币账单缺少汇率，未计入统计", count)
+}
+
+func CategoryDetail(category string, expenses Expenses, amount float64, count int) string {
+	return fmt.Sprintf("[%s] %s %.2f (%d笔)", expenses, category, amount, count)
+}
+
+func BillDesc(remark string, categories []string, amount float64, currency, expenses string) string {
+	return fmt.Sprintf("[%s] %s %s %s", expenses, remark, Money(amount, currency), strings.Join(categories, ","))
+}
+
+// Money 金额，本位币不显示币种
+func Money(amount float64, currency string) string {
+	if currency == "" {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+func CurrencyConverted(amount float64, currency string, converted float64, base string) string {
+	return fmt.Sprintf("%s ≈ %s", Money(amount, currency), Money(converted, base))
+}
+
+func RateNotFound(currency, base string) string {
+	return fmt.Sprintf("暂时查询不到 %s 兑 %s 的汇率，无法记账，请稍后再试或联系管理员配置汇率", currency, base)
+}
+
+func BaseCurrencySet(ledger, currency string) string {
+	return fmt.Sprintf("账本 %s 的本位币已设置为 %s，统计时外币账单按账单日期的汇率换算", ledger, currency)
+}
+
+func UpdateSuccess(desc string) string {
+	return fmt.Sprintf("修改成功。%s", desc)
+}
+
+func DeleteSuccess(desc string) string {
+	return fmt.Sprintf("已删除。%s", desc)
+}
+
+func BatchRecordSuccess(lines []string, totals map[Expenses]float64) string {
+	msg := make([]string, 0)
+	msg = append(msg, fmt.Sprintf("记账成功，共 %d 笔：", len(lines)))
+	msg = append(msg, lines...)
+	if out, ok := totals[Pay]; ok {
+		msg = append(msg, fmt.Sprintf("本月已支出 %.2f", out))
+	}
+	if in, ok := totals[Income]; ok {
+		msg = append(msg, fmt.Sprintf("本月已收入 %.2f", in))
+	}
+	return strings.Join(msg, "\r\n")
+}
+
+func APIToken(token, expireAt string) string {
+	return fmt.Sprintf("你的接口令牌，请勿泄露：\r\n%s\r\n有效期至 %s，请求时设置 Header Authorization: Bearer <令牌>", token, expireAt)
+}
+
+func ExportLink(start, end, format, link string) string {
+	return fmt.Sprintf("%s 至 %s 的账单已生成，格式 %s，24 小时内有效：\r\n%s", start, end, format, link)
+}
+
+func budgetName(category string) string {
+	if category == "" {
+		return "总"
+	}
+	return category
+}
+
+func BudgetSet(category string, amount, spent float64) string {
+	return fmt.Sprintf("已设置%s预算 %.2f/月，本月已支出 %.2f，剩余 %.2f", budgetName(category), amount, spent, amount-spent)
+}
+
+func BudgetRemoved(category string) string {
+	return fmt.Sprintf("已取消%s预算", budgetName(category))
+}
+
+func BudgetRemaining(category string, remaining float64) string {
+	if remaining < 0 {
+		return fmt.Sprintf("本月%s预算已超支 %.2f", budgetName(category), -remaining)
+	}
+	return fmt.Sprintf("本月%s预算剩余 %.2f", budgetName(category), remaining)
+}
+
+func BudgetAlert(category string, threshold int) string {
+	if threshold >= 100 {
+		return fmt.Sprintf("⚠️ 本月%s预算已用完", budgetName(category))
+	}
+	return fmt.Sprintf("⚠️ 本月%s预算已使用 %d%%", budgetName(category), threshold)
+}
+
+func RecurringDesc(day int, desc string) string {
+	return fmt.Sprintf("每月%d号 %s", day, desc)
+}
+
+func RecurringAdded(desc, nextDate string) string {
+	return fmt.Sprintf("已添加周期账单。%s\r\n下次记账日期 %s", desc, nextDate)
+}
+
+func RecurringRemoved(desc string) string {
+	return fmt.Sprintf("已删除周期账单，已记录的账单不受影响。%s", desc)
+}
+
+func Report(name, start, end string, in, out float64, top, budgets []string, unconverted int) string {
+	msg := make([]string, 0)
+	if start == end {
+		msg = append(msg, fmt.Sprintf("【%s】%s", name, start))
+	} else {
+		msg = append(msg, fmt.Sprintf("【%s】%s - %s", name, start, end))
+	}
+	msg = append(msg, fmt.Sprintf("收入 %.2f", in))
+	msg = append(msg, fmt.Sprintf("支出 %.2f", out))
+	if len(top) > 0 {
+		msg = append(msg, "支出最多:")
+		msg = append(msg, top...)
+	}
+	msg = append(msg, budgets...)
+	if unconverted > 0 {
+		msg = append(msg, UnconvertedBills(unconverted))
+	}
+	return strings.Join(msg, "\r\n")
+}
+
+func ReportSubscription(name string, hour int) string {
+	switch name {
+	case "周报":
+		return fmt.Sprintf("%s 每周日 %d 点推送", name, hour)
+	case "月报":
+		return fmt.Sprintf("%s 每月最后一天 %d 点推送", name, hour)
+	}
+	return fmt.Sprintf("%s 每天 %d 点推送", name, hour)
+}
+
+func ReportSubscribed(desc string) string {
+	return fmt.Sprintf("订阅成功。%s", desc)
+}
+
+func ReportUnsubscribed(name string) string {
+	return fmt.Sprintf("已取消订阅%s", name)
+}
+
+// DormantReminder 很久没有记账或从未记账时的提醒
+const DormantReminder = "好久没有记账了，花了什么随手记一笔吧，如：午饭 25\r\n回复「关闭提醒」不再提醒"
+
+func InactiveReminder(days int) string {
+	return fmt.Sprintf("已经 %d 天没有记账了，花了什么随手记一笔吧，如：午饭 25\r\n回复「关闭提醒」不再提醒", days)
+}
+
+var memberRoles = map[string]string{
+	"owner":  "创建者",
+	"editor": "可记账",
+	"viewer": "只读",
+}
+
+func LedgerInvite(code, role string, hours int) string {
+	return fmt.Sprintf("邀请码 %s（%s），%d 小时内有效。\r\n请对方关注后回复「加入账本 %s」", code, memberRoles[role], hours, code)
+}
+
+func LedgerJoined(name, role string) string {
+	return fmt.Sprintf("已加入账本 %s（%s），之后的记账和查询都会使用该账本，账单署名为你的称呼", name, memberRoles[role])
+}
+
+func LedgerMember(name, role string) string {
+	return fmt.Sprintf("%s（%s）", name, memberRoles[role])
+}
+
+func LedgerItem(index int, name, role string, active bool) string {
+	if active {
+		return fmt.Sprintf("%d. %s（%s）【当前】", index, name, memberRoles[role])
+	}
+	return fmt.Sprintf("%d. %s（%s）", index, name, memberRoles[role])
+}
+
+func LedgerCreated(name string) string {
+	return fmt.Sprintf("已创建账本 %s 并切换为当前账本，回复「账本列表」查看所有账本", name)
+}
+
+func LedgerSwitched(name string) string {
+	return fmt.Sprintf("已切换到账本 %s，之后的记账和查询都会使用该账本", name)
+}
+
+func LedgerRenamed(name string) string {
+	return fmt.Sprintf("账本已改名为 %s", name)
+}
+
+func MemberSpending(start, end string, details []string, unconverted int) string {
+	msg := make([]string, 0)
+	msg = append(msg, fmt.Sprintf("%s - %s 成员收支", start, end))
+	if len(details) == 0 {
+		msg = append(msg, "没有账单")
+	}
+	msg = append(msg, details...)
+	if unconverted > 0 {
+		msg = append(msg, UnconvertedBills(unconverted))
+	}
+	return strings.Join(msg, "\r\n")
+}
+
+func MemberSpendingDetail(rank int, name string, in, out float64, count int, top []string) string {
+	if name == "" {
+		name = "未署名"
+	}
+	detail := fmt.Sprintf("%d. %s 支出 %.2f 收入 %.2f (%d笔)", rank, name, out, in, count)
+	if len(top) > 0 {
+		detail += fmt.Sprintf("\r\n   支出最多: %s", strings.Join(top, "、"))
+	}
+	return detail
+}
+
+func CategoryAmount(category string, amount float64) string {
+	return fmt.Sprintf("%s %.2f", category, amount)
+}
+
+func SplitShare(name string, amount float64) string {
+	return fmt.Sprintf("%s 承担 %.2f", name, amount)
+}
+
+// SplitMemberAmbiguous 账本中有多个成员使用相同的称呼，无法确定分摊给谁
+func SplitMemberAmbiguous(name string) string {
+	return fmt.Sprintf("账本中有多位成员叫「%s」，无法确定是哪一位。请让对方发送「我叫 新称呼」修改称呼后再分摊", name)
+}
+
+func SplitDetail(payer string, shares []string) string {
+	return fmt.Sprintf("%s 付款，%s", payer, strings.Join(shares, "，"))
+}
+
+func SettleTransfer(from, to string, amount float64) string {
+	return fmt.Sprintf("%s → %s %.2f", from, to, amount)
+}
+
+func SettleResult(start, end string, transfers []string) string {
+	msg := make([]string, 0)
+	msg = append(msg, fmt.Sprintf("%s - %s 分摊结算", start, end))
+	if len(transfers) == 0 {
+		msg = append(msg, "账已结清，不需要转账")
+	}
+	msg = append(msg, transfers...)
+	return strings.Join(msg, "\r\n")
+}
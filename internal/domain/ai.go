@@ -8,7 +8,13 @@ type AI struct {
 type AIReq struct {
 	Model     string       `json:"model"`
 	Messages  []AIMessage  `json:"messages"`
-	Functions []AIFunction `json:"functions"`
+	Functions []AIFunction `json:"functions,omitempty"`
+	Tools     []AITool     `json:"tools,omitempty"`
+}
+
+type AITool struct {
+	Type     string     `json:"type"`
+	Function AIFunction `json:"function"`
 }
 
 type AIFunction struct {
@@ -24,9 +30,12 @@ type AIParameter struct {
 }
 
 type AIProperty struct {
-	Type        string    `json:"type"`
-	Description string    `json:"description"`
-	Enum        *[]string `json:"enum,omitempty"`
+	Type        string                `json:"type"`
+	Description string                `json:"description"`
+	Enum        *[]string             `json:"enum,omitempty"`
+	Items       *AIProperty           `json:"items,omitempty"`
+	Properties  map[string]AIProperty `json:"properties,omitempty"`
+	Required    *[]string             `json:"required,omitempty"`
 }
 
 type AIChoices struct {
@@ -52,6 +61,13 @@ type AIMessage struct {
 	Role         string          `json:"role"`
 	Content      string          `json:"content"`
 	FunctionCall *AIFunctionCall `json:"function_call,omitempty"`
+	ToolCalls    []AIToolCall    `json:"tool_calls,omitempty"`
+}
+
+type AIToolCall struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type"`
+	Function AIFunctionCall `json:"function"`
 }
 
 type AIFunctionCall struct {
@@ -1,6 +1,7 @@
 package domain
 
 type Bill struct {
+	ID         string   `json:"id"`
 	Remark     string   `json:"remark"`
 	Categories []string `json:"categories"`
 	Amount     float64  `json:"amount"`
@@ -9,4 +10,18 @@ type Bill struct {
 	Expenses   string   `json:"expenses"`
 	AuthorID   string   `json:"author_id"`
 	AuthorName string   `json:"author_name"`
+	// TransactionID 支付宝、微信支付等第三方的交易单号，用于导入时排重
+	TransactionID string `json:"transaction_id,omitempty"`
+	// Currency 金额的币种，如 USD，为空时为人民币
+	Currency string `json:"currency,omitempty"`
+	// Splits 分摊账单的参与人及应承担的金额，为空时不是分摊账单
+	Splits []Split `json:"splits,omitempty"`
+}
+
+// CurrencyCode 账单的币种，未记录币种的账单为 DefaultCurrency
+func (b *Bill) CurrencyCode() string {
+	if b.Currency == "" {
+		return DefaultCurrency
+	}
+	return b.Currency
 }
@@ -0,0 +1,15 @@
+package domain
+
+import "io"
+
+// BillScan 按日期顺序逐条回调账单，用于导出时不必一次加载全部账单
+type BillScan func(fn func(bill *Bill) error) error
+
+// BillExporter 按格式导出账单，列名使用固定的英文名称，与多维表格字段名无关
+type BillExporter interface {
+	// Formats 支持的导出格式
+	Formats() []string
+	// ContentType 导出格式对应的 MIME 类型
+	ContentType(format string) (string, bool)
+	Export(w io.Writer, format string, scan BillScan) error
+}
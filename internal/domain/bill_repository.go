@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/geeklubcn/feishu-bitable-db/db"
 )
 
@@ -12,9 +16,39 @@ const (
 	BillTableMonth    = "月份"
 	BillTableExpenses = "收支"
 	BillTableAuthor   = "花钱小能手"
+	// BillTableAuthorID 记账人的用户标识，旧账本中不存在时自动创建
+	BillTableAuthorID = "记账人ID"
+	// BillTableSplits 分摊账单的参与人及金额，JSON 格式，旧账本中不存在时自动创建
+	BillTableSplits = "分摊"
+	// BillTableCurrency 外币账单的币种，旧账本中不存在时自动创建
+	BillTableCurrency = "币种"
+	// BillTableTransactionID 导入账单的交易单号，旧账本中不存在时自动创建
+	BillTableTransactionID = "交易单号"
 )
 
+// ErrDuplicateBill 账本中已存在相同交易单号及收支的账单
+var ErrDuplicateBill = errors.New("bill with the same transaction id already exists")
+
 type BillRepository interface {
+	// Save 保存账单，并回写 bill.ID。本地存储中交易单号重复时返回 ErrDuplicateBill
 	Save(appToken, tableToken string, bill *Bill) error
+	// SaveBatch 批量保存账单，并回写每个 bill.ID，任一账单交易单号重复时全部不保存
+	SaveBatch(appToken, tableToken string, bills []*Bill) error
 	Search(appToken, tableToken string, ss []db.SearchCmd) []*Bill
+	// Scan 逐页查询符合条件的账单，按日期升序回调 fn，fn 返回错误时停止并返回该错误
+	Scan(appToken, tableToken string, ss []db.SearchCmd, fn func(bill *Bill) error) error
+	// Page 按日期倒序分页查询，跳过前 offset 条后返回最多 limit 条账单，以及符合条件的账单总数
+	Page(appToken, tableToken string, ss []db.SearchCmd, offset, limit int) ([]*Bill, int, error)
+	// Get 根据记录 ID 查询账单
+	Get(appToken, tableToken, id string) (*Bill, bool)
+	// Update 根据 bill.ID 更新账单
+	Update(appToken, tableToken string, bill *Bill) error
+	Delete(appToken, tableToken, id string) error
+}
+
+// DateVal 日期筛选条件的值，按多维表格公式格式化为 TODATE("yyyy-mm-dd")
+type DateVal time.Time
+
+func (d DateVal) String() string {
+	return fmt.Sprintf("TODATE(\"%s\")", time.Time(d).Format("2006-01-02"))
 }
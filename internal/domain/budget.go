@@ -0,0 +1,7 @@
+package domain
+
+// Budget 账本的月度预算，Category 为空时为总预算
+type Budget struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+}
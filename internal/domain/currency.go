@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency 未设置本位币的账本及未指定币种的账单均为人民币
+const DefaultCurrency = "CNY"
+
+// ErrRateNotFound 没有对应币种或日期的汇率
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// RateProvider 查询汇率，返回 date 当天 1 单位 from 币种可兑换的 to 币种金额
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string, date time.Time) (float64, error)
+}
+
+// currencyNames 币种的常用中文名称及符号，按 ISO 4217 代码归一
+var currencyNames = map[string]string{
+	"人民币":  "CNY",
+	"美元":   "USD",
+	"美金":   "USD",
+	"刀":    "USD",
+	"$":    "USD",
+	"欧元":   "EUR",
+	"€":    "EUR",
+	"日元":   "JPY",
+	"日币":   "JPY",
+	"円":    "JPY",
+	"英镑":   "GBP",
+	"£":    "GBP",
+	"港币":   "HKD",
+	"港元":   "HKD",
+	"澳门元":  "MOP",
+	"新台币":  "TWD",
+	"台币":   "TWD",
+	"韩元":   "KRW",
+	"泰铢":   "THB",
+	"新加坡元": "SGD",
+	"新币":   "SGD",
+	"澳元":   "AUD",
+	"澳币":   "AUD",
+	"加元":   "CAD",
+	"加币":   "CAD",
+	"瑞士法郎": "CHF",
+	"卢布":   "RUB",
+	"马币":   "MYR",
+	"越南盾":  "VND",
+}
+
+// CurrencyNames 可以识别的币种中文名称、符号及代码，按长度倒序，便于正则优先匹配较长的名称
+func CurrencyNames() []string {
+	has := make(map[string]bool)
+	names := make([]string, 0, len(currencyNames))
+	for name, code := range currencyNames {
+		names = append(names, name)
+		if !has[code] {
+			has[code] = true
+			names = append(names, code)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if li, lj := len([]rune(names[i])), len([]rune(names[j])); li != lj {
+			return li > lj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// ParseCurrency 将币种中文名称、符号或代码归一为 ISO 4217 代码，如 美元、usd 均为 USD
+func ParseCurrency(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if code, ok := currencyNames[s]; ok {
+		return code, true
+	}
+	if len(s) != 3 {
+		return "", false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return "", false
+		}
+	}
+	return strings.ToUpper(s), true
+}
@@ -0,0 +1,8 @@
+package domain
+
+import "context"
+
+// IntentParser 不依赖 AI，将用户输入解析为函数调用，无法识别时返回 false
+type IntentParser interface {
+	Parse(ctx context.Context, content string) (*AIFunctionCall, bool)
+}
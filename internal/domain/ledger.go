@@ -8,4 +8,27 @@ type Ledger struct {
 	URL         string `json:"url"`
 	CreatorID   string `json:"creator_id"`
 	CreatorName string `json:"creator_name"`
+	// Currency 本位币，统计时账单金额换算为该币种，为空时为 DefaultCurrency，由 LedgerRepository.UpdateCurrency 单独保存
+	Currency string `json:"-"`
+	// Budgets 月度预算，由 LedgerRepository.UpdateBudgets 单独保存
+	Budgets []Budget `json:"-"`
+}
+
+// BaseCurrency 账本的本位币
+func (l *Ledger) BaseCurrency() string {
+	if l.Currency == "" {
+		return DefaultCurrency
+	}
+	return l.Currency
+}
+
+// BillCurrency 在账本中记账时账单的币种，未指定时为本位币，人民币不记录币种
+func (l *Ledger) BillCurrency(code string) string {
+	if code == "" {
+		code = l.BaseCurrency()
+	}
+	if code == DefaultCurrency {
+		return ""
+	}
+	return code
 }
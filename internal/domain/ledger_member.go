@@ -0,0 +1,56 @@
+package domain
+
+import "errors"
+
+// 账本成员角色
+const (
+	// LedgerOwner 账本创建者，可以邀请成员
+	LedgerOwner = "owner"
+	// LedgerEditor 可以记账、修改账本
+	LedgerEditor = "editor"
+	// LedgerViewer 只能查看账单
+	LedgerViewer = "viewer"
+)
+
+// ErrMemberDisabled 未配置账本成员的存储表，账本只属于创建者
+var ErrMemberDisabled = errors.New("ledger member table not configured")
+
+// LedgerMember 账本成员，同一用户可以创建、加入多个账本
+type LedgerMember struct {
+	ID       string `json:"id"`
+	LedgerID string `json:"ledger_id"`
+	UID      string `json:"uid"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	// JoinedAt 加入时间，毫秒
+	JoinedAt int64 `json:"joined_at"`
+	// ActiveAt 最近切换到该账本的时间，毫秒，用户所有账本中最大的为当前账本
+	ActiveAt int64 `json:"active_at"`
+}
+
+// Writable 是否可以记账
+func (m *LedgerMember) Writable() bool {
+	return m.Role == LedgerOwner || m.Role == LedgerEditor
+}
+
+// LedgerInvite 账本邀请码，凭邀请码以指定角色加入账本
+type LedgerInvite struct {
+	Code      string `json:"code"`
+	LedgerID  string `json:"ledger_id"`
+	Role      string `json:"role"`
+	InviterID string `json:"inviter_id"`
+	// ExpireAt 过期时间，毫秒
+	ExpireAt int64 `json:"expire_at"`
+}
+
+type LedgerMemberRepository interface {
+	// Save 保存成员，同一账本的同一用户只保留一条，重复保存时更新角色及加入时间
+	Save(it *LedgerMember) error
+	// QueryByUID 用户加入的账本，最近切换到的在前
+	QueryByUID(UID string) []*LedgerMember
+	// Activate 切换用户的当前账本
+	Activate(ledgerID, UID string, at int64) error
+	QueryByLedger(ledgerID string) []*LedgerMember
+	SaveInvite(it *LedgerInvite) error
+	QueryInvite(code string) (*LedgerInvite, bool)
+}
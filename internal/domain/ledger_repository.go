@@ -4,8 +4,20 @@ import "context"
 
 type LedgerRepository interface {
 	Save(it *Ledger) error
-	QueryByUID(UID string) (*Ledger, bool)
+	QueryByID(id string) (*Ledger, bool)
+	// QueryByCreator 用户创建的账本，账本成员由 LedgerMemberRepository 保存
+	QueryByCreator(UID string) (*Ledger, bool)
 	QueryUnallocated() []*Ledger
 	UpdateUser(id string, user User) error
+	UpdateName(id, name string) error
+	// UpdateBudgets 保存账本的预算
+	UpdateBudgets(it *Ledger) error
+	// UpdateCurrency 保存账本的本位币
+	UpdateCurrency(it *Ledger) error
 	WarmUP(ctx context.Context)
 }
+
+// LedgerProvisioner 为新账本准备账单存储，返回尚未保存的账本
+type LedgerProvisioner interface {
+	Provision(ctx context.Context) (*Ledger, error)
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+// Messenger 主动向用户推送消息
+type Messenger interface {
+	SendText(ctx context.Context, UID, content string) error
+}
+
+// Messengers 按消息渠道选择推送方式，key 为渠道，如 wechat、feishu
+type Messengers map[string]Messenger
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RecurringPeriodLayout 周期账单的记账周期，按月
+const RecurringPeriodLayout = "2006-01"
+
+// ErrRecurringDisabled 未配置周期账单的存储表
+var ErrRecurringDisabled = errors.New("recurring bill table not configured")
+
+// RecurringBill 周期账单规则，每月固定日期自动记账，如房租、订阅、工资
+type RecurringBill struct {
+	ID         string `json:"id"`
+	AppToken   string `json:"app_token"`
+	TableToken string `json:"table_token"`
+	// Day 每月几号记账，超过当月天数时为月末
+	Day        int     `json:"day"`
+	Remark     string  `json:"remark"`
+	Category   string  `json:"category"`
+	Amount     float64 `json:"amount"`
+	Expenses   string  `json:"expenses"`
+	AuthorID   string  `json:"author_id"`
+	AuthorName string  `json:"author_name"`
+	// NextPeriod 下一个待记账的周期，格式为 RecurringPeriodLayout
+	NextPeriod string `json:"next_period"`
+}
+
+// DueDate 指定周期的记账日期
+func (r *RecurringBill) DueDate(period time.Time) time.Time {
+	first := time.Date(period.Year(), period.Month(), 1, 0, 0, 0, 0, time.Local)
+	last := first.AddDate(0, 1, -1).Day()
+	day := r.Day
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// NextDueDate 下一个待记账周期的记账日期
+func (r *RecurringBill) NextDueDate() (time.Time, error) {
+	period, err := time.ParseInLocation(RecurringPeriodLayout, r.NextPeriod, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.DueDate(period), nil
+}
+
+// IdempotencyKey 周期账单生成的账单写入交易单号，同一规则同一周期只记一次
+func (r *RecurringBill) IdempotencyKey(period string) string {
+	return fmt.Sprintf("recurring:%s:%s", r.ID, period)
+}
+
+type RecurringBillRepository interface {
+	// Save 保存规则，并回写 it.ID
+	Save(it *RecurringBill) error
+	// ClaimNextPeriod 存储中规则的下一个记账周期仍为 it.NextPeriod 时更新为 next 并回写 it.NextPeriod，
+	// 已被其他实例更新时返回 false
+	ClaimNextPeriod(it *RecurringBill, next string) (bool, error)
+	Delete(id string) error
+	QueryByLedger(appToken, tableToken string) []*RecurringBill
+	QueryAll() []*RecurringBill
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "errors"
+
+// ErrReminderDisabled 未配置记账提醒的存储表
+var ErrReminderDisabled = errors.New("reminder table not configured")
+
+// Reminder 用户的记账提醒设置，首次使用时按消息渠道创建，默认开启
+type Reminder struct {
+	UID string `json:"uid"`
+	// Channel 推送渠道，与用户最早使用的消息渠道一致
+	Channel  string `json:"channel"`
+	Disabled bool   `json:"disabled"`
+	// LastRemindAt 最近一次提醒的时间，unix 毫秒
+	LastRemindAt int64 `json:"last_remind_at"`
+}
+
+type ReminderRepository interface {
+	// Touch 用户没有提醒设置时按渠道创建，已存在时不修改
+	Touch(UID, channel string) error
+	// SetDisabled 开启或关闭提醒，没有提醒设置时按渠道创建
+	SetDisabled(UID, channel string, disabled bool) error
+	QueryEnabled() []*Reminder
+	// ClaimRemind 将最近提醒时间由 it.LastRemindAt 更新为 at，已被其他实例更新时返回 false
+	ClaimRemind(it *Reminder, at int64) (bool, error)
+}
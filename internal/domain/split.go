@@ -0,0 +1,263 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// 分摊方式
+const (
+	// SplitEqual 平均分摊
+	SplitEqual = "equal"
+	// SplitRatio 按比例分摊，如 2:1，比例为 0 的参与人不承担
+	SplitRatio = "ratio"
+	// SplitExact 指定每人金额，合计须等于账单金额
+	SplitExact = "exact"
+)
+
+// ErrSplitIllegal 参与人或分摊份额错误
+var ErrSplitIllegal = errors.New("illegal split participants or shares")
+
+// maxSplitAmount 分摊金额及比例的上限，避免换算为分时溢出
+const maxSplitAmount = 1e9
+
+// Split 分摊账单中参与人应承担的金额，付款人为账单的记账人
+type Split struct {
+	UID    string  `json:"uid,omitempty"`
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// Balance 结算中一人的分摊净额，正数为应收，负数为应付
+type Balance struct {
+	UID    string  `json:"uid,omitempty"`
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// Transfer 结算时的一笔转账
+type Transfer struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+// NewSplits 按分摊方式计算每个参与人应承担的金额，精确到分，除不尽的部分依次分给排在前面的参与人。
+// mode 为 SplitRatio 时 shares 为比例，为 SplitExact 时 shares 为每人金额
+func NewSplits(amount float64, names []string, mode string, shares []float64) ([]Split, error) {
+	if !validAmount(amount) || len(names) == 0 {
+		return nil, ErrSplitIllegal
+	}
+	for _, it := range shares {
+		if !validAmount(it) {
+			return nil, ErrSplitIllegal
+		}
+	}
+	total := cents(amount)
+	if total <= 0 {
+		return nil, ErrSplitIllegal
+	}
+	has := make(map[string]bool)
+	for _, it := range names {
+		if it == "" || has[it] {
+			return nil, ErrSplitIllegal
+		}
+		has[it] = true
+	}
+
+	parts := make([]int64, len(names))
+	switch mode {
+	case SplitExact:
+		if len(shares) != len(names) {
+			return nil, ErrSplitIllegal
+		}
+		var sum int64
+		for i, it := range shares {
+			parts[i] = cents(it)
+			sum += parts[i]
+		}
+		if sum != total {
+			return nil, ErrSplitIllegal
+		}
+	case SplitRatio:
+		if len(shares) != len(names) {
+			return nil, ErrSplitIllegal
+		}
+		var sum float64
+		for _, it := range shares {
+			sum += it
+		}
+		if sum <= 0 {
+			return nil, ErrSplitIllegal
+		}
+		var allocated int64
+		for i, it := range shares {
+			parts[i] = int64(math.Floor(float64(total) * it / sum))
+			allocated += parts[i]
+		}
+		// 向下取整后剩余不足参与人数的分，依次分给比例大于 0 的参与人
+		remainder := total - allocated
+		if remainder < 0 || remainder > int64(len(parts)) {
+			return nil, ErrSplitIllegal
+		}
+		for i := 0; remainder > 0; i = (i + 1) % len(parts) {
+			if shares[i] > 0 {
+				parts[i]++
+				remainder--
+			}
+		}
+	default:
+		for i := range parts {
+			parts[i] = total / int64(len(parts))
+			if int64(i) < total%int64(len(parts)) {
+				parts[i]++
+			}
+		}
+	}
+
+	res := make([]Split, 0, len(names))
+	for i, it := range names {
+		res = append(res, Split{Name: it, Amount: float64(parts[i]) / 100})
+	}
+	return res, nil
+}
+
+// RescaleSplits 账单金额修改后按原有分摊金额的比例重新分摊
+func RescaleSplits(splits []Split, amount float64) ([]Split, error) {
+	names := make([]string, 0, len(splits))
+	shares := make([]float64, 0, len(splits))
+	for _, it := range splits {
+		names = append(names, it.Name)
+		shares = append(shares, it.Amount)
+	}
+	res, err := NewSplits(amount, names, SplitRatio, shares)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		res[i].UID = splits[i].UID
+	}
+	return res, nil
+}
+
+// Balances 汇总分摊账单的净额，付款人记应收，参与人记应付。有用户标识时按用户标识汇总，
+// 同名的不同成员分别结算，没有时按名称汇总。结果按名称排序，不包含净额为 0 的人
+func Balances(bills []*Bill) []Balance {
+	amounts := make(map[string]int64)
+	names := make(map[string]string)
+	add := func(UID, name string, amount int64) {
+		key := partyKey(UID, name)
+		amounts[key] += amount
+		names[key] = name
+	}
+	for _, it := range bills {
+		if len(it.Splits) == 0 {
+			continue
+		}
+		for _, s := range it.Splits {
+			add(it.AuthorID, it.AuthorName, cents(s.Amount))
+			add(s.UID, s.Name, -cents(s.Amount))
+		}
+	}
+	res := make([]Balance, 0, len(amounts))
+	for key, it := range amounts {
+		if it == 0 {
+			continue
+		}
+		b := Balance{Name: names[key], Amount: float64(it) / 100}
+		if key != partyKey("", b.Name) {
+			b.UID = key
+		}
+		res = append(res, b)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].UID < res[j].UID
+	})
+	return res
+}
+
+// partyKey 有用户标识时使用用户标识，否则使用名称，名称加前缀避免与用户标识重复
+func partyKey(UID, name string) string {
+	if UID != "" {
+		return UID
+	}
+	return "name:" + name
+}
+
+// Settle 生成结清所有净额的转账，每次由应付最多的人转给应收最多的人，转账笔数不超过人数减一
+func Settle(balances []Balance) []Transfer {
+	debtors := make([]*party, 0)
+	creditors := make([]*party, 0)
+	for _, it := range balances {
+		p := &party{key: partyKey(it.UID, it.Name), name: it.Name}
+		if c := cents(it.Amount); c > 0 {
+			p.amount = c
+			creditors = append(creditors, p)
+		} else if c < 0 {
+			p.amount = -c
+			debtors = append(debtors, p)
+		}
+	}
+	byAmount := func(ps []*party) func(i, j int) bool {
+		return func(i, j int) bool {
+			if ps[i].amount != ps[j].amount {
+				return ps[i].amount > ps[j].amount
+			}
+			return ps[i].key < ps[j].key
+		}
+	}
+
+	res := make([]Transfer, 0)
+	for len(debtors) > 0 && len(creditors) > 0 {
+		sort.SliceStable(debtors, byAmount(debtors))
+		sort.SliceStable(creditors, byAmount(creditors))
+		i, j := matchEqual(debtors, creditors)
+		from, to := debtors[i], creditors[j]
+		amount := from.amount
+		if to.amount < amount {
+			amount = to.amount
+		}
+		res = append(res, Transfer{From: from.name, To: to.name, Amount: float64(amount) / 100})
+		from.amount -= amount
+		to.amount -= amount
+		if from.amount == 0 {
+			debtors = append(debtors[:i], debtors[i+1:]...)
+		}
+		if to.amount == 0 {
+			creditors = append(creditors[:j], creditors[j+1:]...)
+		}
+	}
+	return res
+}
+
+// party 结算中的一方，amount 为待转出或待收入的金额，单位为分
+type party struct {
+	key    string
+	name   string
+	amount int64
+}
+
+// matchEqual 优先匹配金额相等的应付、应收，一笔转账结清两人，没有时返回金额最大的两人
+func matchEqual(debtors, creditors []*party) (int, int) {
+	for i, d := range debtors {
+		for j, c := range creditors {
+			if d.amount == c.amount {
+				return i, j
+			}
+		}
+	}
+	return 0, 0
+}
+
+// validAmount 金额或比例为有限的非负数，且不超过 maxSplitAmount
+func validAmount(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0) && v >= 0 && v <= maxSplitAmount
+}
+
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNewSplits(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  float64
+		names   []string
+		mode    string
+		shares  []float64
+		want    []float64
+		wantErr bool
+	}{
+		{name: "平均分摊", amount: 90, names: []string{"老王", "小李", "小张"}, mode: SplitEqual, want: []float64{30, 30, 30}},
+		{name: "平均分摊余数给前面的人", amount: 100, names: []string{"老王", "小李", "小张"}, mode: SplitEqual, want: []float64{33.34, 33.33, 33.33}},
+		{name: "按比例分摊", amount: 90, names: []string{"老王", "小李"}, mode: SplitRatio, shares: []float64{2, 1}, want: []float64{60, 30}},
+		{name: "按比例分摊余数跳过比例为 0 的人", amount: 0.05, names: []string{"老王", "小李", "小张"}, mode: SplitRatio, shares: []float64{0, 1, 1}, want: []float64{0, 0.03, 0.02}},
+		{name: "指定金额", amount: 100, names: []string{"老王", "小李"}, mode: SplitExact, shares: []float64{70.5, 29.5}, want: []float64{70.5, 29.5}},
+		{name: "指定金额合计不等于账单金额", amount: 100, names: []string{"老王", "小李"}, mode: SplitExact, shares: []float64{70, 20}, wantErr: true},
+		{name: "份额与参与人数量不一致", amount: 100, names: []string{"老王", "小李"}, mode: SplitRatio, shares: []float64{1}, wantErr: true},
+		{name: "比例合计为 0", amount: 100, names: []string{"老王", "小李"}, mode: SplitRatio, shares: []float64{0, 0}, wantErr: true},
+		{name: "负数比例", amount: 100, names: []string{"老王", "小李"}, mode: SplitRatio, shares: []float64{-1, 2}, wantErr: true},
+		{name: "比例为 NaN", amount: 100, names: []string{"老王", "小李"}, mode: SplitRatio, shares: []float64{math.NaN(), 1}, wantErr: true},
+		{name: "比例为无穷大", amount: 100, names: []string{"老王", "小李"}, mode: SplitRatio, shares: []float64{math.Inf(1), 1}, wantErr: true},
+		{name: "金额超过上限", amount: 2e9, names: []string{"老王", "小李"}, mode: SplitEqual, wantErr: true},
+		{name: "金额为 0", amount: 0, names: []string{"老王"}, mode: SplitEqual, wantErr: true},
+		{name: "没有参与人", amount: 100, mode: SplitEqual, wantErr: true},
+		{name: "参与人重复", amount: 100, names: []string{"老王", "老王"}, mode: SplitEqual, wantErr: true},
+		{name: "参与人名称为空", amount: 100, names: []string{"老王", ""}, mode: SplitEqual, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSplits(tt.amount, tt.names, tt.mode, tt.shares)
+			if tt.wantErr {
+				if err != ErrSplitIllegal {
+					t.Fatalf("NewSplits() error = %v, want %v", err, ErrSplitIllegal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewSplits() error = %v", err)
+			}
+			amounts := make([]float64, 0, len(got))
+			for i, it := range got {
+				if it.Name != tt.names[i] {
+					t.Errorf("NewSplits()[%d].Name = %s, want %s", i, it.Name, tt.names[i])
+				}
+				amounts = append(amounts, it.Amount)
+			}
+			if !reflect.DeepEqual(amounts, tt.want) {
+				t.Errorf("NewSplits() amounts = %v, want %v", amounts, tt.want)
+			}
+		})
+	}
+}
+
+func TestBalances(t *testing.T) {
+	tests := []struct {
+		name  string
+		bills []*Bill
+		want  []Balance
+	}{
+		{
+			name: "付款人应收参与人应付",
+			bills: []*Bill{
+				{AuthorID: "u1", AuthorName: "老王", Splits: []Split{{UID: "u1", Name: "老王", Amount: 30}, {UID: "u2", Name: "小李", Amount: 30}, {Name: "小张", Amount: 30}}},
+			},
+			want: []Balance{{Name: "小张", Amount: -30}, {UID: "u2", Name: "小李", Amount: -30}, {UID: "u1", Name: "老王", Amount: 60}},
+		},
+		{
+			name: "同名的不同成员分别结算",
+			bills: []*Bill{
+				{AuthorID: "u1", AuthorName: "老王", Splits: []Split{{UID: "u2", Name: "小李", Amount: 10}, {UID: "u3", Name: "小李", Amount: 20}}},
+			},
+			want: []Balance{{UID: "u2", Name: "小李", Amount: -10}, {UID: "u3", Name: "小李", Amount: -20}, {UID: "u1", Name: "老王", Amount: 30}},
+		},
+		{
+			name: "净额为 0 的人及非分摊账单不参与结算",
+			bills: []*Bill{
+				{AuthorID: "u1", AuthorName: "老王", Splits: []Split{{UID: "u2", Name: "小李", Amount: 20}}},
+				{AuthorID: "u2", AuthorName: "小李", Splits: []Split{{UID: "u1", Name: "老王", Amount: 20}}},
+				{AuthorID: "u1", AuthorName: "老王", Amount: 100},
+			},
+			want: []Balance{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Balances(tt.bills); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Balances() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances []Balance
+		want     []Transfer
+	}{
+		{
+			name:     "一人付款两人分摊",
+			balances: []Balance{{Name: "小张", Amount: -30}, {Name: "小李", Amount: -30}, {Name: "老王", Amount: 60}},
+			want:     []Transfer{{From: "小张", To: "老王", Amount: 30}, {From: "小李", To: "老王", Amount: 30}},
+		},
+		{
+			name:     "优先匹配金额相等的两人",
+			balances: []Balance{{Name: "A", Amount: -50}, {Name: "B", Amount: -20}, {Name: "C", Amount: 20}, {Name: "D", Amount: 50}},
+			want:     []Transfer{{From: "A", To: "D", Amount: 50}, {From: "B", To: "C", Amount: 20}},
+		},
+		{
+			name:     "应付最多的人转给应收最多的人",
+			balances: []Balance{{Name: "A", Amount: -70}, {Name: "B", Amount: -10}, {Name: "C", Amount: 40}, {Name: "D", Amount: 40}},
+			want:     []Transfer{{From: "A", To: "C", Amount: 40}, {From: "A", To: "D", Amount: 30}, {From: "B", To: "D", Amount: 10}},
+		},
+		{
+			name:     "同名成员按用户标识区分",
+			balances: []Balance{{UID: "u2", Name: "小李", Amount: -10}, {UID: "u3", Name: "小李", Amount: 10}},
+			want:     []Transfer{{From: "小李", To: "小李", Amount: 10}},
+		},
+		{
+			name: "没有净额",
+			want: []Transfer{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Settle(tt.balances)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Settle() = %+v, want %+v", got, tt.want)
+			}
+			if n := len(tt.balances); n > 0 && len(got) > n-1 {
+				t.Errorf("Settle() transfers = %d, want at most %d", len(got), n-1)
+			}
+		})
+	}
+}
+
+func TestRescaleSplits(t *testing.T) {
+	splits := []Split{{UID: "u1", Name: "老王", Amount: 20}, {UID: "u2", Name: "小李", Amount: 10}}
+	got, err := RescaleSplits(splits, 7.5)
+	if err != nil {
+		t.Fatalf("RescaleSplits() error = %v", err)
+	}
+	want := []Split{{UID: "u1", Name: "老王", Amount: 5}, {UID: "u2", Name: "小李", Amount: 2.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RescaleSplits() = %+v, want %+v", got, want)
+	}
+}
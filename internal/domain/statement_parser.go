@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	StatementAlipay = "alipay"
+	StatementWechat = "wechat"
+)
+
+var ErrUnknownStatement = errors.New("unknown statement format, only alipay and wechat pay csv are supported")
+
+// StatementParser 解析支付宝、微信支付导出的账单流水
+type StatementParser interface {
+	Parse(r io.Reader) (*Statement, error)
+}
+
+// Statement 解析后的账单流水，Bills 不包含记账人
+type Statement struct {
+	// Source 账单来源，如 alipay、wechat
+	Source string
+	Bills  []*Bill
+	// Skipped 不计收支、交易关闭等未转换为账单的记录数
+	Skipped int
+}
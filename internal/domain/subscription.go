@@ -0,0 +1,36 @@
+package domain
+
+import "errors"
+
+// ErrSubscriptionDisabled 未配置订阅的存储表
+var ErrSubscriptionDisabled = errors.New("subscription table not configured")
+
+const (
+	ReportDaily   = "daily"
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+)
+
+// Subscription 用户订阅的收支报告，在每个周期的最后一天 Hour 点后推送
+type Subscription struct {
+	ID  string `json:"id"`
+	UID string `json:"uid"`
+	// Channel 推送渠道，与订阅时的消息渠道一致
+	Channel string `json:"channel"`
+	// Frequency daily、weekly、monthly
+	Frequency string `json:"frequency"`
+	// Hour 推送时间，0 到 23 点
+	Hour int `json:"hour"`
+	// LastPeriod 最近一次推送的周期开始日期，格式为 yyyy-mm-dd
+	LastPeriod string `json:"last_period"`
+}
+
+type SubscriptionRepository interface {
+	// Save 保存订阅，同一用户同一频率只保留一个，并回写 it.ID
+	Save(it *Subscription) error
+	Delete(UID, frequency string) (bool, error)
+	QueryByUID(UID string) []*Subscription
+	QueryAll() []*Subscription
+	// ClaimPeriod 将 LastPeriod 更新为 period，已被更新时返回 false，避免重启或多实例重复推送
+	ClaimPeriod(it *Subscription, period string) (bool, error)
+}
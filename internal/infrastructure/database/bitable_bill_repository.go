@@ -2,11 +2,16 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	"github.com/sirupsen/logrus"
 	"github.com/wangyuheng/richman/internal/domain"
 
 	"github.com/geeklubcn/feishu-bitable-db/db"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,13 +21,25 @@ const (
 	Pay    = "支出"
 )
 
+// batchCreateLimit 多维表格单次批量新增记录上限
+const batchCreateLimit = 500
+
+// optionalBillFields 旧账本中可能不存在、首次使用时自动创建的字段
+var optionalBillFields = map[string]bool{
+	domain.BillTableAuthorID:      true,
+	domain.BillTableSplits:        true,
+	domain.BillTableCurrency:      true,
+	domain.BillTableTransactionID: true,
+}
+
 type billRepository struct {
 	db    db.DB
+	cli   *lark.Client
 	cache sync.Map
 }
 
-func NewBillRepository(db db.DB) domain.BillRepository {
-	b := &billRepository{db: db}
+func NewBillRepository(db db.DB, cli *lark.Client) domain.BillRepository {
+	b := &billRepository{db: db, cli: cli}
 	return b
 }
 
@@ -75,45 +92,79 @@ func (b *billRepository) getAuthorFieldName(appToken, tableToken string) string
 
 func (b *billRepository) Search(appToken, tableToken string, ss []db.SearchCmd) []*domain.Bill {
 	res := make([]*domain.Bill, 0)
+	err := b.Scan(appToken, tableToken, ss, func(bill *domain.Bill) error {
+		res = append(res, bill)
+		return nil
+	})
+	if err != nil {
+		return res
+	}
+	if len(res) > 0 {
+		b.cache.Store(fmt.Sprintf("remark-search-%+v", ss), res)
+	}
+	return res
+}
 
+func (b *billRepository) Scan(appToken, tableToken string, ss []db.SearchCmd, fn func(bill *domain.Bill) error) error {
 	ctx := context.Background()
-	records := b.db.Read(ctx, appToken, tableToken, ss)
-
-	for _, r := range records {
-		it := &domain.Bill{
-			Remark:   fmt.Sprintf("%s", r[domain.BillTableRemark]),
-			Expenses: fmt.Sprintf("%s", r[domain.BillTableExpenses]),
-			Month:    fmt.Sprintf("%s", r[domain.BillTableMonth]),
-		}
-		cs := make([]string, 0)
-
-		fc := r[domain.BillTableCategory]
-		if fcs, ok := fc.([]interface{}); ok {
-			for _, fc := range fcs {
-				if c, ok := fc.(string); ok {
-					cs = append(cs, c)
-				}
+	// 按旧账本中不存在的字段筛选会查询失败，先创建字段
+	for _, it := range ss {
+		if optionalBillFields[it.Key] {
+			if err := ensureTextField(ctx, b.cli, &b.cache, appToken, tableToken, it.Key); err != nil {
+				return err
 			}
-		} else if c, ok := fc.(string); ok {
-			cs = append(cs, c)
 		}
+	}
+	sort := fmt.Sprintf(`["%s ASC"]`, domain.BillTableDate)
+	return listRecords(ctx, b.cli, appToken, tableToken, ss, sort, func(r map[string]interface{}) error {
+		return fn(b.toBill(r))
+	})
+}
 
-		it.Categories = cs
-
-		if r[domain.BillTableAmount] != nil {
-			it.Amount, _ = strconv.ParseFloat(r[domain.BillTableAmount].(string), 10)
+func (b *billRepository) Page(appToken, tableToken string, ss []db.SearchCmd, offset, limit int) ([]*domain.Bill, int, error) {
+	ctx := context.Background()
+	for _, it := range ss {
+		if optionalBillFields[it.Key] {
+			if err := ensureTextField(ctx, b.cli, &b.cache, appToken, tableToken, it.Key); err != nil {
+				return nil, 0, err
+			}
 		}
+	}
+	sort := fmt.Sprintf(`["%s DESC"]`, domain.BillTableDate)
+	records, total, err := pageRecords(ctx, b.cli, appToken, tableToken, ss, sort, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	res := make([]*domain.Bill, 0, len(records))
+	for _, r := range records {
+		res = append(res, b.toBill(r))
+	}
+	return res, total, nil
+}
 
-		if r[domain.BillTableDate] != nil {
-			it.Date = int64(r[domain.BillTableDate].(float64))
-		}
+func (b *billRepository) Get(appToken, tableToken, id string) (*domain.Bill, bool) {
+	ctx := context.Background()
 
-		res = append(res, it)
+	req := larkbitable.NewGetAppTableRecordReqBuilder().
+		AppToken(appToken).
+		TableId(tableToken).
+		RecordId(id).
+		Build()
+	resp, err := b.cli.Bitable.AppTableRecord.Get(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("get record err! resp:%+v", resp)
+		return nil, false
 	}
-	if len(res) > 0 {
-		b.cache.Store(fmt.Sprintf("remark-search-%+v", ss), res)
+	if !resp.Success() || resp.Data.Record == nil {
+		logrus.WithContext(ctx).Warnf("get record fail! resp:%+v", resp)
+		return nil, false
 	}
-	return res
+	r := make(map[string]interface{}, len(resp.Data.Record.Fields)+1)
+	for k, v := range resp.Data.Record.Fields {
+		r[k] = v
+	}
+	r[db.ID] = id
+	return b.toBill(r), true
 }
 
 func (b *billRepository) Save(appToken, tableToken string, bill *domain.Bill) error {
@@ -125,24 +176,220 @@ func (b *billRepository) Save(appToken, tableToken string, bill *domain.Bill) er
 	if bill.Expenses == "" {
 		bill.Expenses = Pay
 	}
+	if err := b.ensureFields(ctx, appToken, tableToken, bill); err != nil {
+		return err
+	}
+
+	id, err := b.db.Create(ctx, appToken, tableToken, b.toRecord(appToken, tableToken, bill))
+	if err != nil {
+		b.refresh(appToken)
+		return err
+	}
+	bill.ID = id
+	return nil
+}
+
+func (b *billRepository) SaveBatch(appToken, tableToken string, bills []*domain.Bill) error {
+	ctx := context.Background()
+
+	if err := b.ensureFields(ctx, appToken, tableToken, bills...); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano() / 1e6
+	for start := 0; start < len(bills); start += batchCreateLimit {
+		end := start + batchCreateLimit
+		if end > len(bills) {
+			end = len(bills)
+		}
+		chunk := bills[start:end]
+		records := make([]*larkbitable.AppTableRecord, 0, len(chunk))
+		for _, bill := range chunk {
+			if bill.Date == 0 {
+				bill.Date = now
+			}
+			if bill.Expenses == "" {
+				bill.Expenses = Pay
+			}
+			records = append(records, larkbitable.NewAppTableRecordBuilder().
+				Fields(b.toRecord(appToken, tableToken, bill)).
+				Build())
+		}
+		req := larkbitable.NewBatchCreateAppTableRecordReqBuilder().
+			AppToken(appToken).
+			TableId(tableToken).
+			Body(larkbitable.NewBatchCreateAppTableRecordReqBodyBuilder().
+				Records(records).
+				Build()).
+			Build()
+		resp, err := b.cli.Bitable.AppTableRecord.BatchCreate(ctx, req)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("batch create record err! resp:%+v", resp)
+			b.refresh(appToken)
+			return err
+		}
+		if !resp.Success() {
+			logrus.WithContext(ctx).Errorf("batch create record fail! resp:%+v", resp)
+			b.refresh(appToken)
+			return fmt.Errorf("batch create record fail: %s", resp.Msg)
+		}
+		for i, r := range resp.Data.Records {
+			if i < len(chunk) && r.RecordId != nil {
+				chunk[i].ID = *r.RecordId
+			}
+		}
+	}
+	return nil
+}
+
+func (b *billRepository) Update(appToken, tableToken string, bill *domain.Bill) error {
+	ctx := context.Background()
 
+	if bill.ID == "" {
+		return fmt.Errorf("bill id is empty")
+	}
+	if bill.Expenses == "" {
+		bill.Expenses = Pay
+	}
+	if err := b.ensureFields(ctx, appToken, tableToken, bill); err != nil {
+		return err
+	}
+	err := b.db.Update(ctx, appToken, tableToken, bill.ID, b.toRecord(appToken, tableToken, bill))
+	if err != nil {
+		b.refresh(appToken)
+	}
+	return err
+}
+
+func (b *billRepository) Delete(appToken, tableToken, id string) error {
+	return b.db.Delete(context.Background(), appToken, tableToken, id)
+}
+
+// ensureFields 旧账本中没有交易单号、记账人ID、分摊、币种字段时，在写入前创建
+func (b *billRepository) ensureFields(ctx context.Context, appToken, tableToken string, bills ...*domain.Bill) error {
+	var transaction, author, splits, currency bool
+	for _, bill := range bills {
+		transaction = transaction || bill.TransactionID != ""
+		author = author || bill.AuthorID != ""
+		splits = splits || len(bill.Splits) > 0
+		currency = currency || bill.Currency != ""
+	}
+	if transaction {
+		if err := ensureTextField(ctx, b.cli, &b.cache, appToken, tableToken, domain.BillTableTransactionID); err != nil {
+			return err
+		}
+	}
+	if author {
+		if err := ensureTextField(ctx, b.cli, &b.cache, appToken, tableToken, domain.BillTableAuthorID); err != nil {
+			return err
+		}
+	}
+	if splits {
+		if err := ensureTextField(ctx, b.cli, &b.cache, appToken, tableToken, domain.BillTableSplits); err != nil {
+			return err
+		}
+	}
+	if currency {
+		if err := ensureTextField(ctx, b.cli, &b.cache, appToken, tableToken, domain.BillTableCurrency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *billRepository) toRecord(appToken, tableToken string, bill *domain.Bill) map[string]interface{} {
 	var categoryV interface{}
-	if b.getCategoryFieldType(appToken, tableToken) == 3 {
+	if b.getCategoryFieldType(appToken, tableToken) == 3 && len(bill.Categories) > 0 {
 		categoryV = bill.Categories[0]
 	} else {
 		categoryV = bill.Categories
 	}
 
-	_, err := b.db.Create(ctx, appToken, tableToken, map[string]interface{}{
+	record := map[string]interface{}{
 		domain.BillTableRemark:   bill.Remark,
 		domain.BillTableCategory: categoryV,
 		domain.BillTableAmount:   bill.Amount,
-		domain.BillTableDate:     bill.Date,
 		domain.BillTableExpenses: bill.Expenses,
 		domain.BillTableAuthor:   bill.AuthorName,
-	})
-	if err != nil {
-		b.refresh(appToken)
 	}
-	return err
+	if bill.Date != 0 {
+		record[domain.BillTableDate] = bill.Date
+	}
+	if bill.TransactionID != "" {
+		record[domain.BillTableTransactionID] = bill.TransactionID
+	}
+	if bill.AuthorID != "" {
+		record[domain.BillTableAuthorID] = bill.AuthorID
+	}
+	if bill.Currency != "" {
+		record[domain.BillTableCurrency] = bill.Currency
+	}
+	if len(bill.Splits) > 0 {
+		if splits, err := json.Marshal(bill.Splits); err == nil {
+			record[domain.BillTableSplits] = string(splits)
+		}
+	}
+	return record
+}
+
+// toBill 将多维表格记录转换为账单
+func (b *billRepository) toBill(r map[string]interface{}) *domain.Bill {
+	it := &domain.Bill{
+		ID:       db.GetID(r),
+		Remark:   fmt.Sprintf("%s", r[domain.BillTableRemark]),
+		Expenses: fmt.Sprintf("%s", r[domain.BillTableExpenses]),
+		Month:    fmt.Sprintf("%s", r[domain.BillTableMonth]),
+	}
+	cs := make([]string, 0)
+
+	fc := r[domain.BillTableCategory]
+	if fcs, ok := fc.([]interface{}); ok {
+		for _, fc := range fcs {
+			if c, ok := fc.(string); ok {
+				cs = append(cs, c)
+			}
+		}
+	} else if c, ok := fc.(string); ok {
+		cs = append(cs, c)
+	}
+
+	it.Categories = cs
+
+	// 金额字段可能为文本或数字类型
+	switch v := r[domain.BillTableAmount].(type) {
+	case string:
+		it.Amount, _ = strconv.ParseFloat(v, 64)
+	case float64:
+		it.Amount = v
+	}
+
+	if r[domain.BillTableDate] != nil {
+		it.Date = int64(r[domain.BillTableDate].(float64))
+	}
+	it.TransactionID = textVal(r[domain.BillTableTransactionID])
+	it.AuthorID = textVal(r[domain.BillTableAuthorID])
+	it.AuthorName = authorVal(r[domain.BillTableAuthor])
+	it.Currency = textVal(r[domain.BillTableCurrency])
+	if splits := textVal(r[domain.BillTableSplits]); splits != "" {
+		if err := json.Unmarshal([]byte(splits), &it.Splits); err != nil {
+			logrus.WithError(err).Warnf("parse bill splits fail! id:%s", it.ID)
+		}
+	}
+	return it
+}
+
+// authorVal 花钱小能手可能为文本或人员字段，人员字段取成员名称
+func authorVal(v interface{}) string {
+	if vv, ok := v.([]interface{}); ok {
+		names := make([]string, 0, len(vv))
+		for _, seg := range vv {
+			if m, ok := seg.(map[string]interface{}); ok && m["name"] != nil {
+				names = append(names, fmt.Sprintf("%v", m["name"]))
+			}
+		}
+		if len(names) > 0 {
+			return strings.Join(names, ",")
+		}
+	}
+	return textVal(v)
 }
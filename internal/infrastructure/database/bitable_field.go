@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// ensureTextField 表格中没有的字段在首次写入前创建为文本字段，如旧账本中的交易单号，结果缓存在 cache 中
+func ensureTextField(ctx context.Context, cli *lark.Client, cache *sync.Map, appToken, tableToken, name string) error {
+	key := fmt.Sprintf("field-appToken-%s-tableToken-%s-%s", appToken, tableToken, name)
+	if _, ok := cache.Load(key); ok {
+		return nil
+	}
+	var pageToken string
+	for {
+		builder := larkbitable.NewListAppTableFieldReqBuilder().
+			AppToken(appToken).
+			TableId(tableToken).
+			PageSize(100)
+		if pageToken != "" {
+			builder.PageToken(pageToken)
+		}
+		resp, err := cli.Bitable.AppTableField.List(ctx, builder.Build())
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("list fields err! resp:%+v", resp)
+			return err
+		}
+		if !resp.Success() {
+			logrus.WithContext(ctx).Errorf("list fields fail! resp:%+v", resp)
+			return fmt.Errorf("list fields fail: %s", resp.Msg)
+		}
+		for _, f := range resp.Data.Items {
+			if f.FieldName != nil && *f.FieldName == name {
+				cache.Store(key, true)
+				return nil
+			}
+		}
+		if resp.Data.HasMore == nil || !*resp.Data.HasMore || resp.Data.PageToken == nil {
+			break
+		}
+		pageToken = *resp.Data.PageToken
+	}
+
+	req := larkbitable.NewCreateAppTableFieldReqBuilder().
+		AppToken(appToken).
+		TableId(tableToken).
+		AppTableField(larkbitable.NewAppTableFieldBuilder().
+			FieldName(name).
+			Type(1).
+			Build()).
+		Build()
+	resp, err := cli.Bitable.AppTableField.Create(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("create field err! resp:%+v", resp)
+		return err
+	}
+	if !resp.Success() {
+		logrus.WithContext(ctx).Errorf("create field fail! resp:%+v", resp)
+		return fmt.Errorf("create field fail: %s", resp.Msg)
+	}
+	cache.Store(key, true)
+	return nil
+}
+
+// textVal 文本字段可能为字符串或富文本片段数组
+func textVal(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case []interface{}:
+		var s string
+		for _, seg := range vv {
+			if m, ok := seg.(map[string]interface{}); ok {
+				s += fmt.Sprintf("%v", m["text"])
+			}
+		}
+		return s
+	}
+	return ""
+}
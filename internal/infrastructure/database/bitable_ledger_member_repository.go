@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"github.com/geeklubcn/feishu-bitable-db/db"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"sort"
+	"strconv"
+)
+
+// ledgerMemberRepository 账本成员及邀请码保存在 DB_APP_TOKEN 的 MEMBER_TABLE_TOKEN、INVITE_TABLE_TOKEN 数据表中，字段均为文本
+type ledgerMemberRepository struct {
+	db               db.DB
+	dbToken          string
+	memberTableToken string
+	inviteTableToken string
+}
+
+func NewLedgerMemberRepository(cfg *config.Config, db db.DB) domain.LedgerMemberRepository {
+	return &ledgerMemberRepository{
+		db:               db,
+		dbToken:          cfg.DBAppToken,
+		memberTableToken: cfg.MemberTableToken,
+		inviteTableToken: cfg.InviteTableToken,
+	}
+}
+
+func (l *ledgerMemberRepository) enabled() bool {
+	return l.dbToken != "" && l.memberTableToken != "" && l.inviteTableToken != ""
+}
+
+func (l *ledgerMemberRepository) Save(it *domain.LedgerMember) error {
+	if !l.enabled() {
+		return domain.ErrMemberDisabled
+	}
+	fields := map[string]interface{}{
+		"ledger_id": it.LedgerID,
+		"uid":       it.UID,
+		"name":      it.Name,
+		"role":      it.Role,
+		"joined_at": strconv.FormatInt(it.JoinedAt, 10),
+		"active_at": strconv.FormatInt(it.ActiveAt, 10),
+	}
+	exists := l.read([]db.SearchCmd{
+		{Key: "ledger_id", Operator: "=", Val: it.LedgerID},
+		{Key: "uid", Operator: "=", Val: it.UID},
+	})
+	if len(exists) > 0 {
+		it.ID = exists[0].ID
+		return l.db.Update(context.Background(), l.dbToken, l.memberTableToken, it.ID, fields)
+	}
+	id, err := l.db.Create(context.Background(), l.dbToken, l.memberTableToken, fields)
+	if err != nil {
+		return err
+	}
+	it.ID = id
+	return nil
+}
+
+func (l *ledgerMemberRepository) QueryByUID(UID string) []*domain.LedgerMember {
+	res := l.read([]db.SearchCmd{{Key: "uid", Operator: "=", Val: UID}})
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].ActiveAt > res[j].ActiveAt
+	})
+	return res
+}
+
+func (l *ledgerMemberRepository) Activate(ledgerID, UID string, at int64) error {
+	if !l.enabled() {
+		return domain.ErrMemberDisabled
+	}
+	for _, it := range l.read([]db.SearchCmd{
+		{Key: "ledger_id", Operator: "=", Val: ledgerID},
+		{Key: "uid", Operator: "=", Val: UID},
+	}) {
+		return l.db.Update(context.Background(), l.dbToken, l.memberTableToken, it.ID, map[string]interface{}{
+			"active_at": strconv.FormatInt(at, 10),
+		})
+	}
+	return nil
+}
+
+func (l *ledgerMemberRepository) QueryByLedger(ledgerID string) []*domain.LedgerMember {
+	res := l.read([]db.SearchCmd{{Key: "ledger_id", Operator: "=", Val: ledgerID}})
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].JoinedAt < res[j].JoinedAt
+	})
+	return res
+}
+
+func (l *ledgerMemberRepository) SaveInvite(it *domain.LedgerInvite) error {
+	if !l.enabled() {
+		return domain.ErrMemberDisabled
+	}
+	_, err := l.db.Create(context.Background(), l.dbToken, l.inviteTableToken, map[string]interface{}{
+		"code":       it.Code,
+		"ledger_id":  it.LedgerID,
+		"role":       it.Role,
+		"inviter_id": it.InviterID,
+		"expire_at":  strconv.FormatInt(it.ExpireAt, 10),
+	})
+	return err
+}
+
+func (l *ledgerMemberRepository) QueryInvite(code string) (*domain.LedgerInvite, bool) {
+	if !l.enabled() {
+		return nil, false
+	}
+	items := l.db.Read(context.Background(), l.dbToken, l.inviteTableToken, []db.SearchCmd{{Key: "code", Operator: "=", Val: code}})
+	if len(items) == 0 {
+		return nil, false
+	}
+	expireAt, _ := strconv.ParseInt(textVal(items[0]["expire_at"]), 10, 64)
+	return &domain.LedgerInvite{
+		Code:      textVal(items[0]["code"]),
+		LedgerID:  textVal(items[0]["ledger_id"]),
+		Role:      textVal(items[0]["role"]),
+		InviterID: textVal(items[0]["inviter_id"]),
+		ExpireAt:  expireAt,
+	}, true
+}
+
+func (l *ledgerMemberRepository) read(ss []db.SearchCmd) []*domain.LedgerMember {
+	res := make([]*domain.LedgerMember, 0)
+	if !l.enabled() {
+		return res
+	}
+	for _, it := range l.db.Read(context.Background(), l.dbToken, l.memberTableToken, ss) {
+		joinedAt, _ := strconv.ParseInt(textVal(it["joined_at"]), 10, 64)
+		activeAt, _ := strconv.ParseInt(textVal(it["active_at"]), 10, 64)
+		res = append(res, &domain.LedgerMember{
+			ID:       db.GetID(it),
+			LedgerID: textVal(it["ledger_id"]),
+			UID:      textVal(it["uid"]),
+			Name:     textVal(it["name"]),
+			Role:     textVal(it["role"]),
+			JoinedAt: joinedAt,
+			ActiveAt: activeAt,
+		})
+	}
+	return res
+}
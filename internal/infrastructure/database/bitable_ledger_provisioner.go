@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	larkdrive "github.com/larksuite/oapi-sdk-go/v3/service/drive/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"time"
+)
+
+type ledgerProvisioner struct {
+	cli                  *lark.Client
+	templateAppToken     string
+	targetFolderAppToken string
+}
+
+func NewLedgerProvisioner(cfg *config.Config, cli *lark.Client) domain.LedgerProvisioner {
+	return &ledgerProvisioner{
+		cli:                  cli,
+		templateAppToken:     cfg.LarkDBConfig.TemplateAppToken,
+		targetFolderAppToken: cfg.LarkDBConfig.TargetFolderAppToken,
+	}
+}
+
+func (l *ledgerProvisioner) Provision(ctx context.Context) (*domain.Ledger, error) {
+	// 根据模板复制到指定文件夹
+	copyFile, err := l.copyFromTemplate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// copying 时调用其他接口可能导致失败
+	time.Sleep(2 * time.Second)
+	// 允许外部访问
+	err = l.setPermissionPublic(ctx, *copyFile.Token)
+	if err != nil {
+		return nil, err
+	}
+	// 获取账单表ID
+	tableID, err := l.getBillTableID(ctx, *copyFile.Token)
+	if err != nil {
+		return nil, err
+	}
+	if tableID == nil {
+		return nil, fmt.Errorf("bill table not found in %s", *copyFile.Token)
+	}
+	return &domain.Ledger{
+		AppToken:   *copyFile.Token,
+		TableToken: *tableID,
+		Name:       *copyFile.Name,
+		URL:        *copyFile.Url,
+	}, nil
+}
+
+func (l *ledgerProvisioner) copyFromTemplate(ctx context.Context) (*larkdrive.File, error) {
+	resp, err := l.cli.Drive.File.Copy(ctx, larkdrive.NewCopyFileReqBuilder().
+		FileToken(l.templateAppToken).
+		Body(larkdrive.NewCopyFileReqBodyBuilder().
+			Type("bitable").
+			FolderToken(l.targetFolderAppToken).
+			Name("飞书记账").
+			Build()).
+		Build())
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("copy file error! resp:%+v", resp)
+		return nil, err
+	}
+	if !resp.Success() {
+		logrus.WithContext(ctx).WithError(err).Errorf("copy file fail! resp:%+v", resp)
+		return nil, fmt.Errorf("copy file fail: %s", resp.Msg)
+	}
+	return resp.Data.File, nil
+}
+
+func (l *ledgerProvisioner) setPermissionPublic(ctx context.Context, appToken string) error {
+	permissionResp, err := l.cli.Drive.PermissionPublic.Patch(ctx, larkdrive.NewPatchPermissionPublicReqBuilder().
+		Token(appToken).
+		Type("bitable").
+		PermissionPublicRequest(larkdrive.NewPermissionPublicRequestBuilder().
+			ExternalAccess(true).
+			SecurityEntity("anyone_can_view").
+			CommentEntity("anyone_can_view").
+			ShareEntity("anyone").
+			LinkShareEntity("anyone_editable").
+			InviteExternal(true).
+			Build()).
+		Build())
+	if err != nil || !permissionResp.Success() {
+		logrus.WithContext(ctx).WithError(err).Errorf("patch file permission fail! resp:%+v", permissionResp)
+		return err
+	}
+	return nil
+}
+
+func (l *ledgerProvisioner) getBillTableID(ctx context.Context, appToken string) (*string, error) {
+	logger := logrus.WithContext(ctx)
+	req := larkbitable.NewListAppTableReqBuilder().
+		AppToken(appToken).
+		PageSize(20).
+		Build()
+
+	table, err := l.cli.Bitable.AppTable.List(ctx, req)
+	if err != nil {
+		logger.WithError(err).Errorf("list app table error! req: %+v, resp:%+v", req, table)
+		return nil, err
+	}
+	if !table.Success() {
+		logger.WithError(err).Errorf("list app table fail! req: %+v, resp:%+v", req, table)
+		return nil, fmt.Errorf("list app table fail: %s", table.Msg)
+	}
+	for _, t := range table.Data.Items {
+		if *t.Name == "个人账单记录" {
+			return t.TableId, nil
+		}
+	}
+	return nil, nil
+}
@@ -13,6 +13,13 @@ import (
 	"sync"
 )
 
+const (
+	// ledgerBudgetsField 账本表中保存预算 JSON 的文本字段
+	ledgerBudgetsField = "budgets"
+	// ledgerCurrencyField 账本表中保存本位币的文本字段
+	ledgerCurrencyField = "currency"
+)
+
 type ledgerRepository struct {
 	db           db.DB
 	cli          *lark.Client
@@ -34,6 +41,7 @@ func (l *ledgerRepository) WarmUP(ctx context.Context) {
 	items := l.db.Read(ctx, l.dbAppToken, l.dbTableToken, []db.SearchCmd{})
 	for _, it := range items {
 		res := &domain.Ledger{
+			ID:          db.GetID(it),
 			AppToken:    db.GetString(it, "app_token"),
 			TableToken:  db.GetString(it, "table_token"),
 			Name:        db.GetString(it, "name"),
@@ -41,7 +49,10 @@ func (l *ledgerRepository) WarmUP(ctx context.Context) {
 			CreatorID:   db.GetString(it, "creator_id"),
 			CreatorName: db.GetString(it, "creator_name"),
 		}
-		l.cache.Store(fmt.Sprintf("REPO:LEDGER:%s", db.GetString(it, res.CreatorID)), res)
+		res.Budgets = parseBudgets(textVal(it[ledgerBudgetsField]))
+		res.Currency = textVal(it[ledgerCurrencyField])
+		l.cache.Store(fmt.Sprintf("REPO:LEDGER:%s", res.CreatorID), res)
+		l.cache.Store(fmt.Sprintf("REPO:LEDGER:ID:%s", res.ID), res)
 	}
 }
 
@@ -71,6 +82,91 @@ func (l *ledgerRepository) UpdateUser(id string, user domain.User) error {
 	return nil
 }
 
+func (l *ledgerRepository) UpdateName(id, name string) error {
+	ctx := context.Background()
+
+	req := larkbitable.NewUpdateAppTableRecordReqBuilder().
+		AppToken(l.dbAppToken).
+		TableId(l.dbTableToken).
+		RecordId(id).
+		AppTableRecord(larkbitable.NewAppTableRecordBuilder().
+			Fields(map[string]interface{}{
+				"name": name,
+			}).
+			Build()).
+		Build()
+	resp, err := l.cli.Bitable.AppTableRecord.Update(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("update name err! resp:%+v", resp)
+		return err
+	}
+	if !resp.Success() {
+		logrus.WithContext(ctx).Errorf("update name fail! resp:%+v", resp)
+		return fmt.Errorf("update name fail: %s", resp.Msg)
+	}
+	return nil
+}
+
+func (l *ledgerRepository) UpdateBudgets(it *domain.Ledger) error {
+	ctx := context.Background()
+
+	if err := ensureTextField(ctx, l.cli, &l.cache, l.dbAppToken, l.dbTableToken, ledgerBudgetsField); err != nil {
+		return err
+	}
+	budgets, err := json.Marshal(it.Budgets)
+	if err != nil {
+		return err
+	}
+	req := larkbitable.NewUpdateAppTableRecordReqBuilder().
+		AppToken(l.dbAppToken).
+		TableId(l.dbTableToken).
+		RecordId(it.ID).
+		AppTableRecord(larkbitable.NewAppTableRecordBuilder().
+			Fields(map[string]interface{}{
+				ledgerBudgetsField: string(budgets),
+			}).
+			Build()).
+		Build()
+	resp, err := l.cli.Bitable.AppTableRecord.Update(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("update budgets err! resp:%+v", resp)
+		return err
+	}
+	if !resp.Success() {
+		logrus.WithContext(ctx).Errorf("update budgets fail! resp:%+v", resp)
+		return fmt.Errorf("update budgets fail: %s", resp.Msg)
+	}
+	return nil
+}
+
+func (l *ledgerRepository) UpdateCurrency(it *domain.Ledger) error {
+	ctx := context.Background()
+
+	if err := ensureTextField(ctx, l.cli, &l.cache, l.dbAppToken, l.dbTableToken, ledgerCurrencyField); err != nil {
+		return err
+	}
+	req := larkbitable.NewUpdateAppTableRecordReqBuilder().
+		AppToken(l.dbAppToken).
+		TableId(l.dbTableToken).
+		RecordId(it.ID).
+		AppTableRecord(larkbitable.NewAppTableRecordBuilder().
+			Fields(map[string]interface{}{
+				ledgerCurrencyField: it.Currency,
+			}).
+			Build()).
+		Build()
+	resp, err := l.cli.Bitable.AppTableRecord.Update(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("update currency err! resp:%+v", resp)
+		return err
+	}
+	if !resp.Success() {
+		logrus.WithContext(ctx).Errorf("update currency fail! resp:%+v", resp)
+		return fmt.Errorf("update currency fail: %s", resp.Msg)
+	}
+	return nil
+}
+
 func (l *ledgerRepository) QueryUnallocated() []*domain.Ledger {
 	ctx := context.Background()
 
@@ -109,7 +205,46 @@ func (l *ledgerRepository) QueryUnallocated() []*domain.Ledger {
 	return ledgers
 }
 
-func (l *ledgerRepository) QueryByUID(UID string) (*domain.Ledger, bool) {
+func (l *ledgerRepository) QueryByID(id string) (*domain.Ledger, bool) {
+	ctx := context.Background()
+
+	if v, ok := l.cache.Load(fmt.Sprintf("REPO:LEDGER:ID:%s", id)); ok {
+		if vv, ok := v.(*domain.Ledger); ok {
+			return vv, true
+		}
+	}
+
+	req := larkbitable.NewGetAppTableRecordReqBuilder().
+		AppToken(l.dbAppToken).
+		TableId(l.dbTableToken).
+		RecordId(id).
+		Build()
+	resp, err := l.cli.Bitable.AppTableRecord.Get(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("get record err! resp:%+v", resp)
+		return nil, false
+	}
+	if !resp.Success() || resp.Data.Record == nil {
+		logrus.WithContext(ctx).Errorf("get record fail! resp:%+v", resp)
+		return nil, false
+	}
+	var ledger domain.Ledger
+	j, err := json.Marshal(resp.Data.Record.Fields)
+	if err != nil {
+		return nil, false
+	}
+	if err = json.Unmarshal(j, &ledger); err != nil {
+		return nil, false
+	}
+	ledger.ID = id
+	ledger.Budgets = parseBudgets(textVal(resp.Data.Record.Fields[ledgerBudgetsField]))
+	ledger.Currency = textVal(resp.Data.Record.Fields[ledgerCurrencyField])
+	l.cache.Store(fmt.Sprintf("REPO:LEDGER:ID:%s", id), &ledger)
+
+	return &ledger, true
+}
+
+func (l *ledgerRepository) QueryByCreator(UID string) (*domain.Ledger, bool) {
 	ctx := context.Background()
 
 	if v, ok := l.cache.Load(fmt.Sprintf("REPO:LEDGER:%s", UID)); ok {
@@ -145,6 +280,8 @@ func (l *ledgerRepository) QueryByUID(UID string) (*domain.Ledger, bool) {
 		return nil, false
 	}
 	ledger.ID = *resp.Data.Items[0].RecordId
+	ledger.Budgets = parseBudgets(textVal(resp.Data.Items[0].Fields[ledgerBudgetsField]))
+	ledger.Currency = textVal(resp.Data.Items[0].Fields[ledgerCurrencyField])
 	l.cache.Store(fmt.Sprintf("REPO:LEDGER:%s", UID), &ledger)
 
 	return &ledger, true
@@ -189,3 +326,14 @@ func structToMap(input interface{}) (map[string]interface{}, error) {
 	delete(result, "id")
 	return result, nil
 }
+
+func parseBudgets(v string) []domain.Budget {
+	budgets := make([]domain.Budget, 0)
+	if v == "" {
+		return budgets
+	}
+	if err := json.Unmarshal([]byte(v), &budgets); err != nil {
+		logrus.WithError(err).Warnf("parse budgets fail! budgets:%s", v)
+	}
+	return budgets
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/geeklubcn/feishu-bitable-db/db"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// listRecordsPageSize 多维表格单次查询记录上限
+const listRecordsPageSize = 500
+
+// listRecords 按条件逐页查询记录并回调 fn，sort 为空时不排序，如 ["日期 ASC"]。
+// db.Read 只返回第一页，记录较多时使用该方法
+func listRecords(ctx context.Context, cli *lark.Client, appToken, tableToken string, ss []db.SearchCmd, sort string, fn func(r map[string]interface{}) error) error {
+	return listRecordPages(ctx, cli, appToken, tableToken, ss, sort, listRecordsPageSize, func(records []map[string]interface{}, total int) (bool, error) {
+		for _, r := range records {
+			if err := fn(r); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+}
+
+// pageRecords 跳过前 offset 条记录后返回最多 limit 条，以及符合条件的记录总数。
+// 多维表格只支持 page_token 翻页，读取到 offset+limit 条后即停止，不会遍历整张表
+func pageRecords(ctx context.Context, cli *lark.Client, appToken, tableToken string, ss []db.SearchCmd, sort string, offset, limit int) ([]map[string]interface{}, int, error) {
+	res := make([]map[string]interface{}, 0, limit)
+	total, skipped := 0, 0
+	pageSize := offset + limit
+	if pageSize > listRecordsPageSize {
+		pageSize = listRecordsPageSize
+	}
+	err := listRecordPages(ctx, cli, appToken, tableToken, ss, sort, pageSize, func(records []map[string]interface{}, n int) (bool, error) {
+		total = n
+		for _, r := range records {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(res) == limit {
+				return false, nil
+			}
+			res = append(res, r)
+		}
+		return len(res) < limit, nil
+	})
+	return res, total, err
+}
+
+// listRecordPages 逐页查询记录，fn 返回 false 时停止翻页，total 为符合条件的记录总数
+func listRecordPages(ctx context.Context, cli *lark.Client, appToken, tableToken string, ss []db.SearchCmd, sort string, pageSize int, fn func(records []map[string]interface{}, total int) (bool, error)) error {
+	var pageToken string
+	for {
+		builder := larkbitable.NewListAppTableRecordReqBuilder().
+			AppToken(appToken).
+			TableId(tableToken).
+			PageSize(pageSize)
+		if filter := recordFilter(ss); filter != "" {
+			builder.Filter(filter)
+		}
+		if sort != "" {
+			builder.Sort(sort)
+		}
+		if pageToken != "" {
+			builder.PageToken(pageToken)
+		}
+		resp, err := cli.Bitable.AppTableRecord.List(ctx, builder.Build())
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("list records err! resp:%+v", resp)
+			return err
+		}
+		if !resp.Success() {
+			logrus.WithContext(ctx).Errorf("list records fail! resp:%+v", resp)
+			return fmt.Errorf("list records fail: %s", resp.Msg)
+		}
+		records := make([]map[string]interface{}, 0, len(resp.Data.Items))
+		for _, it := range resp.Data.Items {
+			r := make(map[string]interface{}, len(it.Fields)+1)
+			for k, v := range it.Fields {
+				r[k] = v
+			}
+			if it.RecordId != nil {
+				r[db.ID] = *it.RecordId
+			}
+			records = append(records, r)
+		}
+		total := 0
+		if resp.Data.Total != nil {
+			total = *resp.Data.Total
+		}
+		more, err := fn(records, total)
+		if err != nil {
+			return err
+		}
+		if !more || resp.Data.HasMore == nil || !*resp.Data.HasMore || resp.Data.PageToken == nil {
+			return nil
+		}
+		pageToken = *resp.Data.PageToken
+	}
+}
+
+// recordFilter 与 db.Read 相同的筛选公式
+func recordFilter(ss []db.SearchCmd) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	filters := make([]string, 0, len(ss))
+	for _, s := range ss {
+		switch v := s.Val.(type) {
+		case string:
+			filters = append(filters, fmt.Sprintf("CurrentValue.[%s]%s\"%s\"", s.Key, s.Operator, v))
+		case int, int8, int16, int32, int64:
+			filters = append(filters, fmt.Sprintf("CurrentValue.[%s]%s%d", s.Key, s.Operator, v))
+		default:
+			filters = append(filters, fmt.Sprintf("CurrentValue.[%s]%s%s", s.Key, s.Operator, v))
+		}
+	}
+	return "AND(" + strings.Join(filters, ",") + ")"
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/geeklubcn/feishu-bitable-db/db"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"sort"
+	"strconv"
+)
+
+// recurringBillRepository 周期账单规则保存在 RECURRING_DB_TOKEN 的数据表中，字段均为文本
+type recurringBillRepository struct {
+	db         db.DB
+	cli        *lark.Client
+	dbToken    string
+	tableToken string
+}
+
+func NewRecurringBillRepository(cfg *config.Config, cli *lark.Client, db db.DB) domain.RecurringBillRepository {
+	return &recurringBillRepository{
+		db:         db,
+		cli:        cli,
+		dbToken:    cfg.RecurringDBToken,
+		tableToken: cfg.RecurringTableToken,
+	}
+}
+
+func (r *recurringBillRepository) enabled() bool {
+	return r.dbToken != "" && r.tableToken != ""
+}
+
+func (r *recurringBillRepository) Save(it *domain.RecurringBill) error {
+	if !r.enabled() {
+		return domain.ErrRecurringDisabled
+	}
+	id, err := r.db.Create(context.Background(), r.dbToken, r.tableToken, map[string]interface{}{
+		"app_token":   it.AppToken,
+		"table_token": it.TableToken,
+		"day":         strconv.Itoa(it.Day),
+		"remark":      it.Remark,
+		"category":    it.Category,
+		"amount":      strconv.FormatFloat(it.Amount, 'f', -1, 64),
+		"expenses":    it.Expenses,
+		"author_id":   it.AuthorID,
+		"author_name": it.AuthorName,
+		"next_period": it.NextPeriod,
+	})
+	if err != nil {
+		return err
+	}
+	it.ID = id
+	return nil
+}
+
+// ClaimNextPeriod 多维表格不支持条件更新，读取最新的周期一致后再更新，仍有很小的并发窗口，
+// 由记账前按交易单号检查兜底
+func (r *recurringBillRepository) ClaimNextPeriod(it *domain.RecurringBill, next string) (bool, error) {
+	if !r.enabled() {
+		return false, domain.ErrRecurringDisabled
+	}
+	ctx := context.Background()
+	req := larkbitable.NewGetAppTableRecordReqBuilder().
+		AppToken(r.dbToken).
+		TableId(r.tableToken).
+		RecordId(it.ID).
+		Build()
+	resp, err := r.cli.Bitable.AppTableRecord.Get(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("get recurring bill err! resp:%+v", resp)
+		return false, err
+	}
+	if !resp.Success() || resp.Data.Record == nil {
+		logrus.WithContext(ctx).Errorf("get recurring bill fail! resp:%+v", resp)
+		return false, fmt.Errorf("get recurring bill fail: %s", resp.Msg)
+	}
+	if textVal(resp.Data.Record.Fields["next_period"]) != it.NextPeriod {
+		return false, nil
+	}
+	err = r.db.Update(ctx, r.dbToken, r.tableToken, it.ID, map[string]interface{}{
+		"next_period": next,
+	})
+	if err != nil {
+		return false, err
+	}
+	it.NextPeriod = next
+	return true, nil
+}
+
+func (r *recurringBillRepository) Delete(id string) error {
+	if !r.enabled() {
+		return domain.ErrRecurringDisabled
+	}
+	return r.db.Delete(context.Background(), r.dbToken, r.tableToken, id)
+}
+
+func (r *recurringBillRepository) QueryByLedger(appToken, tableToken string) []*domain.RecurringBill {
+	res := r.read([]db.SearchCmd{
+		{Key: "app_token", Operator: "=", Val: appToken},
+		{Key: "table_token", Operator: "=", Val: tableToken},
+	})
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Day < res[j].Day
+	})
+	return res
+}
+
+func (r *recurringBillRepository) QueryAll() []*domain.RecurringBill {
+	return r.read([]db.SearchCmd{})
+}
+
+func (r *recurringBillRepository) read(ss []db.SearchCmd) []*domain.RecurringBill {
+	res := make([]*domain.RecurringBill, 0)
+	if !r.enabled() {
+		return res
+	}
+	for _, it := range r.db.Read(context.Background(), r.dbToken, r.tableToken, ss) {
+		day, _ := strconv.Atoi(textVal(it["day"]))
+		amount, _ := strconv.ParseFloat(textVal(it["amount"]), 64)
+		res = append(res, &domain.RecurringBill{
+			ID:         db.GetID(it),
+			AppToken:   textVal(it["app_token"]),
+			TableToken: textVal(it["table_token"]),
+			Day:        day,
+			Remark:     textVal(it["remark"]),
+			Category:   textVal(it["category"]),
+			Amount:     amount,
+			Expenses:   textVal(it["expenses"]),
+			AuthorID:   textVal(it["author_id"]),
+			AuthorName: textVal(it["author_name"]),
+			NextPeriod: textVal(it["next_period"]),
+		})
+	}
+	return res
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"github.com/geeklubcn/feishu-bitable-db/db"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"strconv"
+)
+
+// reminderRepository 记账提醒设置保存在 SUBSCRIPTION_DB_TOKEN 的 REMINDER_TABLE_TOKEN 数据表中，字段均为文本
+type reminderRepository struct {
+	db         db.DB
+	dbToken    string
+	tableToken string
+}
+
+func NewReminderRepository(cfg *config.Config, db db.DB) domain.ReminderRepository {
+	return &reminderRepository{
+		db:         db,
+		dbToken:    cfg.SubscriptionDBToken,
+		tableToken: cfg.ReminderTableToken,
+	}
+}
+
+func (r *reminderRepository) enabled() bool {
+	return r.dbToken != "" && r.tableToken != ""
+}
+
+func (r *reminderRepository) Touch(UID, channel string) error {
+	if !r.enabled() {
+		return domain.ErrReminderDisabled
+	}
+	if _, ok := r.get(UID); ok {
+		return nil
+	}
+	return r.create(&domain.Reminder{UID: UID, Channel: channel})
+}
+
+func (r *reminderRepository) SetDisabled(UID, channel string, disabled bool) error {
+	if !r.enabled() {
+		return domain.ErrReminderDisabled
+	}
+	it, ok := r.get(UID)
+	if !ok {
+		return r.create(&domain.Reminder{UID: UID, Channel: channel, Disabled: disabled})
+	}
+	return r.db.Update(context.Background(), r.dbToken, r.tableToken, it.id, map[string]interface{}{
+		"disabled": strconv.FormatBool(disabled),
+	})
+}
+
+func (r *reminderRepository) QueryEnabled() []*domain.Reminder {
+	res := make([]*domain.Reminder, 0)
+	for _, it := range r.read([]db.SearchCmd{}) {
+		if !it.Disabled {
+			res = append(res, &it.Reminder)
+		}
+	}
+	return res
+}
+
+// ClaimRemind 多维表格不支持条件更新，更新前重新读取最近提醒时间，尽量避免重复提醒
+func (r *reminderRepository) ClaimRemind(it *domain.Reminder, at int64) (bool, error) {
+	if !r.enabled() {
+		return false, domain.ErrReminderDisabled
+	}
+	latest, ok := r.get(it.UID)
+	if !ok || latest.LastRemindAt != it.LastRemindAt {
+		return false, nil
+	}
+	if err := r.db.Update(context.Background(), r.dbToken, r.tableToken, latest.id, map[string]interface{}{
+		"last_remind_at": strconv.FormatInt(at, 10),
+	}); err != nil {
+		return false, err
+	}
+	it.LastRemindAt = at
+	return true, nil
+}
+
+// reminderRecord 提醒设置及其记录 ID
+type reminderRecord struct {
+	domain.Reminder
+	id string
+}
+
+func (r *reminderRepository) create(it *domain.Reminder) error {
+	_, err := r.db.Create(context.Background(), r.dbToken, r.tableToken, map[string]interface{}{
+		"uid":            it.UID,
+		"channel":        it.Channel,
+		"disabled":       strconv.FormatBool(it.Disabled),
+		"last_remind_at": strconv.FormatInt(it.LastRemindAt, 10),
+	})
+	return err
+}
+
+func (r *reminderRepository) get(UID string) (*reminderRecord, bool) {
+	res := r.read([]db.SearchCmd{{Key: "uid", Operator: "=", Val: UID}})
+	if len(res) == 0 {
+		return nil, false
+	}
+	return res[0], true
+}
+
+func (r *reminderRepository) read(ss []db.SearchCmd) []*reminderRecord {
+	res := make([]*reminderRecord, 0)
+	if !r.enabled() {
+		return res
+	}
+	for _, it := range r.db.Read(context.Background(), r.dbToken, r.tableToken, ss) {
+		disabled, _ := strconv.ParseBool(textVal(it["disabled"]))
+		lastRemindAt, _ := strconv.ParseInt(textVal(it["last_remind_at"]), 10, 64)
+		res = append(res, &reminderRecord{
+			Reminder: domain.Reminder{
+				UID:          textVal(it["uid"]),
+				Channel:      textVal(it["channel"]),
+				Disabled:     disabled,
+				LastRemindAt: lastRemindAt,
+			},
+			id: db.GetID(it),
+		})
+	}
+	return res
+}
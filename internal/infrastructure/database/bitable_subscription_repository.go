@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"github.com/geeklubcn/feishu-bitable-db/db"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"strconv"
+)
+
+// subscriptionRepository 报告订阅保存在 SUBSCRIPTION_DB_TOKEN 的数据表中，字段均为文本
+type subscriptionRepository struct {
+	db         db.DB
+	dbToken    string
+	tableToken string
+}
+
+func NewSubscriptionRepository(cfg *config.Config, db db.DB) domain.SubscriptionRepository {
+	return &subscriptionRepository{
+		db:         db,
+		dbToken:    cfg.SubscriptionDBToken,
+		tableToken: cfg.SubscriptionTableToken,
+	}
+}
+
+func (s *subscriptionRepository) enabled() bool {
+	return s.dbToken != "" && s.tableToken != ""
+}
+
+func (s *subscriptionRepository) Save(it *domain.Subscription) error {
+	if !s.enabled() {
+		return domain.ErrSubscriptionDisabled
+	}
+	ctx := context.Background()
+	record := map[string]interface{}{
+		"uid":       it.UID,
+		"channel":   it.Channel,
+		"frequency": it.Frequency,
+		"hour":      strconv.Itoa(it.Hour),
+	}
+	if exists, ok := s.get(it.UID, it.Frequency); ok {
+		it.ID = exists.ID
+		it.LastPeriod = exists.LastPeriod
+		return s.db.Update(ctx, s.dbToken, s.tableToken, it.ID, record)
+	}
+	record["last_period"] = it.LastPeriod
+	id, err := s.db.Create(ctx, s.dbToken, s.tableToken, record)
+	if err != nil {
+		return err
+	}
+	it.ID = id
+	return nil
+}
+
+func (s *subscriptionRepository) Delete(UID, frequency string) (bool, error) {
+	if !s.enabled() {
+		return false, domain.ErrSubscriptionDisabled
+	}
+	it, ok := s.get(UID, frequency)
+	if !ok {
+		return false, nil
+	}
+	return true, s.db.Delete(context.Background(), s.dbToken, s.tableToken, it.ID)
+}
+
+func (s *subscriptionRepository) QueryByUID(UID string) []*domain.Subscription {
+	return s.read([]db.SearchCmd{{Key: "uid", Operator: "=", Val: UID}})
+}
+
+func (s *subscriptionRepository) QueryAll() []*domain.Subscription {
+	return s.read([]db.SearchCmd{})
+}
+
+// ClaimPeriod 多维表格不支持条件更新，更新前重新读取最新的推送周期，尽量避免重复推送
+func (s *subscriptionRepository) ClaimPeriod(it *domain.Subscription, period string) (bool, error) {
+	if !s.enabled() {
+		return false, domain.ErrSubscriptionDisabled
+	}
+	latest, ok := s.get(it.UID, it.Frequency)
+	if !ok || latest.LastPeriod == period {
+		return false, nil
+	}
+	if err := s.db.Update(context.Background(), s.dbToken, s.tableToken, it.ID, map[string]interface{}{
+		"last_period": period,
+	}); err != nil {
+		return false, err
+	}
+	it.LastPeriod = period
+	return true, nil
+}
+
+func (s *subscriptionRepository) get(UID, frequency string) (*domain.Subscription, bool) {
+	res := s.read([]db.SearchCmd{
+		{Key: "uid", Operator: "=", Val: UID},
+		{Key: "frequency", Operator: "=", Val: frequency},
+	})
+	if len(res) == 0 {
+		return nil, false
+	}
+	return res[0], true
+}
+
+func (s *subscriptionRepository) read(ss []db.SearchCmd) []*domain.Subscription {
+	res := make([]*domain.Subscription, 0)
+	if !s.enabled() {
+		return res
+	}
+	for _, it := range s.db.Read(context.Background(), s.dbToken, s.tableToken, ss) {
+		hour, _ := strconv.Atoi(textVal(it["hour"]))
+		res = append(res, &domain.Subscription{
+			ID:         db.GetID(it),
+			UID:        textVal(it["uid"]),
+			Channel:    textVal(it["channel"]),
+			Frequency:  textVal(it["frequency"]),
+			Hour:       hour,
+			LastPeriod: textVal(it["last_period"]),
+		})
+	}
+	return res
+}
@@ -47,7 +47,7 @@ func (u *userRepository) GetByID(UID string) (*domain.User, bool) {
 	}
 
 	rs := u.db.Read(ctx, userDatabase, userTable, []db.SearchCmd{
-		{userUid, "=", UID},
+		{Key: userUid, Operator: "=", Val: UID},
 	})
 	if len(rs) == 0 {
 		return nil, false
@@ -64,7 +64,7 @@ func (u *userRepository) Save(it *domain.User) (string, error) {
 	ctx := context.Background()
 
 	for _, r := range u.db.Read(ctx, userDatabase, userTable, []db.SearchCmd{
-		{userUid, "=", it.UID},
+		{Key: userUid, Operator: "=", Val: it.UID},
 	}) {
 		_ = u.db.Delete(ctx, userDatabase, userTable, db.GetID(r))
 	}
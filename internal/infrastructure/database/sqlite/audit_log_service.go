@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type auditLogService struct {
+	db  *sql.DB
+	buf chan domain.AuditLog
+}
+
+func NewAuditLogService(sdb *sql.DB) domain.AuditLogService {
+	s := &auditLogService{
+		db:  sdb,
+		buf: make(chan domain.AuditLog, 100),
+	}
+	s.StartConsume()
+	return s
+}
+
+func (a *auditLogService) Send(log domain.AuditLog) {
+	a.buf <- log
+}
+
+func (a *auditLogService) StartConsume() {
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				logrus.Errorf("consume fail! err: %v", e)
+			}
+		}()
+		for data := range a.buf {
+			_, err := a.db.Exec(`INSERT INTO audit_logs (key, operator, req, resp, created_at) VALUES (?, ?, ?, ?, ?)`,
+				data.Key, data.Operator, data.Req, data.Resp, time.Now().UnixNano()/1e6)
+			if err != nil {
+				logrus.WithError(err).Error("insert audit log fail!")
+			}
+		}
+	}()
+}
@@ -0,0 +1,346 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geeklubcn/feishu-bitable-db/db"
+	"github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+const (
+	Pay = "支出"
+)
+
+// billColumns 账单表字段名与本地列名的映射
+var billColumns = map[string]string{
+	domain.BillTableRemark:        "remark",
+	domain.BillTableCategory:      "categories",
+	domain.BillTableAmount:        "amount",
+	domain.BillTableDate:          "date",
+	domain.BillTableMonth:         "month",
+	domain.BillTableExpenses:      "expenses",
+	domain.BillTableAuthor:        "author_name",
+	domain.BillTableAuthorID:      "author_id",
+	domain.BillTableTransactionID: "transaction_id",
+}
+
+var operators = map[string]bool{
+	"=":  true,
+	"!=": true,
+	">":  true,
+	">=": true,
+	"<":  true,
+	"<=": true,
+}
+
+const billSelectColumns = "id, remark, categories, amount, month, date, expenses, author_id, author_name, transaction_id, splits, currency"
+
+type billRepository struct {
+	db *sql.DB
+}
+
+func NewBillRepository(sdb *sql.DB) domain.BillRepository {
+	return &billRepository{db: sdb}
+}
+
+func (b *billRepository) Save(appToken, tableToken string, bill *domain.Bill) error {
+	ctx := context.Background()
+	b.fillDefault(bill, time.Now())
+
+	res, err := b.db.ExecContext(ctx, `INSERT INTO bills
+		(app_token, table_token, remark, categories, amount, month, date, expenses, author_id, author_name, transaction_id, splits, currency)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		appToken, tableToken, bill.Remark, marshalCategories(bill), bill.Amount, bill.Month, bill.Date,
+		bill.Expenses, bill.AuthorID, bill.AuthorName, bill.TransactionID, marshalSplits(bill), bill.Currency)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("insert bill fail! bill:%+v", bill)
+		return duplicated(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	bill.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (b *billRepository) SaveBatch(appToken, tableToken string, bills []*domain.Bill) error {
+	ctx := context.Background()
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO bills
+		(app_token, table_token, remark, categories, amount, month, date, expenses, author_id, author_name, transaction_id, splits, currency)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	ids := make([]string, 0, len(bills))
+	for _, bill := range bills {
+		b.fillDefault(bill, now)
+		res, err := stmt.ExecContext(ctx, appToken, tableToken, bill.Remark, marshalCategories(bill), bill.Amount,
+			bill.Month, bill.Date, bill.Expenses, bill.AuthorID, bill.AuthorName, bill.TransactionID, marshalSplits(bill), bill.Currency)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("batch insert bill fail! bill:%+v", bill)
+			_ = tx.Rollback()
+			return duplicated(err)
+		}
+		id, _ := res.LastInsertId()
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for i, bill := range bills {
+		bill.ID = ids[i]
+	}
+	return nil
+}
+
+func (b *billRepository) Search(appToken, tableToken string, ss []db.SearchCmd) []*domain.Bill {
+	res := make([]*domain.Bill, 0)
+	_ = b.Scan(appToken, tableToken, ss, func(bill *domain.Bill) error {
+		res = append(res, bill)
+		return nil
+	})
+	return res
+}
+
+// Scan 按 (date, id) 分页查询，每页读取完成后再回调，避免导出较慢时长时间占用唯一的连接
+func (b *billRepository) Scan(appToken, tableToken string, ss []db.SearchCmd, fn func(bill *domain.Bill) error) error {
+	ctx := context.Background()
+
+	where, args, err := b.where(ctx, appToken, tableToken, ss)
+	if err != nil {
+		return err
+	}
+	var lastDate, lastID int64
+	for first := true; ; first = false {
+		pageWhere, pageArgs := where, args
+		if !first {
+			pageWhere = append(where[:len(where):len(where)], "(date > ? OR (date = ? AND id > ?))")
+			pageArgs = append(args[:len(args):len(args)], lastDate, lastDate, lastID)
+		}
+		page, n, err := b.query(ctx, pageWhere, pageArgs, "date, id", scanPageSize, 0)
+		if err != nil {
+			return err
+		}
+		for _, it := range page {
+			if err = fn(it); err != nil {
+				return err
+			}
+		}
+		if n < scanPageSize || len(page) == 0 {
+			return nil
+		}
+		last := page[len(page)-1]
+		lastDate = last.Date
+		lastID, _ = strconv.ParseInt(last.ID, 10, 64)
+	}
+}
+
+// scanPageSize Scan 每页查询的账单数
+const scanPageSize = 500
+
+func (b *billRepository) Page(appToken, tableToken string, ss []db.SearchCmd, offset, limit int) ([]*domain.Bill, int, error) {
+	ctx := context.Background()
+
+	where, args, err := b.where(ctx, appToken, tableToken, ss)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	if err = b.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM bills WHERE `+strings.Join(where, " AND "), args...).Scan(&total); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("count bills fail!")
+		return nil, 0, err
+	}
+	bills, _, err := b.query(ctx, where, args, "date DESC, id DESC", limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bills, total, nil
+}
+
+// where 账本及查询条件对应的 WHERE 子句及参数
+func (b *billRepository) where(ctx context.Context, appToken, tableToken string, ss []db.SearchCmd) ([]string, []interface{}, error) {
+	where := []string{"app_token = ?", "table_token = ?"}
+	args := []interface{}{appToken, tableToken}
+	for _, s := range ss {
+		cond, arg, err := b.condition(s)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("unsupported search cmd! cmd:%+v", s)
+			return nil, nil, err
+		}
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	return where, args, nil
+}
+
+// query 按 order 排序查询一页账单，返回账单及读取的行数，解析失败的行不返回
+func (b *billRepository) query(ctx context.Context, where []string, args []interface{}, order string, limit, offset int) ([]*domain.Bill, int, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT `+billSelectColumns+`
+		FROM bills WHERE `+strings.Join(where, " AND ")+` ORDER BY `+order+` LIMIT ? OFFSET ?`, append(args[:len(args):len(args)], limit, offset)...)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("search bills fail!")
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res := make([]*domain.Bill, 0, limit)
+	n := 0
+	for rows.Next() {
+		n++
+		it, err := scanBill(rows)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("scan bill fail!")
+			continue
+		}
+		res = append(res, it)
+	}
+	return res, n, rows.Err()
+}
+
+func (b *billRepository) Get(appToken, tableToken, id string) (*domain.Bill, bool) {
+	ctx := context.Background()
+
+	row := b.db.QueryRowContext(ctx, `SELECT `+billSelectColumns+`
+		FROM bills WHERE id = ? AND app_token = ? AND table_token = ?`, id, appToken, tableToken)
+	it, err := scanBill(row)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.WithContext(ctx).WithError(err).Errorf("get bill fail! id:%s", id)
+		}
+		return nil, false
+	}
+	return it, true
+}
+
+func (b *billRepository) Update(appToken, tableToken string, bill *domain.Bill) error {
+	ctx := context.Background()
+
+	if bill.ID == "" {
+		return fmt.Errorf("bill id is empty")
+	}
+	b.fillDefault(bill, time.Now())
+	_, err := b.db.ExecContext(ctx, `UPDATE bills SET
+		remark = ?, categories = ?, amount = ?, month = ?, date = ?, expenses = ?, author_id = ?, author_name = ?, transaction_id = ?, splits = ?, currency = ?
+		WHERE id = ? AND app_token = ? AND table_token = ?`,
+		bill.Remark, marshalCategories(bill), bill.Amount, bill.Month, bill.Date, bill.Expenses,
+		bill.AuthorID, bill.AuthorName, bill.TransactionID, marshalSplits(bill), bill.Currency, bill.ID, appToken, tableToken)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("update bill fail! bill:%+v", bill)
+		return duplicated(err)
+	}
+	return nil
+}
+
+func (b *billRepository) Delete(appToken, tableToken, id string) error {
+	_, err := b.db.Exec(`DELETE FROM bills WHERE id = ? AND app_token = ? AND table_token = ?`, id, appToken, tableToken)
+	return err
+}
+
+// duplicated 交易单号唯一索引冲突时返回 domain.ErrDuplicateBill
+func duplicated(err error) error {
+	var e sqlite3.Error
+	if errors.As(err, &e) && e.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return domain.ErrDuplicateBill
+	}
+	return err
+}
+
+// fillDefault 补全多维表格中由公式或默认值生成的字段
+func (b *billRepository) fillDefault(bill *domain.Bill, now time.Time) {
+	if bill.Date == 0 {
+		bill.Date = now.UnixNano() / 1e6
+	}
+	if bill.Expenses == "" {
+		bill.Expenses = Pay
+	}
+	bill.Month = fmt.Sprintf("%d 月", time.Unix(0, bill.Date*1e6).Month())
+}
+
+func (b *billRepository) condition(s db.SearchCmd) (string, interface{}, error) {
+	column, ok := billColumns[s.Key]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown field %s", s.Key)
+	}
+	if !operators[s.Operator] {
+		return "", nil, fmt.Errorf("unknown operator %s", s.Operator)
+	}
+	val := s.Val
+	if d, ok := s.Val.(domain.DateVal); ok {
+		t := time.Time(d)
+		val = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).UnixNano() / 1e6
+	}
+	// 分类为多选字段，等于任一分类即匹配
+	if column == "categories" && s.Operator == "=" {
+		return "EXISTS (SELECT 1 FROM json_each(categories) WHERE value = ?)", fmt.Sprint(val), nil
+	}
+	return fmt.Sprintf("%s %s ?", column, s.Operator), val, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBill(row scanner) (*domain.Bill, error) {
+	var id int64
+	var categories, splits string
+	it := &domain.Bill{}
+	if err := row.Scan(&id, &it.Remark, &categories, &it.Amount, &it.Month, &it.Date, &it.Expenses, &it.AuthorID, &it.AuthorName, &it.TransactionID, &splits, &it.Currency); err != nil {
+		return nil, err
+	}
+	if splits != "" {
+		if err := json.Unmarshal([]byte(splits), &it.Splits); err != nil {
+			logrus.WithError(err).Warnf("parse bill splits fail! id:%d", id)
+		}
+	}
+	it.ID = strconv.FormatInt(id, 10)
+	it.Categories = make([]string, 0)
+	if categories != "" {
+		if err := json.Unmarshal([]byte(categories), &it.Categories); err != nil {
+			logrus.WithError(err).Warnf("parse bill categories fail! id:%d", id)
+		}
+	}
+	return it, nil
+}
+
+// marshalCategories 分类保存为 JSON 数组，分类名称中可以包含逗号
+func marshalCategories(bill *domain.Bill) string {
+	if len(bill.Categories) == 0 {
+		return "[]"
+	}
+	categories, err := json.Marshal(bill.Categories)
+	if err != nil {
+		return "[]"
+	}
+	return string(categories)
+}
+
+// marshalSplits 分摊参与人保存为 JSON，不是分摊账单时为空
+func marshalSplits(bill *domain.Bill) string {
+	if len(bill.Splits) == 0 {
+		return ""
+	}
+	splits, err := json.Marshal(bill.Splits)
+	if err != nil {
+		return ""
+	}
+	return string(splits)
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type ledgerMemberRepository struct {
+	db *sql.DB
+}
+
+func NewLedgerMemberRepository(sdb *sql.DB) domain.LedgerMemberRepository {
+	return &ledgerMemberRepository{db: sdb}
+}
+
+func (l *ledgerMemberRepository) Save(it *domain.LedgerMember) error {
+	_, err := l.db.Exec(`INSERT INTO ledger_members (ledger_id, uid, name, role, joined_at, active_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (ledger_id, uid) DO UPDATE SET name = excluded.name, role = excluded.role, joined_at = excluded.joined_at, active_at = excluded.active_at`,
+		it.LedgerID, it.UID, it.Name, it.Role, it.JoinedAt, it.ActiveAt)
+	if err != nil {
+		logrus.WithError(err).Errorf("save ledger member fail! member:%+v", it)
+		return err
+	}
+	var id int64
+	if err = l.db.QueryRow(`SELECT id FROM ledger_members WHERE ledger_id = ? AND uid = ?`, it.LedgerID, it.UID).Scan(&id); err != nil {
+		return err
+	}
+	it.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (l *ledgerMemberRepository) QueryByUID(UID string) []*domain.LedgerMember {
+	return l.query(`WHERE uid = ? ORDER BY active_at DESC, id DESC`, UID)
+}
+
+func (l *ledgerMemberRepository) Activate(ledgerID, UID string, at int64) error {
+	_, err := l.db.Exec(`UPDATE ledger_members SET active_at = ? WHERE ledger_id = ? AND uid = ?`, at, ledgerID, UID)
+	if err != nil {
+		logrus.WithError(err).Errorf("activate ledger fail! ledger:%s uid:%s", ledgerID, UID)
+	}
+	return err
+}
+
+func (l *ledgerMemberRepository) QueryByLedger(ledgerID string) []*domain.LedgerMember {
+	return l.query(`WHERE ledger_id = ? ORDER BY joined_at, id`, ledgerID)
+}
+
+func (l *ledgerMemberRepository) SaveInvite(it *domain.LedgerInvite) error {
+	_, err := l.db.Exec(`INSERT INTO ledger_invites (code, ledger_id, role, inviter_id, expire_at) VALUES (?, ?, ?, ?, ?)`,
+		it.Code, it.LedgerID, it.Role, it.InviterID, it.ExpireAt)
+	if err != nil {
+		logrus.WithError(err).Errorf("save ledger invite fail! invite:%+v", it)
+	}
+	return err
+}
+
+func (l *ledgerMemberRepository) QueryInvite(code string) (*domain.LedgerInvite, bool) {
+	it := &domain.LedgerInvite{}
+	err := l.db.QueryRow(`SELECT code, ledger_id, role, inviter_id, expire_at FROM ledger_invites WHERE code = ?`, code).
+		Scan(&it.Code, &it.LedgerID, &it.Role, &it.InviterID, &it.ExpireAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.WithError(err).Errorf("query ledger invite fail! code:%s", code)
+		}
+		return nil, false
+	}
+	return it, true
+}
+
+func (l *ledgerMemberRepository) query(clause string, args ...interface{}) []*domain.LedgerMember {
+	rows, err := l.db.Query(`SELECT id, ledger_id, uid, name, role, joined_at, active_at FROM ledger_members `+clause, args...)
+	if err != nil {
+		logrus.WithError(err).Error("query ledger members fail!")
+		return nil
+	}
+	defer rows.Close()
+
+	res := make([]*domain.LedgerMember, 0)
+	for rows.Next() {
+		var id int64
+		it := &domain.LedgerMember{}
+		if err := rows.Scan(&id, &it.LedgerID, &it.UID, &it.Name, &it.Role, &it.JoinedAt, &it.ActiveAt); err != nil {
+			logrus.WithError(err).Error("scan ledger member fail!")
+			continue
+		}
+		it.ID = strconv.FormatInt(id, 10)
+		res = append(res, it)
+	}
+	return res
+}
@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+const (
+	localLedgerName = "本地账本"
+	localBillTable  = "bills"
+)
+
+type ledgerProvisioner struct {
+}
+
+func NewLedgerProvisioner() domain.LedgerProvisioner {
+	return &ledgerProvisioner{}
+}
+
+// Provision 本地账本共用 bills 表，通过随机生成的 AppToken 区分
+func (l *ledgerProvisioner) Provision(ctx context.Context) (*domain.Ledger, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return &domain.Ledger{
+		AppToken:   "local" + hex.EncodeToString(buf),
+		TableToken: localBillTable,
+		Name:       localLedgerName,
+	}, nil
+}
@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type ledgerRepository struct {
+	db *sql.DB
+}
+
+func NewLedgerRepository(sdb *sql.DB) domain.LedgerRepository {
+	return &ledgerRepository{db: sdb}
+}
+
+func (l *ledgerRepository) Save(it *domain.Ledger) error {
+	res, err := l.db.Exec(`INSERT INTO ledgers (app_token, table_token, name, url, creator_id, creator_name)
+		VALUES (?, ?, ?, ?, ?, ?)`, it.AppToken, it.TableToken, it.Name, it.URL, it.CreatorID, it.CreatorName)
+	if err != nil {
+		logrus.WithError(err).Errorf("insert ledger fail! ledger:%+v", it)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	it.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (l *ledgerRepository) QueryByID(id string) (*domain.Ledger, bool) {
+	ls := l.query(`WHERE id = ?`, id)
+	if len(ls) == 0 {
+		return nil, false
+	}
+	return ls[0], true
+}
+
+func (l *ledgerRepository) QueryByCreator(UID string) (*domain.Ledger, bool) {
+	ls := l.query(`WHERE creator_id = ? ORDER BY id LIMIT 1`, UID)
+	if len(ls) == 0 {
+		return nil, false
+	}
+	return ls[0], true
+}
+
+func (l *ledgerRepository) QueryUnallocated() []*domain.Ledger {
+	return l.query(`WHERE creator_id = '' ORDER BY id`)
+}
+
+func (l *ledgerRepository) UpdateUser(id string, user domain.User) error {
+	_, err := l.db.Exec(`UPDATE ledgers SET creator_id = ?, creator_name = ? WHERE id = ?`, user.UID, user.Name, id)
+	if err != nil {
+		logrus.WithError(err).Errorf("update ledger user fail! id:%s", id)
+	}
+	return err
+}
+
+func (l *ledgerRepository) UpdateName(id, name string) error {
+	_, err := l.db.Exec(`UPDATE ledgers SET name = ? WHERE id = ?`, name, id)
+	if err != nil {
+		logrus.WithError(err).Errorf("update ledger name fail! id:%s", id)
+	}
+	return err
+}
+
+func (l *ledgerRepository) UpdateBudgets(it *domain.Ledger) error {
+	budgets, err := json.Marshal(it.Budgets)
+	if err != nil {
+		return err
+	}
+	_, err = l.db.Exec(`UPDATE ledgers SET budgets = ? WHERE id = ?`, string(budgets), it.ID)
+	if err != nil {
+		logrus.WithError(err).Errorf("update ledger budgets fail! id:%s", it.ID)
+	}
+	return err
+}
+
+func (l *ledgerRepository) UpdateCurrency(it *domain.Ledger) error {
+	_, err := l.db.Exec(`UPDATE ledgers SET currency = ? WHERE id = ?`, it.Currency, it.ID)
+	if err != nil {
+		logrus.WithError(err).Errorf("update ledger currency fail! id:%s", it.ID)
+	}
+	return err
+}
+
+// WarmUP 本地数据库无需预热
+func (l *ledgerRepository) WarmUP(ctx context.Context) {
+}
+
+func (l *ledgerRepository) query(clause string, args ...interface{}) []*domain.Ledger {
+	rows, err := l.db.Query(`SELECT id, app_token, table_token, name, url, creator_id, creator_name, budgets, currency FROM ledgers `+clause, args...)
+	if err != nil {
+		logrus.WithError(err).Error("query ledgers fail!")
+		return nil
+	}
+	defer rows.Close()
+
+	res := make([]*domain.Ledger, 0)
+	for rows.Next() {
+		var id int64
+		var budgets string
+		it := &domain.Ledger{}
+		if err := rows.Scan(&id, &it.AppToken, &it.TableToken, &it.Name, &it.URL, &it.CreatorID, &it.CreatorName, &budgets, &it.Currency); err != nil {
+			logrus.WithError(err).Error("scan ledger fail!")
+			continue
+		}
+		it.ID = strconv.FormatInt(id, 10)
+		it.Budgets = make([]domain.Budget, 0)
+		if budgets != "" {
+			if err := json.Unmarshal([]byte(budgets), &it.Budgets); err != nil {
+				logrus.WithError(err).Warnf("parse ledger budgets fail! id:%s", it.ID)
+			}
+		}
+		res = append(res, it)
+	}
+	return res
+}
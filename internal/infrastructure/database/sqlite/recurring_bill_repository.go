@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type recurringBillRepository struct {
+	db *sql.DB
+}
+
+func NewRecurringBillRepository(sdb *sql.DB) domain.RecurringBillRepository {
+	return &recurringBillRepository{db: sdb}
+}
+
+func (r *recurringBillRepository) Save(it *domain.RecurringBill) error {
+	res, err := r.db.Exec(`INSERT INTO recurring_bills
+		(app_token, table_token, day, remark, category, amount, expenses, author_id, author_name, next_period)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		it.AppToken, it.TableToken, it.Day, it.Remark, it.Category, it.Amount, it.Expenses, it.AuthorID, it.AuthorName, it.NextPeriod)
+	if err != nil {
+		logrus.WithError(err).Errorf("insert recurring bill fail! rule:%+v", it)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	it.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (r *recurringBillRepository) ClaimNextPeriod(it *domain.RecurringBill, next string) (bool, error) {
+	res, err := r.db.Exec(`UPDATE recurring_bills SET next_period = ? WHERE id = ? AND next_period = ?`, next, it.ID, it.NextPeriod)
+	if err != nil {
+		logrus.WithError(err).Errorf("update recurring bill fail! id:%s", it.ID)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	it.NextPeriod = next
+	return true, nil
+}
+
+func (r *recurringBillRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM recurring_bills WHERE id = ?`, id)
+	if err != nil {
+		logrus.WithError(err).Errorf("delete recurring bill fail! id:%s", id)
+	}
+	return err
+}
+
+func (r *recurringBillRepository) QueryByLedger(appToken, tableToken string) []*domain.RecurringBill {
+	return r.query(`WHERE app_token = ? AND table_token = ? ORDER BY day, id`, appToken, tableToken)
+}
+
+func (r *recurringBillRepository) QueryAll() []*domain.RecurringBill {
+	return r.query(`ORDER BY id`)
+}
+
+func (r *recurringBillRepository) query(clause string, args ...interface{}) []*domain.RecurringBill {
+	rows, err := r.db.Query(`SELECT id, app_token, table_token, day, remark, category, amount, expenses, author_id, author_name, next_period
+		FROM recurring_bills `+clause, args...)
+	if err != nil {
+		logrus.WithError(err).Error("query recurring bills fail!")
+		return nil
+	}
+	defer rows.Close()
+
+	res := make([]*domain.RecurringBill, 0)
+	for rows.Next() {
+		var id int64
+		it := &domain.RecurringBill{}
+		if err := rows.Scan(&id, &it.AppToken, &it.TableToken, &it.Day, &it.Remark, &it.Category, &it.Amount, &it.Expenses,
+			&it.AuthorID, &it.AuthorName, &it.NextPeriod); err != nil {
+			logrus.WithError(err).Error("scan recurring bill fail!")
+			continue
+		}
+		it.ID = strconv.FormatInt(id, 10)
+		res = append(res, it)
+	}
+	return res
+}
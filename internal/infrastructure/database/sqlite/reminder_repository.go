@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type reminderRepository struct {
+	db *sql.DB
+}
+
+func NewReminderRepository(sdb *sql.DB) domain.ReminderRepository {
+	return &reminderRepository{db: sdb}
+}
+
+func (r *reminderRepository) Touch(UID, channel string) error {
+	_, err := r.db.Exec(`INSERT OR IGNORE INTO reminders (uid, channel) VALUES (?, ?)`, UID, channel)
+	if err != nil {
+		logrus.WithError(err).Errorf("touch reminder fail! uid:%s", UID)
+	}
+	return err
+}
+
+func (r *reminderRepository) SetDisabled(UID, channel string, disabled bool) error {
+	_, err := r.db.Exec(`INSERT INTO reminders (uid, channel, disabled) VALUES (?, ?, ?)
+		ON CONFLICT (uid) DO UPDATE SET disabled = excluded.disabled`, UID, channel, disabled)
+	if err != nil {
+		logrus.WithError(err).Errorf("update reminder fail! uid:%s", UID)
+	}
+	return err
+}
+
+func (r *reminderRepository) QueryEnabled() []*domain.Reminder {
+	rows, err := r.db.Query(`SELECT uid, channel, disabled, last_remind_at FROM reminders WHERE disabled = 0 ORDER BY uid`)
+	if err != nil {
+		logrus.WithError(err).Error("query reminders fail!")
+		return nil
+	}
+	defer rows.Close()
+
+	res := make([]*domain.Reminder, 0)
+	for rows.Next() {
+		it := &domain.Reminder{}
+		if err := rows.Scan(&it.UID, &it.Channel, &it.Disabled, &it.LastRemindAt); err != nil {
+			logrus.WithError(err).Error("scan reminder fail!")
+			continue
+		}
+		res = append(res, it)
+	}
+	return res
+}
+
+func (r *reminderRepository) ClaimRemind(it *domain.Reminder, at int64) (bool, error) {
+	res, err := r.db.Exec(`UPDATE reminders SET last_remind_at = ? WHERE uid = ? AND last_remind_at = ?`, at, it.UID, it.LastRemindAt)
+	if err != nil {
+		logrus.WithError(err).Errorf("claim reminder fail! uid:%s", it.UID)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	it.LastRemindAt = at
+	return true, nil
+}
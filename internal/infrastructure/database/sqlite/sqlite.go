@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+)
+
+// migrations 按顺序执行的建表语句，只能追加，不能修改已发布的语句
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS bills (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		app_token   TEXT    NOT NULL,
+		table_token TEXT    NOT NULL,
+		remark      TEXT    NOT NULL DEFAULT '',
+		categories  TEXT    NOT NULL DEFAULT '',
+		amount      REAL    NOT NULL DEFAULT 0,
+		month       TEXT    NOT NULL DEFAULT '',
+		date        INTEGER NOT NULL DEFAULT 0,
+		expenses    TEXT    NOT NULL DEFAULT '',
+		author_id   TEXT    NOT NULL DEFAULT '',
+		author_name TEXT    NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_bills_ledger ON bills (app_token, table_token, date)`,
+	`CREATE TABLE IF NOT EXISTS ledgers (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		app_token    TEXT NOT NULL,
+		table_token  TEXT NOT NULL,
+		name         TEXT NOT NULL DEFAULT '',
+		url          TEXT NOT NULL DEFAULT '',
+		creator_id   TEXT NOT NULL DEFAULT '',
+		creator_name TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ledgers_creator ON ledgers (creator_id)`,
+	`CREATE TABLE IF NOT EXISTS users (
+		uid  TEXT PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_logs (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		key        TEXT    NOT NULL DEFAULT '',
+		operator   TEXT    NOT NULL DEFAULT '',
+		req        TEXT    NOT NULL DEFAULT '',
+		resp       TEXT    NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL DEFAULT 0
+	)`,
+	`ALTER TABLE bills ADD COLUMN transaction_id TEXT NOT NULL DEFAULT ''`,
+	// 退款与原交易可能使用相同的单号，按单号及收支唯一
+	`CREATE UNIQUE INDEX IF NOT EXISTS uk_bills_transaction ON bills (app_token, table_token, transaction_id, expenses) WHERE transaction_id != ''`,
+	`ALTER TABLE ledgers ADD COLUMN budgets TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS recurring_bills (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		app_token   TEXT    NOT NULL,
+		table_token TEXT    NOT NULL,
+		day         INTEGER NOT NULL DEFAULT 1,
+		remark      TEXT    NOT NULL DEFAULT '',
+		category    TEXT    NOT NULL DEFAULT '',
+		amount      REAL    NOT NULL DEFAULT 0,
+		expenses    TEXT    NOT NULL DEFAULT '',
+		author_id   TEXT    NOT NULL DEFAULT '',
+		author_name TEXT    NOT NULL DEFAULT '',
+		next_period TEXT    NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_recurring_bills_ledger ON recurring_bills (app_token, table_token)`,
+	`CREATE TABLE IF NOT EXISTS subscriptions (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		uid         TEXT    NOT NULL,
+		channel     TEXT    NOT NULL DEFAULT '',
+		frequency   TEXT    NOT NULL,
+		hour        INTEGER NOT NULL DEFAULT 0,
+		last_period TEXT    NOT NULL DEFAULT '',
+		UNIQUE (uid, frequency)
+	)`,
+	`CREATE TABLE IF NOT EXISTS reminders (
+		uid            TEXT PRIMARY KEY,
+		channel        TEXT    NOT NULL DEFAULT '',
+		disabled       INTEGER NOT NULL DEFAULT 0,
+		last_remind_at INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS ledger_members (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		ledger_id TEXT    NOT NULL,
+		uid       TEXT    NOT NULL,
+		name      TEXT    NOT NULL DEFAULT '',
+		role      TEXT    NOT NULL DEFAULT '',
+		joined_at INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (ledger_id, uid)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_ledger_members_uid ON ledger_members (uid)`,
+	`CREATE TABLE IF NOT EXISTS ledger_invites (
+		code       TEXT PRIMARY KEY,
+		ledger_id  TEXT    NOT NULL,
+		role       TEXT    NOT NULL DEFAULT '',
+		inviter_id TEXT    NOT NULL DEFAULT '',
+		expire_at  INTEGER NOT NULL DEFAULT 0
+	)`,
+	`ALTER TABLE ledger_members ADD COLUMN active_at INTEGER NOT NULL DEFAULT 0`,
+	`UPDATE ledger_members SET active_at = joined_at`,
+	`ALTER TABLE bills ADD COLUMN splits TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE bills ADD COLUMN currency TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE ledgers ADD COLUMN currency TEXT NOT NULL DEFAULT ''`,
+}
+
+// Open 打开本地数据库文件，并执行未完成的 migrations
+func Open(cfg *config.Config) (*sql.DB, error) {
+	sdb, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on", cfg.SqlitePath))
+	if err != nil {
+		return nil, err
+	}
+	// sqlite 只允许单个写连接，避免 database is locked
+	sdb.SetMaxOpenConns(1)
+	if err = migrate(sdb); err != nil {
+		_ = sdb.Close()
+		return nil, err
+	}
+	return sdb, nil
+}
+
+func migrate(sdb *sql.DB) error {
+	if _, err := sdb.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	if err := sdb.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := sdb.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(migrations[i]); err != nil {
+			_ = tx.Rollback()
+			logrus.WithError(err).Errorf("sqlite migrate fail! version:%d", i+1)
+			return err
+		}
+		if _, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type subscriptionRepository struct {
+	db *sql.DB
+}
+
+func NewSubscriptionRepository(sdb *sql.DB) domain.SubscriptionRepository {
+	return &subscriptionRepository{db: sdb}
+}
+
+func (s *subscriptionRepository) Save(it *domain.Subscription) error {
+	_, err := s.db.Exec(`INSERT INTO subscriptions (uid, channel, frequency, hour, last_period) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (uid, frequency) DO UPDATE SET channel = excluded.channel, hour = excluded.hour`,
+		it.UID, it.Channel, it.Frequency, it.Hour, it.LastPeriod)
+	if err != nil {
+		logrus.WithError(err).Errorf("save subscription fail! subscription:%+v", it)
+		return err
+	}
+	var id int64
+	if err = s.db.QueryRow(`SELECT id, last_period FROM subscriptions WHERE uid = ? AND frequency = ?`, it.UID, it.Frequency).
+		Scan(&id, &it.LastPeriod); err != nil {
+		return err
+	}
+	it.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (s *subscriptionRepository) Delete(UID, frequency string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM subscriptions WHERE uid = ? AND frequency = ?`, UID, frequency)
+	if err != nil {
+		logrus.WithError(err).Errorf("delete subscription fail! uid:%s", UID)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *subscriptionRepository) QueryByUID(UID string) []*domain.Subscription {
+	return s.query(`WHERE uid = ? ORDER BY id`, UID)
+}
+
+func (s *subscriptionRepository) QueryAll() []*domain.Subscription {
+	return s.query(`ORDER BY id`)
+}
+
+func (s *subscriptionRepository) ClaimPeriod(it *domain.Subscription, period string) (bool, error) {
+	res, err := s.db.Exec(`UPDATE subscriptions SET last_period = ? WHERE id = ? AND last_period <> ?`, period, it.ID, period)
+	if err != nil {
+		logrus.WithError(err).Errorf("claim subscription period fail! id:%s", it.ID)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	it.LastPeriod = period
+	return true, nil
+}
+
+func (s *subscriptionRepository) query(clause string, args ...interface{}) []*domain.Subscription {
+	rows, err := s.db.Query(`SELECT id, uid, channel, frequency, hour, last_period FROM subscriptions `+clause, args...)
+	if err != nil {
+		logrus.WithError(err).Error("query subscriptions fail!")
+		return nil
+	}
+	defer rows.Close()
+
+	res := make([]*domain.Subscription, 0)
+	for rows.Next() {
+		var id int64
+		it := &domain.Subscription{}
+		if err := rows.Scan(&id, &it.UID, &it.Channel, &it.Frequency, &it.Hour, &it.LastPeriod); err != nil {
+			logrus.WithError(err).Error("scan subscription fail!")
+			continue
+		}
+		it.ID = strconv.FormatInt(id, 10)
+		res = append(res, it)
+	}
+	return res
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type userRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(sdb *sql.DB) domain.UserRepository {
+	return &userRepository{db: sdb}
+}
+
+func (u *userRepository) GetByID(UID string) (*domain.User, bool) {
+	res := &domain.User{}
+	err := u.db.QueryRow(`SELECT uid, name FROM users WHERE uid = ?`, UID).Scan(&res.UID, &res.Name)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.WithError(err).Errorf("query user fail! uid:%s", UID)
+		}
+		return nil, false
+	}
+	return res, true
+}
+
+func (u *userRepository) Save(it *domain.User) (string, error) {
+	_, err := u.db.Exec(`INSERT INTO users (uid, name) VALUES (?, ?)
+		ON CONFLICT (uid) DO UPDATE SET name = excluded.name`, it.UID, it.Name)
+	if err != nil {
+		logrus.WithError(err).Errorf("save user fail! user:%+v", it)
+		return "", err
+	}
+	return it.UID, nil
+}
+
+// WarmUP 本地数据库无需预热
+func (u *userRepository) WarmUP(ctx context.Context) {
+}
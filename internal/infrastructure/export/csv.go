@@ -0,0 +1,28 @@
+package export
+
+import (
+	"encoding/csv"
+	"github.com/wangyuheng/richman/internal/domain"
+	"io"
+)
+
+// utf8BOM 便于 Excel 正确识别中文
+const utf8BOM = "\xEF\xBB\xBF"
+
+func writeCSV(w io.Writer, scan domain.BillScan) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	err := scan(func(bill *domain.Bill) error {
+		return cw.Write(toRow(bill).values())
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,115 @@
+package export
+
+import (
+	"fmt"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatJSON = "json"
+	// FormatBeancount 等复式记账格式，分类按配置映射为科目
+	FormatBeancount = "beancount"
+	FormatLedger    = "ledger"
+	FormatHledger   = "hledger"
+)
+
+const dateLayout = "2006-01-02"
+
+// columns 导出的列名，新增列只能追加在末尾
+var columns = []string{"id", "date", "type", "remark", "category", "amount", "author_id", "author_name", "currency"}
+
+// Row 导出的一行账单
+type Row struct {
+	ID   string `json:"id"`
+	Date string `json:"date"`
+	// Type income 或 expense
+	Type   string `json:"type"`
+	Remark string `json:"remark"`
+	// Category 多个分类以 ; 分隔
+	Category   string  `json:"category"`
+	Amount     float64 `json:"amount"`
+	AuthorID   string  `json:"author_id"`
+	AuthorName string  `json:"author_name"`
+	// Currency 账单记录的币种，为空表示人民币（domain.DefaultCurrency），与账本本位币无关
+	Currency string `json:"currency"`
+}
+
+func toRow(bill *domain.Bill) Row {
+	typ := "expense"
+	if common.Expenses(bill.Expenses) == common.Income {
+		typ = "income"
+	}
+	return Row{
+		ID:         bill.ID,
+		Date:       time.Unix(0, bill.Date*1e6).Format(dateLayout),
+		Type:       typ,
+		Remark:     bill.Remark,
+		Category:   strings.Join(bill.Categories, ";"),
+		Amount:     bill.Amount,
+		AuthorID:   bill.AuthorID,
+		AuthorName: bill.AuthorName,
+		Currency:   bill.Currency,
+	}
+}
+
+// values 与 columns 顺序一致
+func (r Row) values() []string {
+	return []string{r.ID, r.Date, r.Type, r.Remark, r.Category, strconv.FormatFloat(r.Amount, 'f', 2, 64), r.AuthorID, r.AuthorName, r.Currency}
+}
+
+type writer struct {
+	contentType string
+	write       func(w io.Writer, scan domain.BillScan) error
+}
+
+type billExporter struct {
+	writers map[string]writer
+}
+
+func NewBillExporter(cfg *config.Config) (domain.BillExporter, error) {
+	j, err := newJournal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &billExporter{
+		writers: map[string]writer{
+			FormatCSV:       {contentType: "text/csv; charset=utf-8", write: writeCSV},
+			FormatXLSX:      {contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", write: writeXLSX},
+			FormatJSON:      {contentType: "application/json; charset=utf-8", write: writeJSON},
+			FormatBeancount: {contentType: "text/plain; charset=utf-8", write: j.writeBeancount},
+			FormatLedger:    {contentType: "text/plain; charset=utf-8", write: j.writeLedger},
+			FormatHledger:   {contentType: "text/plain; charset=utf-8", write: j.writeHledger},
+		},
+	}, nil
+}
+
+func (e *billExporter) Formats() []string {
+	formats := make([]string, 0, len(e.writers))
+	for f := range e.writers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func (e *billExporter) ContentType(format string) (string, bool) {
+	w, ok := e.writers[format]
+	return w.contentType, ok
+}
+
+func (e *billExporter) Export(w io.Writer, format string, scan domain.BillScan) error {
+	it, ok := e.writers[format]
+	if !ok {
+		return fmt.Errorf("unsupported export format %s", format)
+	}
+	return it.write(w, scan)
+}
@@ -0,0 +1,194 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultExpenseAccount 未配置映射的支出分类
+	defaultExpenseAccount = "Expenses:Uncategorized"
+	defaultIncomeAccount  = "Income:Uncategorized"
+)
+
+// journal 将账单转换为复式记账分录，分类通过映射表对应到 Expenses:* 或 Income:* 科目，
+// 对方科目统一为 asset
+type journal struct {
+	// accounts 分类与科目的映射，key 为 分类 或 收入:分类、支出:分类
+	accounts map[string]string
+	asset    string
+	currency string
+}
+
+func newJournal(cfg *config.Config) (*journal, error) {
+	j := &journal{
+		accounts: make(map[string]string),
+		asset:    cfg.JournalAssetAccount,
+		currency: cfg.JournalCurrency,
+	}
+	if cfg.JournalAccountsPath == "" {
+		return j, nil
+	}
+	f, err := os.Open(cfg.JournalAccountsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if j.accounts, err = parseAccounts(f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", cfg.JournalAccountsPath, err)
+	}
+	return j, nil
+}
+
+// parseAccounts 解析映射文件，每行为 分类 = 科目，# 开头为注释，如:
+//
+//	餐饮 = Expenses:Food
+//	收入:其他 = Income:Other
+func parseAccounts(r io.Reader) (map[string]string, error) {
+	accounts := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("line %d: expect 分类 = 科目", n)
+		}
+		accounts[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return accounts, scanner.Err()
+}
+
+// account 按分类顺序匹配，收支类型限定的映射优先
+func (j *journal) account(bill *domain.Bill) string {
+	expenses := common.Pay
+	if common.Expenses(bill.Expenses) == common.Income {
+		expenses = common.Income
+	}
+	for _, c := range bill.Categories {
+		if a, ok := j.accounts[fmt.Sprintf("%s:%s", expenses, c)]; ok {
+			return a
+		}
+		if a, ok := j.accounts[c]; ok {
+			return a
+		}
+	}
+	if expenses == common.Income {
+		return defaultIncomeAccount
+	}
+	return defaultExpenseAccount
+}
+
+// postings 按借贷顺序返回科目，收入记入资产，支出从资产扣除
+func (j *journal) postings(bill *domain.Bill) (debit, credit string) {
+	account := j.account(bill)
+	if common.Expenses(bill.Expenses) == common.Income {
+		return j.asset, account
+	}
+	return account, j.asset
+}
+
+func (j *journal) writeBeancount(w io.Writer, scan domain.BillScan) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "option \"operating_currency\" \"%s\"\n", j.currency)
+	// beancount 要求科目先 open，账单按日期顺序导出，科目在首次使用的日期开启
+	opened := map[string]bool{}
+	err := scan(func(bill *domain.Bill) error {
+		debit, credit := j.postings(bill)
+		date := journalDate(bill.Date, dateLayout)
+		names := make([]string, 0, 2)
+		for _, name := range []string{debit, credit} {
+			if !opened[name] {
+				opened[name] = true
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			bw.WriteString("\n")
+		}
+		for _, name := range names {
+			fmt.Fprintf(bw, "%s open %s\n", date, name)
+		}
+		fmt.Fprintf(bw, "\n%s * %s\n", date, quote(bill.Remark))
+		fmt.Fprintf(bw, "  id: %s\n", quote(bill.ID))
+		if bill.AuthorName != "" {
+			fmt.Fprintf(bw, "  author: %s\n", quote(bill.AuthorName))
+		}
+		if len(bill.Categories) > 0 {
+			fmt.Fprintf(bw, "  category: %s\n", quote(strings.Join(bill.Categories, ";")))
+		}
+		fmt.Fprintf(bw, "  %s  %.2f %s\n", debit, bill.Amount, j.commodity(bill))
+		fmt.Fprintf(bw, "  %s\n", credit)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeLedger ledger-cli 格式，日期为 yyyy/mm/dd
+func (j *journal) writeLedger(w io.Writer, scan domain.BillScan) error {
+	return j.writePlainText(w, scan, "2006/01/02")
+}
+
+// writeHledger hledger 格式，日期为 yyyy-mm-dd，注释中的 key: value 识别为 tag
+func (j *journal) writeHledger(w io.Writer, scan domain.BillScan) error {
+	return j.writePlainText(w, scan, dateLayout)
+}
+
+// writePlainText ledger-cli 与 hledger 语法一致，元数据写在注释中
+func (j *journal) writePlainText(w io.Writer, scan domain.BillScan, layout string) error {
+	bw := bufio.NewWriter(w)
+	first := true
+	err := scan(func(bill *domain.Bill) error {
+		if !first {
+			bw.WriteString("\n")
+		}
+		first = false
+		debit, credit := j.postings(bill)
+		fmt.Fprintf(bw, "%s %s\n", journalDate(bill.Date, layout), singleLine(bill.Remark))
+		fmt.Fprintf(bw, "    ; id: %s\n", bill.ID)
+		if bill.AuthorName != "" {
+			fmt.Fprintf(bw, "    ; author: %s\n", singleLine(bill.AuthorName))
+		}
+		fmt.Fprintf(bw, "    %s  %.2f %s\n", debit, bill.Amount, j.commodity(bill))
+		fmt.Fprintf(bw, "    %s\n", credit)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// commodity 外币账单使用账单的币种
+func (j *journal) commodity(bill *domain.Bill) string {
+	if bill.Currency != "" {
+		return bill.Currency
+	}
+	return j.currency
+}
+
+func journalDate(millis int64, layout string) string {
+	return time.Unix(0, millis*1e6).Format(layout)
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(singleLine(s)) + `"`
+}
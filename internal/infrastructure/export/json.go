@@ -0,0 +1,34 @@
+package export
+
+import (
+	"encoding/json"
+	"github.com/wangyuheng/richman/internal/domain"
+	"io"
+)
+
+// writeJSON 逐条写入，避免账单较多时整体序列化
+func writeJSON(w io.Writer, scan domain.BillScan) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := scan(func(bill *domain.Bill) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		b, err := json.Marshal(toRow(bill))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
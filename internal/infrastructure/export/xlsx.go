@@ -0,0 +1,113 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"github.com/wangyuheng/richman/internal/domain"
+	"io"
+	"strconv"
+)
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// xlsxParts 除工作表外的固定文件，只包含一个名为 bills 的工作表
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{
+		name: "[Content_Types].xml",
+		content: `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+	},
+	{
+		name: "_rels/.rels",
+		content: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		name: "xl/workbook.xml",
+		content: `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="bills" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`,
+	},
+	{
+		name: "xl/_rels/workbook.xml.rels",
+		content: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	},
+}
+
+// amountColumn 金额列以数字写入，便于在表格中求和
+const amountColumn = 5
+
+// writeXLSX 生成最简的 Office Open XML 工作簿，文本使用 inlineStr 无需 sharedStrings
+func writeXLSX(w io.Writer, scan domain.BillScan) error {
+	zw := zip.NewWriter(w)
+	for _, p := range xlsxParts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(f, xmlHeader+p.content); err != nil {
+			return err
+		}
+	}
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	_, _ = bw.WriteString(xmlHeader)
+	_, _ = bw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	writeXLSXRow(bw, 1, columns, -1)
+	row := 1
+	err = scan(func(bill *domain.Bill) error {
+		row++
+		writeXLSXRow(bw, row, toRow(bill).values(), amountColumn)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = bw.WriteString(`</sheetData></worksheet>`)
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeXLSXRow(w *bufio.Writer, row int, values []string, numberColumn int) {
+	_, _ = fmt.Fprintf(w, `<row r="%d">`, row)
+	for i, v := range values {
+		ref := cellRef(i, row)
+		if i == numberColumn {
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				_, _ = fmt.Fprintf(w, `<c r="%s"><v>%s</v></c>`, ref, v)
+				continue
+			}
+		}
+		_, _ = fmt.Fprintf(w, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		_ = xml.EscapeText(w, []byte(v))
+		_, _ = w.WriteString(`</t></is></c>`)
+	}
+	_, _ = w.WriteString(`</row>`)
+}
+
+// cellRef 列序号从 0 开始，转换为 A1 格式的单元格引用
+func cellRef(col, row int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name + strconv.Itoa(row)
+}
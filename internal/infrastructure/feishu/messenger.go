@@ -0,0 +1,86 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+const msgTypeText = "text"
+
+// Messenger 通过飞书机器人发送消息
+type Messenger interface {
+	domain.Messenger
+	// Reply 回复指定消息，群聊中以话题形式展示
+	Reply(ctx context.Context, messageID, content string) error
+}
+
+type messenger struct {
+	cli *lark.Client
+}
+
+func NewMessenger(cli *lark.Client) Messenger {
+	return &messenger{cli: cli}
+}
+
+// SendText 按 open_id 向用户发送单聊消息
+func (m *messenger) SendText(ctx context.Context, UID, content string) error {
+	text, err := textContent(content)
+	if err != nil {
+		return err
+	}
+	req := larkim.NewCreateMessageReqBuilder().
+		ReceiveIdType(larkim.ReceiveIdTypeOpenId).
+		Body(larkim.NewCreateMessageReqBodyBuilder().
+			ReceiveId(UID).
+			MsgType(msgTypeText).
+			Content(text).
+			Build()).
+		Build()
+	resp, err := m.cli.Im.Message.Create(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("create message err! resp:%+v", resp)
+		return err
+	}
+	if !resp.Success() {
+		logrus.WithContext(ctx).Errorf("create message fail! resp:%+v", resp)
+		return fmt.Errorf("create message fail: %s", resp.Msg)
+	}
+	return nil
+}
+
+func (m *messenger) Reply(ctx context.Context, messageID, content string) error {
+	text, err := textContent(content)
+	if err != nil {
+		return err
+	}
+	req := larkim.NewReplyMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewReplyMessageReqBodyBuilder().
+			MsgType(msgTypeText).
+			Content(text).
+			Build()).
+		Build()
+	resp, err := m.cli.Im.Message.Reply(ctx, req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("reply message err! resp:%+v", resp)
+		return err
+	}
+	if !resp.Success() {
+		logrus.WithContext(ctx).Errorf("reply message fail! resp:%+v", resp)
+		return fmt.Errorf("reply message fail: %s", resp.Msg)
+	}
+	return nil
+}
+
+func textContent(content string) (string, error) {
+	b, err := json.Marshal(map[string]string{"text": content})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,67 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"net/http"
+)
+
+func init() {
+	Register("openai-functions", newFunctionsProvider)
+}
+
+// functionsProvider 使用已废弃的 functions/function_call 格式
+type functionsProvider struct {
+	url   string
+	key   string
+	model string
+}
+
+func newFunctionsProvider(cfg config.AIConfig) (Provider, error) {
+	model := cfg.AiModel
+	if model == "" {
+		model = "gpt-3.5-turbo-0613"
+	}
+	return &functionsProvider{
+		url:   cfg.AiURL,
+		key:   cfg.AiKey,
+		model: model,
+	}, nil
+}
+
+func (f *functionsProvider) NewRequest(ctx context.Context, messages []domain.AIMessage, functions []domain.AIFunction) (*http.Request, error) {
+	payload, err := json.Marshal(domain.AIReq{
+		Model:     f.model,
+		Messages:  messages,
+		Functions: functions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.key)
+	return req, nil
+}
+
+func (f *functionsProvider) ParseResponse(body []byte) (*domain.AIMessage, error) {
+	return parseChoices(body)
+}
+
+func parseChoices(body []byte) (*domain.AIMessage, error) {
+	var response domain.AIResp
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("openai response choices is empty")
+	}
+	return &response.Choices[0].Message, nil
+}
@@ -3,7 +3,6 @@ package openai
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/wangyuheng/richman/config"
@@ -16,17 +15,21 @@ import (
 
 type openAIService struct {
 	auditLogger domain.AuditLogService
-	url         string
-	key         string
+	provider    Provider
+	client      *http.Client
 	cache       sync.Map
 }
 
-func NewOpenAIService(cfg *config.Config, auditLogger domain.AuditLogService) domain.AIService {
+func NewOpenAIService(cfg *config.Config, auditLogger domain.AuditLogService) (domain.AIService, error) {
+	provider, err := newProvider(cfg.AIConfig)
+	if err != nil {
+		return nil, err
+	}
 	return &openAIService{
 		auditLogger: auditLogger,
-		url:         cfg.AiURL,
-		key:         cfg.AiKey,
-	}
+		provider:    provider,
+		client:      &http.Client{Timeout: cfg.AiTimeout},
+	}, nil
 }
 
 func (o *openAIService) CallFunctions(ctx context.Context, content string, ai domain.AI) (*domain.AIMessage, error) {
@@ -36,21 +39,25 @@ func (o *openAIService) CallFunctions(ctx context.Context, content string, ai do
 		}
 	}
 
-	data := domain.AIReq{
-		Model: "gpt-3.5-turbo-0613",
-		Messages: []domain.AIMessage{
-			{
-				Role:    "system",
-				Content: ai.Introduction,
-			},
-			{
-				Role:    "user",
-				Content: content,
-			},
+	req, err := o.provider.NewRequest(ctx, []domain.AIMessage{
+		{
+			Role:    "system",
+			Content: ai.Introduction,
+		},
+		{
+			Role:    "user",
+			Content: content,
 		},
-		Functions: ai.Functions,
+	}, ai.Functions)
+	if err != nil {
+		return nil, err
+	}
+	var payload []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			payload, _ = io.ReadAll(body)
+		}
 	}
-	payload, _ := json.Marshal(data)
 
 	var auditResp string
 	defer func() {
@@ -62,27 +69,23 @@ func (o *openAIService) CallFunctions(ctx context.Context, content string, ai do
 		})
 	}()
 
-	req, _ := http.NewRequest("POST", o.url, bytes.NewBuffer(payload))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+o.key)
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := o.client.Do(req)
 	if err != nil {
 		logrus.WithContext(ctx).WithError(err).Errorf("call openai err! req:%+v, resp:%+v", req, resp)
 		return nil, err
 	}
-	var response domain.AIResp
+	defer resp.Body.Close()
 	respBody, _ := io.ReadAll(resp.Body)
 	auditResp = string(respBody)
-	err = json.Unmarshal(respBody, &response)
+	if resp.StatusCode != http.StatusOK {
+		logrus.WithContext(ctx).Errorf("call openai fail! status:%d, resp:%s", resp.StatusCode, bytes.TrimSpace(respBody))
+		return nil, fmt.Errorf("openai response status %d", resp.StatusCode)
+	}
+	msg, err := o.provider.ParseResponse(respBody)
 	if err != nil {
-		logrus.WithError(err).Errorf("call openai response not json err! req:%+v, resp:%+v", req, resp)
+		logrus.WithContext(ctx).WithError(err).Errorf("parse openai response err! req:%+v, resp:%s", req, respBody)
 		return nil, err
 	}
-	if len(response.Choices) == 0 {
-		logrus.WithError(err).Errorf("call openai response not json err! req:%+v, resp:%+v", req, resp)
-		return nil, fmt.Errorf("openai response choices is empty")
-	}
-	o.cache.Store(fmt.Sprintf("AI:FUNCTIONS:%s", content), &response.Choices[0].Message)
-	return &response.Choices[0].Message, nil
+	o.cache.Store(fmt.Sprintf("AI:FUNCTIONS:%s", content), msg)
+	return msg, nil
 }
@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider 将统一的函数调用请求转换为具体 AI 服务的 HTTP 请求，并解析其响应
+type Provider interface {
+	NewRequest(ctx context.Context, messages []domain.AIMessage, functions []domain.AIFunction) (*http.Request, error)
+	ParseResponse(body []byte) (*domain.AIMessage, error)
+}
+
+type ProviderFactory func(cfg config.AIConfig) (Provider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]ProviderFactory)
+)
+
+// Register 注册 AI provider，name 对应配置项 AI_PROVIDER
+func Register(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if _, dup := providers[name]; dup {
+		panic(fmt.Sprintf("openai: register provider %s twice", name))
+	}
+	providers[name] = factory
+}
+
+func newProvider(cfg config.AIConfig) (Provider, error) {
+	providersMu.RLock()
+	factory, ok := providers[cfg.AiProvider]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown ai provider %s, available: %s", cfg.AiProvider, strings.Join(providerNames(), ","))
+	}
+	return factory(cfg)
+}
+
+func providerNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// endpoint 兼容 AI_URL 配置为 base URL 或完整的 chat completions 地址
+func endpoint(baseURL, defaultBaseURL string) string {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if strings.HasSuffix(baseURL, "/chat/completions") {
+		return baseURL
+	}
+	return baseURL + "/chat/completions"
+}
+
+func init() {
+	Register("none", newNoneProvider)
+}
+
+// noneProvider 不调用 AI，所有请求交由规则解析兜底
+type noneProvider struct {
+}
+
+func newNoneProvider(cfg config.AIConfig) (Provider, error) {
+	return &noneProvider{}, nil
+}
+
+func (n *noneProvider) NewRequest(ctx context.Context, messages []domain.AIMessage, functions []domain.AIFunction) (*http.Request, error) {
+	return nil, fmt.Errorf("ai provider is disabled")
+}
+
+func (n *noneProvider) ParseResponse(body []byte) (*domain.AIMessage, error) {
+	return nil, fmt.Errorf("ai provider is disabled")
+}
@@ -0,0 +1,122 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	Register("openai", newOpenAIProvider)
+	Register("azure", newAzureProvider)
+	Register("local", newLocalProvider)
+}
+
+// toolsProvider 使用 tools/tool_calls 格式，兼容 OpenAI、Azure OpenAI 及 Ollama、llama.cpp 等本地服务
+type toolsProvider struct {
+	url    string
+	model  string
+	header http.Header
+}
+
+func newOpenAIProvider(cfg config.AIConfig) (Provider, error) {
+	if cfg.AiKey == "" {
+		return nil, fmt.Errorf("AI_KEY is required by openai provider")
+	}
+	model := cfg.AiModel
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+cfg.AiKey)
+	return &toolsProvider{
+		url:    endpoint(cfg.AiURL, "https://api.openai.com/v1"),
+		model:  model,
+		header: header,
+	}, nil
+}
+
+// newAzureProvider AI_URL 为资源地址，如 https://{resource}.openai.azure.com ，AI_MODEL 为 deployment 名称
+func newAzureProvider(cfg config.AIConfig) (Provider, error) {
+	if cfg.AiURL == "" || cfg.AiKey == "" || cfg.AiModel == "" {
+		return nil, fmt.Errorf("AI_URL, AI_KEY and AI_MODEL are required by azure provider")
+	}
+	version := cfg.AiAPIVersion
+	if version == "" {
+		version = "2024-02-01"
+	}
+	header := http.Header{}
+	header.Set("api-key", cfg.AiKey)
+	return &toolsProvider{
+		url: fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+			strings.TrimSuffix(cfg.AiURL, "/"), url.PathEscape(cfg.AiModel), url.QueryEscape(version)),
+		header: header,
+	}, nil
+}
+
+// newLocalProvider 默认连接本机 Ollama，llama.cpp 可将 AI_URL 设置为 http://localhost:8080/v1
+func newLocalProvider(cfg config.AIConfig) (Provider, error) {
+	if cfg.AiModel == "" {
+		return nil, fmt.Errorf("AI_MODEL is required by local provider")
+	}
+	header := http.Header{}
+	if cfg.AiKey != "" {
+		header.Set("Authorization", "Bearer "+cfg.AiKey)
+	}
+	return &toolsProvider{
+		url:    endpoint(cfg.AiURL, "http://localhost:11434/v1"),
+		model:  cfg.AiModel,
+		header: header,
+	}, nil
+}
+
+func (t *toolsProvider) NewRequest(ctx context.Context, messages []domain.AIMessage, functions []domain.AIFunction) (*http.Request, error) {
+	tools := make([]domain.AITool, 0, len(functions))
+	for _, f := range functions {
+		tools = append(tools, domain.AITool{
+			Type:     "function",
+			Function: f,
+		})
+	}
+	payload, err := json.Marshal(domain.AIReq{
+		Model:    t.model,
+		Messages: messages,
+		Tools:    tools,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range t.header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// ParseResponse 将第一个 tool_call 转换为 FunctionCall，调用方无需区分格式
+func (t *toolsProvider) ParseResponse(body []byte) (*domain.AIMessage, error) {
+	msg, err := parseChoices(body)
+	if err != nil {
+		return nil, err
+	}
+	if msg.FunctionCall == nil {
+		for _, call := range msg.ToolCalls {
+			if call.Type == "" || call.Type == "function" {
+				fc := call.Function
+				msg.FunctionCall = &fc
+				break
+			}
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,106 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+const rateDateLayout = "2006-01-02"
+
+func init() {
+	Register("file", newFileProvider)
+}
+
+// rateFile 离线汇率文件，rates 为每天 1 单位外币可兑换的 base 币种金额，如
+// {"base": "CNY", "rates": {"2026-10-01": {"USD": 7.12, "JPY": 0.048}}}
+type rateFile struct {
+	Base  string                        `json:"base"`
+	Rates map[string]map[string]float64 `json:"rates"`
+}
+
+type datedRate struct {
+	date string
+	rate float64
+}
+
+// fileProvider 从文件加载汇率，用于离线部署及测试。
+// 没有账单当天的汇率时使用之前最近一天的汇率，早于文件中所有日期时使用最早的汇率
+type fileProvider struct {
+	base  string
+	rates map[string][]datedRate
+}
+
+func newFileProvider(cfg config.RateConfig) (domain.RateProvider, error) {
+	if cfg.RateFile == "" {
+		return nil, fmt.Errorf("RATE_FILE is required for file rate provider")
+	}
+	data, err := os.ReadFile(cfg.RateFile)
+	if err != nil {
+		return nil, err
+	}
+	var f rateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse rate file %s: %w", cfg.RateFile, err)
+	}
+	if f.Base == "" {
+		f.Base = domain.DefaultCurrency
+	}
+	p := &fileProvider{base: strings.ToUpper(f.Base), rates: make(map[string][]datedRate)}
+	for date, rates := range f.Rates {
+		if _, err := time.Parse(rateDateLayout, date); err != nil {
+			return nil, fmt.Errorf("illegal date %s in rate file: %w", date, err)
+		}
+		for currency, rate := range rates {
+			if rate <= 0 {
+				return nil, fmt.Errorf("illegal rate %s %s in rate file", date, currency)
+			}
+			currency = strings.ToUpper(currency)
+			p.rates[currency] = append(p.rates[currency], datedRate{date: date, rate: rate})
+		}
+	}
+	for _, it := range p.rates {
+		sort.Slice(it, func(i, j int) bool {
+			return it[i].date < it[j].date
+		})
+	}
+	return p, nil
+}
+
+func (f *fileProvider) Rate(ctx context.Context, from, to string, date time.Time) (float64, error) {
+	fromRate, ok := f.baseRate(from, date)
+	if !ok {
+		return 0, domain.ErrRateNotFound
+	}
+	toRate, ok := f.baseRate(to, date)
+	if !ok {
+		return 0, domain.ErrRateNotFound
+	}
+	return fromRate / toRate, nil
+}
+
+// baseRate 1 单位 currency 可兑换的 base 币种金额
+func (f *fileProvider) baseRate(currency string, date time.Time) (float64, bool) {
+	if currency == f.base {
+		return 1, true
+	}
+	rates := f.rates[currency]
+	if len(rates) == 0 {
+		return 0, false
+	}
+	day := date.Format(rateDateLayout)
+	i := sort.Search(len(rates), func(i int) bool {
+		return rates[i].date > day
+	})
+	if i == 0 {
+		return rates[0].rate, true
+	}
+	return rates[i-1].rate, true
+}
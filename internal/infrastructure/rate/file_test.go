@@ -0,0 +1,98 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+func writeRateFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileProviderRate(t *testing.T) {
+	path := writeRateFile(t, `{"base": "CNY", "rates": {
+		"2026-10-01": {"USD": 7.1, "jpy": 0.05},
+		"2026-10-05": {"USD": 7.2}
+	}}`)
+	provider, err := newFileProvider(config.RateConfig{RateFile: path})
+	if err != nil {
+		t.Fatalf("newFileProvider() error = %v", err)
+	}
+	day := func(s string) time.Time {
+		d, _ := time.Parse(rateDateLayout, s)
+		return d
+	}
+	tests := []struct {
+		name     string
+		from, to string
+		date     time.Time
+		want     float64
+		wantErr  error
+	}{
+		{name: "当天汇率", from: "USD", to: "CNY", date: day("2026-10-05"), want: 7.2},
+		{name: "没有当天汇率时使用之前最近一天", from: "USD", to: "CNY", date: day("2026-10-04"), want: 7.1},
+		{name: "晚于所有日期使用最新汇率", from: "USD", to: "CNY", date: day("2026-12-01"), want: 7.2},
+		{name: "早于所有日期使用最早汇率", from: "USD", to: "CNY", date: day("2026-01-01"), want: 7.1},
+		{name: "币种代码不区分大小写", from: "JPY", to: "CNY", date: day("2026-10-01"), want: 0.05},
+		{name: "本位币换算为外币", from: "CNY", to: "USD", date: day("2026-10-01"), want: 1 / 7.1},
+		{name: "两种外币通过本位币换算", from: "USD", to: "JPY", date: day("2026-10-01"), want: 7.1 / 0.05},
+		{name: "没有该币种汇率", from: "EUR", to: "CNY", date: day("2026-10-01"), wantErr: domain.ErrRateNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.Rate(context.Background(), tt.from, tt.to, tt.date)
+			if err != tt.wantErr {
+				t.Fatalf("Rate() error = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Rate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFileProviderIllegal(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "日期格式错误", content: `{"rates": {"2026/10/01": {"USD": 7.1}}}`},
+		{name: "汇率不大于 0", content: `{"rates": {"2026-10-01": {"USD": 0}}}`},
+		{name: "不是 JSON", content: `USD 7.1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newFileProvider(config.RateConfig{RateFile: writeRateFile(t, tt.content)}); err == nil {
+				t.Error("newFileProvider() error = nil, want error")
+			}
+		})
+	}
+	if _, err := newFileProvider(config.RateConfig{}); err == nil {
+		t.Error("newFileProvider() without RATE_FILE error = nil, want error")
+	}
+}
+
+func TestNewRateProviderSameCurrency(t *testing.T) {
+	provider, err := NewRateProvider(&config.Config{RateConfig: config.RateConfig{RateProvider: "none"}})
+	if err != nil {
+		t.Fatalf("NewRateProvider() error = %v", err)
+	}
+	if rate, err := provider.Rate(context.Background(), "USD", "USD", time.Now()); err != nil || rate != 1 {
+		t.Errorf("Rate() = %v, %v, want 1, nil", rate, err)
+	}
+	if _, err := provider.Rate(context.Background(), "USD", "CNY", time.Now()); err != domain.ErrRateNotFound {
+		t.Errorf("Rate() error = %v, want %v", err, domain.ErrRateNotFound)
+	}
+}
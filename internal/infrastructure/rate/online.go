@@ -0,0 +1,118 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+func init() {
+	Register("online", newOnlineProvider)
+}
+
+const (
+	// todayRateTTL 当天汇率会更新，缓存一段时间后重新查询
+	todayRateTTL = time.Hour
+	// failedRateTTL 查询失败后短时间内直接返回错误，避免接口不可用时每笔账单都等待超时
+	failedRateTTL = time.Minute
+)
+
+// onlineProvider 调用在线汇率接口，响应格式为 {"rates": {"CNY": 7.12}}，兼容 frankfurter、exchangerate.host 等接口。
+// 历史汇率不会变化，按日期缓存；当天汇率及查询失败只缓存一段时间
+type onlineProvider struct {
+	url    string
+	client *http.Client
+	cache  sync.Map
+}
+
+// rateEntry 缓存的汇率或查询错误，expireAt 为零值时不过期
+type rateEntry struct {
+	rate     float64
+	err      error
+	expireAt time.Time
+}
+
+type rateResp struct {
+	Rates map[string]float64 `json:"rates"`
+}
+
+func newOnlineProvider(cfg config.RateConfig) (domain.RateProvider, error) {
+	if cfg.RateAPIURL == "" {
+		return nil, fmt.Errorf("RATE_API_URL is required for online rate provider")
+	}
+	return &onlineProvider{
+		url:    cfg.RateAPIURL,
+		client: &http.Client{Timeout: cfg.RateTimeout},
+	}, nil
+}
+
+func (o *onlineProvider) Rate(ctx context.Context, from, to string, date time.Time) (float64, error) {
+	// 未来日期的账单使用最新汇率
+	now := time.Now()
+	if date.After(now) {
+		date = now
+	}
+	day := date.Format(rateDateLayout)
+	key := fmt.Sprintf("RATE:%s:%s:%s", day, from, to)
+	if v, ok := o.cache.Load(key); ok {
+		if e, ok := v.(rateEntry); ok && (e.expireAt.IsZero() || now.Before(e.expireAt)) {
+			return e.rate, e.err
+		}
+	}
+
+	rate, err := o.query(ctx, day, from, to)
+	if ctx.Err() != nil {
+		// 请求取消或超时不是接口的问题，不缓存
+		return rate, err
+	}
+	entry := rateEntry{rate: rate, err: err}
+	switch {
+	case err != nil:
+		entry.expireAt = now.Add(failedRateTTL)
+	case day == now.Format(rateDateLayout):
+		entry.expireAt = now.Add(todayRateTTL)
+	}
+	o.cache.Store(key, entry)
+	return rate, err
+}
+
+// query 查询 day 当天的汇率
+func (o *onlineProvider) query(ctx context.Context, day, from, to string) (float64, error) {
+	url := strings.NewReplacer("{date}", day, "{from}", from, "{to}", to).Replace(o.url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("query exchange rate fail! url:%s", url)
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		logrus.WithContext(ctx).Errorf("query exchange rate fail! url:%s, status:%d, body:%s", url, resp.StatusCode, body)
+		return 0, domain.ErrRateNotFound
+	}
+	var res rateResp
+	if err := json.Unmarshal(body, &res); err != nil {
+		return 0, err
+	}
+	rate, ok := res.Rates[to]
+	if !ok || rate <= 0 {
+		return 0, domain.ErrRateNotFound
+	}
+	return rate, nil
+}
@@ -0,0 +1,72 @@
+package rate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+func TestOnlineProviderCache(t *testing.T) {
+	hits := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from := r.URL.Query().Get("from")
+		hits[from]++
+		if from == "EUR" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"rates": {"CNY": 7.1}}`))
+	}))
+	defer srv.Close()
+
+	provider, err := newOnlineProvider(config.RateConfig{RateAPIURL: srv.URL + "/{date}?from={from}&to={to}", RateTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("newOnlineProvider() error = %v", err)
+	}
+	o := provider.(*onlineProvider)
+	ctx := context.Background()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		if rate, err := o.Rate(ctx, "USD", "CNY", day); err != nil || rate != 7.1 {
+			t.Fatalf("Rate() = %v, %v, want 7.1, nil", rate, err)
+		}
+		if _, err := o.Rate(ctx, "EUR", "CNY", day); err != domain.ErrRateNotFound {
+			t.Fatalf("Rate() error = %v, want %v", err, domain.ErrRateNotFound)
+		}
+	}
+	if hits["USD"] != 1 || hits["EUR"] != 1 {
+		t.Errorf("hits = %v, want one request per currency", hits)
+	}
+
+	// 查询失败及当天汇率过期后重新查询，历史汇率不过期
+	expired := time.Now().Add(-time.Second)
+	o.cache.Range(func(key, value interface{}) bool {
+		e := value.(rateEntry)
+		if !e.expireAt.IsZero() {
+			e.expireAt = expired
+			o.cache.Store(key, e)
+		}
+		return true
+	})
+	_, _ = o.Rate(ctx, "USD", "CNY", day)
+	_, _ = o.Rate(ctx, "EUR", "CNY", day)
+	if _, err := o.Rate(ctx, "USD", "CNY", time.Now()); err != nil {
+		t.Fatalf("Rate() today error = %v", err)
+	}
+	o.cache.Range(func(key, value interface{}) bool {
+		if e := value.(rateEntry); e.err == nil && !e.expireAt.IsZero() {
+			e.expireAt = expired
+			o.cache.Store(key, e)
+		}
+		return true
+	})
+	_, _ = o.Rate(ctx, "USD", "CNY", time.Now())
+	if hits["USD"] != 3 || hits["EUR"] != 2 {
+		t.Errorf("hits = %v, want USD 3, EUR 2", hits)
+	}
+}
@@ -0,0 +1,84 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+type ProviderFactory func(cfg config.RateConfig) (domain.RateProvider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]ProviderFactory)
+)
+
+// Register 注册汇率 provider，name 对应配置项 RATE_PROVIDER
+func Register(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if _, dup := providers[name]; dup {
+		panic(fmt.Sprintf("rate: register provider %s twice", name))
+	}
+	providers[name] = factory
+}
+
+// NewRateProvider 按 RATE_PROVIDER 创建汇率 provider，相同币种的汇率始终为 1
+func NewRateProvider(cfg *config.Config) (domain.RateProvider, error) {
+	providersMu.RLock()
+	factory, ok := providers[cfg.RateProvider]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown rate provider %s, available: %s", cfg.RateProvider, strings.Join(providerNames(), ","))
+	}
+	provider, err := factory(cfg.RateConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &sameCurrency{provider: provider}, nil
+}
+
+func providerNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("none", newNoneProvider)
+}
+
+// sameCurrency 相同币种无需查询汇率
+type sameCurrency struct {
+	provider domain.RateProvider
+}
+
+func (s *sameCurrency) Rate(ctx context.Context, from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	return s.provider.Rate(ctx, from, to, date)
+}
+
+// noneProvider 未配置汇率来源，不支持外币换算
+type noneProvider struct {
+}
+
+func newNoneProvider(cfg config.RateConfig) (domain.RateProvider, error) {
+	return &noneProvider{}, nil
+}
+
+func (n *noneProvider) Rate(ctx context.Context, from, to string, date time.Time) (float64, error) {
+	return 0, domain.ErrRateNotFound
+}
@@ -0,0 +1,315 @@
+package rule
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+// currencyUnit 金额后的币种，如 5 美元、30 USD
+var currencyUnit = currencyPattern()
+
+var (
+	// amountFirst 如: +5000 工资、5美元 咖啡
+	amountFirst = regexp.MustCompile(`^([+]?\d+(?:\.\d+)?)\s*(?:` + currencyUnit + `|元|块)?\s*(\D+)$`)
+	// remarkAmount 如: 包子 15、早餐12 午饭35.5元、咖啡 5 美元
+	remarkAmount = regexp.MustCompile(`([^\d\s+.]+?)\s*(?:花了|花费|收入)?\s*([+]?\d+(?:\.\d+)?)\s*(?:` + currencyUnit + `|元|块)?`)
+	identity     = regexp.MustCompile(`^(?:我叫|叫我|我是)\s*(\S+)$`)
+	// identityQuestion 如: 我是谁、我叫什么？，是提问而不是设置称呼
+	identityQuestion = regexp.MustCompile(`谁|什么|啥|[?？]$`)
+	// budget 如: 预算 5000、设置餐饮预算 1000
+	budget = regexp.MustCompile(`^(?:设置|设定)?\s*(\S*?)\s*预算\s*(\d+(?:\.\d+)?)\s*(?:元|块)?$`)
+	// recurring 如: 每月1号房租 3000、每月15日 工资 +20000
+	recurring = regexp.MustCompile(`^每月\s*(\d{1,2})\s*(?:号|日)\s*(\D+?)\s*([+]?\d+(?:\.\d+)?)\s*(?:元|块)?$`)
+	// subscribeReport 如: 订阅日报、订阅周报 20点
+	subscribeReport = regexp.MustCompile(`^(?:订阅|开启)\s*(日报|周报|月报)\s*(?:(\d{1,2})\s*(?:点|时))?$`)
+	// unsubscribeReport 如: 取消日报、取消订阅周报
+	unsubscribeReport = regexp.MustCompile(`^(?:取消|关闭)\s*(?:订阅)?\s*(日报|周报|月报)$`)
+	// reminder 如: 关闭提醒、开启记账提醒
+	reminder = regexp.MustCompile(`^(开启|打开|关闭|取消)\s*(?:记账)?提醒$`)
+	// inviteMember 如: 邀请家人、邀请室友 只读
+	inviteMember = regexp.MustCompile(`^邀请(?:家人|成员|室友|朋友)\s*(只读|查看)?$`)
+	// joinLedger 如: 加入账本 ABC123
+	joinLedger = regexp.MustCompile(`^加入账本\s*([0-9A-Za-z]+)$`)
+	// createLedger 如: 新建账本 副业
+	createLedger = regexp.MustCompile(`^(?:新建|创建)账本\s*(\S+)$`)
+	// switchLedger 如: 切换账本 家庭、切换到账本 2
+	switchLedger = regexp.MustCompile(`^切换(?:到)?账本\s*(\S+)$`)
+	// renameLedger 如: 账本改名 家庭
+	renameLedger = regexp.MustCompile(`^(?:账本改名|账本重命名|重命名账本)(?:为)?\s*(\S+)$`)
+	// splitBill 如: AA 晚饭 300 我和小王、AA 房租 3000 我和小王 按2:1、AA 聚餐 500 我200 小王300
+	splitBill = regexp.MustCompile(`^(?i:AA|均摊|分摊)\s*(\D+?)\s*(\d+(?:\.\d+)?)\s*(?:` + currencyUnit + `|元|块)?\s+(.+?)(?:\s*按\s*(\d+(?:\.\d+)?(?:\s*[:：]\s*\d+(?:\.\d+)?)+))?$`)
+	// splitShare 指定金额的参与人，如: 小王300
+	splitShare = regexp.MustCompile(`^(\D+?)(\d+(?:\.\d+)?)$`)
+	// baseCurrency 如: 本位币 美元、设置本位币 USD
+	baseCurrency = regexp.MustCompile(`^(?:设置|设定)?本位币\s*(?:为)?\s*(\S+)$`)
+	// deleteRecurring 如: 删除周期账单 房租
+	deleteRecurring = regexp.MustCompile(`^(?:删除|取消)(?:周期账单|定期账单|每月)\s*(\S+)$`)
+)
+
+// reportFrequencies 报告名称与频率
+var reportFrequencies = map[string]string{
+	"日报": domain.ReportDaily,
+	"周报": domain.ReportWeekly,
+	"月报": domain.ReportMonthly,
+}
+
+var commands = []struct {
+	name     string
+	keywords []string
+}{
+	{name: "undo_last", keywords: []string{"撤销", "撤回", "撤销上一笔", "删除上一笔"}},
+	{name: "get_ledger", keywords: []string{"账本", "查看账本", "我的账本"}},
+	{name: "get_category", keywords: []string{"分类", "查看分类"}},
+	{name: "get_source_code", keywords: []string{"源码", "源代码"}},
+	{name: "export_bill", keywords: []string{"导出", "导出账单", "导出本月账单"}},
+	{name: "list_recurring_bill", keywords: []string{"周期账单", "定期账单", "查看周期账单"}},
+	{name: "list_ledger", keywords: []string{"账本列表", "所有账本", "切换账本"}},
+	{name: "list_member", keywords: []string{"账本成员", "查看成员", "成员"}},
+	{name: "list_report_subscription", keywords: []string{"我的订阅", "查看订阅"}},
+	{name: "get_api_token", keywords: []string{"令牌", "接口令牌", "token"}},
+	{name: "settle_up", keywords: []string{"结算", "谁欠谁", "算账", "分摊结算"}},
+	{name: "query_member_spending", keywords: []string{"谁花得最多", "谁花的最多", "成员支出", "成员收支", "每人花了多少"}},
+	{name: "query_bill", keywords: []string{"查账", "账单", "查询", "本月", "统计"}},
+}
+
+// categories 关键字分类字典，按顺序匹配
+var categories = []struct {
+	category string
+	expenses common.Expenses
+	keywords []string
+}{
+	{category: "工资", expenses: common.Income, keywords: []string{"工资", "薪水", "奖金", "年终"}},
+	{category: "其他收入", expenses: common.Income, keywords: []string{"收入", "报销", "退款", "理财", "利息", "收红包"}},
+	{category: "餐饮", expenses: common.Pay, keywords: []string{"早餐", "早饭", "午餐", "午饭", "晚餐", "晚饭", "夜宵", "包子", "外卖", "咖啡", "奶茶", "饮料", "零食", "水果", "饭", "面", "餐"}},
+	{category: "交通", expenses: common.Pay, keywords: []string{"打车", "地铁", "公交", "加油", "停车", "高铁", "火车", "机票", "滴滴", "油费", "过路费"}},
+	{category: "住房", expenses: common.Pay, keywords: []string{"房租", "水费", "电费", "燃气", "物业", "房贷"}},
+	{category: "通讯", expenses: common.Pay, keywords: []string{"话费", "流量", "宽带"}},
+	{category: "购物", expenses: common.Pay, keywords: []string{"超市", "衣服", "鞋", "淘宝", "京东", "日用"}},
+	{category: "娱乐", expenses: common.Pay, keywords: []string{"电影", "游戏", "KTV", "演出", "门票", "会员"}},
+	{category: "医疗", expenses: common.Pay, keywords: []string{"药", "医院", "挂号", "体检"}},
+}
+
+const defaultCategory = "其他"
+
+type parser struct {
+}
+
+func NewParser() domain.IntentParser {
+	return &parser{}
+}
+
+func (p *parser) Parse(ctx context.Context, content string) (*domain.AIFunctionCall, bool) {
+	s := normalize(content)
+	if s == "" {
+		return nil, false
+	}
+	for _, c := range commands {
+		for _, k := range c.keywords {
+			if s == k {
+				return call(c.name, map[string]interface{}{})
+			}
+		}
+	}
+	if m := budget.FindStringSubmatch(s); m != nil {
+		category := strings.TrimPrefix(m[1], "每月")
+		// 总预算、月预算等均为总预算
+		if category == "总" || category == "月" || category == "月度" || category == "本月" {
+			category = ""
+		}
+		return call("set_budget", map[string]interface{}{"category": category, "amount": m[2]})
+	}
+	if m := recurring.FindStringSubmatch(s); m != nil {
+		args := bookkeeping(strings.TrimSpace(m[2]), m[3], "")
+		args["day"] = m[1]
+		return call("add_recurring_bill", args)
+	}
+	if m := reminder.FindStringSubmatch(s); m != nil {
+		enabled := m[1] == "开启" || m[1] == "打开"
+		return call("set_reminder", map[string]interface{}{"enabled": strconv.FormatBool(enabled)})
+	}
+	if m := createLedger.FindStringSubmatch(s); m != nil {
+		return call("create_ledger", map[string]interface{}{"name": m[1]})
+	}
+	if m := switchLedger.FindStringSubmatch(s); m != nil {
+		return call("switch_ledger", map[string]interface{}{"name": m[1]})
+	}
+	if m := renameLedger.FindStringSubmatch(s); m != nil {
+		return call("rename_ledger", map[string]interface{}{"name": m[1]})
+	}
+	if m := inviteMember.FindStringSubmatch(s); m != nil {
+		role := domain.LedgerEditor
+		if m[1] != "" {
+			role = domain.LedgerViewer
+		}
+		return call("invite_member", map[string]interface{}{"role": role})
+	}
+	if m := joinLedger.FindStringSubmatch(s); m != nil {
+		return call("join_ledger", map[string]interface{}{"code": strings.ToUpper(m[1])})
+	}
+	if f, ok := reportFrequencies[s]; ok {
+		return call("get_report", map[string]interface{}{"frequency": f})
+	}
+	if m := subscribeReport.FindStringSubmatch(s); m != nil {
+		return call("subscribe_report", map[string]interface{}{"frequency": reportFrequencies[m[1]], "hour": m[2]})
+	}
+	if m := unsubscribeReport.FindStringSubmatch(s); m != nil {
+		return call("unsubscribe_report", map[string]interface{}{"frequency": reportFrequencies[m[1]]})
+	}
+	if m := deleteRecurring.FindStringSubmatch(s); m != nil {
+		return call("delete_recurring_bill", map[string]interface{}{"remark": m[1]})
+	}
+	if m := splitBill.FindStringSubmatch(s); m != nil {
+		if args, ok := split(strings.TrimSpace(m[1]), m[2], currency(m[3]), m[4], m[5]); ok {
+			return call("split_bill", args)
+		}
+	}
+	if m := identity.FindStringSubmatch(s); m != nil && !identityQuestion.MatchString(m[1]) {
+		return call("get_user_identity", map[string]interface{}{"name": m[1]})
+	}
+	if m := baseCurrency.FindStringSubmatch(s); m != nil {
+		return call("set_base_currency", map[string]interface{}{"currency": m[1]})
+	}
+	if m := amountFirst.FindStringSubmatch(s); m != nil {
+		return call("bookkeeping", bookkeeping(strings.TrimSpace(m[3]), m[1], currency(m[2])))
+	}
+
+	matches := remarkAmount.FindAllStringSubmatch(s, -1)
+	// 除金额和名称外不能包含其他内容，避免误判
+	if len(matches) == 0 || strings.TrimSpace(remarkAmount.ReplaceAllString(s, "")) != "" {
+		return nil, false
+	}
+	if len(matches) == 1 {
+		return call("bookkeeping", bookkeeping(matches[0][1], matches[0][2], currency(matches[0][3])))
+	}
+	bills := make([]map[string]interface{}, 0, len(matches))
+	for _, m := range matches {
+		bills = append(bills, bookkeeping(m[1], m[2], currency(m[3])))
+	}
+	return call("batch_bookkeeping", map[string]interface{}{"bills": bills})
+}
+
+func bookkeeping(remark, amount, currency string) map[string]interface{} {
+	expenses := common.ConfirmExpenses(amount)
+	category, e := classify(remark)
+	if e == common.Income {
+		expenses = common.Income
+	}
+	if expenses == common.Income && e != common.Income {
+		category = "其他收入"
+	}
+	return map[string]interface{}{
+		"remark":   remark,
+		"amount":   strconv.FormatFloat(common.ParseAmount(amount), 'f', -1, 64),
+		"expenses": string(expenses),
+		"category": category,
+		"currency": currency,
+	}
+}
+
+// currencyPattern 匹配币种名称、符号及代码的正则分组，代码不区分大小写
+func currencyPattern() string {
+	names := domain.CurrencyNames()
+	for i, it := range names {
+		names[i] = regexp.QuoteMeta(it)
+	}
+	return `(?i:(` + strings.Join(names, "|") + `))`
+}
+
+// currency 币种名称归一为代码，未指定时为空
+func currency(name string) string {
+	if code, ok := domain.ParseCurrency(name); ok {
+		return code
+	}
+	return ""
+}
+
+// split 解析分摊参与人，参与人之后为比例时按比例分摊，参与人带金额时按指定金额分摊
+func split(remark, amount, currency, participants, ratio string) (map[string]interface{}, bool) {
+	tokens := strings.FieldsFunc(participants, func(r rune) bool {
+		return r == ' ' || r == '和' || r == '与' || r == '跟' || r == '、' || r == '/'
+	})
+	names := make([]string, 0, len(tokens))
+	shares := make([]string, 0, len(tokens))
+	for _, it := range tokens {
+		if _, err := strconv.ParseFloat(it, 64); err == nil && len(names) > len(shares) {
+			// 「小王 300」形式，金额属于前一个参与人
+			shares = append(shares, it)
+			continue
+		}
+		if m := splitShare.FindStringSubmatch(it); m != nil {
+			names = append(names, m[1])
+			shares = append(shares, m[2])
+			continue
+		}
+		if len(names) > len(shares) && len(shares) > 0 {
+			return nil, false
+		}
+		names = append(names, it)
+	}
+	mode := domain.SplitEqual
+	switch {
+	case len(shares) > 0:
+		if len(shares) != len(names) || ratio != "" {
+			return nil, false
+		}
+		mode = domain.SplitExact
+	case ratio != "":
+		shares = strings.FieldsFunc(ratio, func(r rune) bool {
+			return r == ':' || r == '：' || r == ' '
+		})
+		mode = domain.SplitRatio
+	}
+	if len(names) == 0 {
+		return nil, false
+	}
+	category, _ := classify(remark)
+	return map[string]interface{}{
+		"remark":       remark,
+		"amount":       amount,
+		"category":     category,
+		"currency":     currency,
+		"participants": names,
+		"mode":         mode,
+		"shares":       shares,
+	}, true
+}
+
+func classify(remark string) (string, common.Expenses) {
+	for _, c := range categories {
+		for _, k := range c.keywords {
+			if strings.Contains(remark, k) {
+				return c.category, c.expenses
+			}
+		}
+	}
+	return defaultCategory, common.Pay
+}
+
+func normalize(content string) string {
+	s := strings.NewReplacer("＋", "+", "，", " ", ",", " ", "。", "", "；", " ", ";", " ", "　", " ").Replace(content)
+	s = strings.Map(func(r rune) rune {
+		// 全角数字转半角
+		if r >= '０' && r <= '９' {
+			return r - '０' + '0'
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+func call(name string, args interface{}) (*domain.AIFunctionCall, bool) {
+	arguments, err := json.Marshal(args)
+	if err != nil {
+		return nil, false
+	}
+	return &domain.AIFunctionCall{
+		Name:      name,
+		Arguments: string(arguments),
+	}, true
+}
@@ -0,0 +1,65 @@
+package rule
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+		// args 需要校验的参数，未列出的参数不校验
+		args map[string]interface{}
+	}{
+		{content: "午饭 25", want: "bookkeeping", args: map[string]interface{}{"remark": "午饭", "amount": "25", "expenses": "支出", "category": "餐饮", "currency": ""}},
+		{content: "+5000 工资", want: "bookkeeping", args: map[string]interface{}{"remark": "工资", "amount": "5000", "expenses": "收入", "category": "工资"}},
+		{content: "咖啡 5 美元", want: "bookkeeping", args: map[string]interface{}{"remark": "咖啡", "amount": "5", "currency": "USD"}},
+		{content: "拉面 1000日元", want: "bookkeeping", args: map[string]interface{}{"remark": "拉面", "amount": "1000", "currency": "JPY"}},
+		{content: "早餐１２，午饭35.5元", want: "batch_bookkeeping"},
+		{content: "撤销", want: "undo_last"},
+		{content: "查账", want: "query_bill"},
+		{content: "我叫老王", want: "get_user_identity", args: map[string]interface{}{"name": "老王"}},
+		{content: "我是谁", want: ""},
+		{content: "我叫什么？", want: ""},
+		{content: "我是老王?", want: ""},
+		{content: "餐饮预算 1000", want: "set_budget", args: map[string]interface{}{"category": "餐饮", "amount": "1000"}},
+		{content: "月预算 5000", want: "set_budget", args: map[string]interface{}{"category": "", "amount": "5000"}},
+		{content: "每月1号房租 3000", want: "add_recurring_bill", args: map[string]interface{}{"remark": "房租", "amount": "3000", "day": "1"}},
+		{content: "订阅周报 20点", want: "subscribe_report", args: map[string]interface{}{"frequency": "weekly", "hour": "20"}},
+		{content: "关闭提醒", want: "set_reminder", args: map[string]interface{}{"enabled": "false"}},
+		{content: "加入账本 abc123", want: "join_ledger", args: map[string]interface{}{"code": "ABC123"}},
+		{content: "本位币 美元", want: "set_base_currency", args: map[string]interface{}{"currency": "美元"}},
+		{content: "AA 晚饭 90 老王 小李", want: "split_bill", args: map[string]interface{}{"remark": "晚饭", "amount": "90", "mode": "equal", "participants": []interface{}{"老王", "小李"}}},
+		{content: "AA 房租 3000 我和小王 按2:1", want: "split_bill", args: map[string]interface{}{"mode": "ratio", "participants": []interface{}{"我", "小王"}, "shares": []interface{}{"2", "1"}}},
+		{content: "AA 聚餐 500 我200 小王300", want: "split_bill", args: map[string]interface{}{"mode": "exact", "participants": []interface{}{"我", "小王"}, "shares": []interface{}{"200", "300"}}},
+		{content: "今天天气不错", want: ""},
+		{content: "", want: ""},
+	}
+	p := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			got, ok := p.Parse(context.Background(), tt.content)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("Parse() = %+v, want not matched", got)
+				}
+				return
+			}
+			if !ok || got.Name != tt.want {
+				t.Fatalf("Parse() = %+v, %v, want %s", got, ok, tt.want)
+			}
+			var args map[string]interface{}
+			if err := json.Unmarshal([]byte(got.Arguments), &args); err != nil {
+				t.Fatalf("unmarshal arguments error = %v", err)
+			}
+			for k, v := range tt.args {
+				if !reflect.DeepEqual(args[k], v) {
+					t.Errorf("Parse() args[%s] = %v, want %v", k, args[k], v)
+				}
+			}
+		})
+	}
+}
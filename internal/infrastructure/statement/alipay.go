@@ -0,0 +1,29 @@
+package statement
+
+import (
+	"strings"
+
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+// alipay 支付宝 App 导出的交易明细，旧版网页导出的列名作为备选
+var alipay = source{
+	name: domain.StatementAlipay,
+	columns: columns{
+		date:        []string{"交易时间", "交易创建时间"},
+		category:    []string{"交易分类", "类型"},
+		counterpart: []string{"交易对方"},
+		goods:       []string{"商品说明", "商品名称"},
+		direction:   []string{"收/支"},
+		amount:      []string{"金额", "金额（元）"},
+		status:      []string{"交易状态"},
+		id:          []string{"交易订单号", "交易号"},
+	},
+	isRefund: func(r record, c columns) bool {
+		return r.get(c.status) == "退款成功" || strings.HasPrefix(r.get(c.goods), "退款")
+	},
+	isClosed: func(r record, c columns) bool {
+		status := r.get(c.status)
+		return status == "交易关闭" || strings.Contains(status, "失败")
+	},
+}
@@ -0,0 +1,234 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// refundCategory 退款统一记为收入
+const refundCategory = "退款"
+
+var dateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/1/2 15:04:05",
+	"2006/1/2 15:04",
+}
+
+// columns 各来源的列名，兼容新旧版本导出文件，按顺序匹配
+type columns struct {
+	date        []string
+	category    []string
+	counterpart []string
+	goods       []string
+	direction   []string
+	amount      []string
+	status      []string
+	id          []string
+}
+
+// record 按列名读取的一行流水
+type record map[string]string
+
+func (r record) get(names []string) string {
+	for _, n := range names {
+		if v, ok := r[n]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+type source struct {
+	name    string
+	columns columns
+	// isRefund 判断是否为退款记录
+	isRefund func(r record, c columns) bool
+	// isClosed 判断是否为未成功的交易
+	isClosed func(r record, c columns) bool
+}
+
+var sources = []source{alipay, wechat}
+
+type parser struct {
+}
+
+func NewParser() domain.StatementParser {
+	return &parser{}
+}
+
+func (p *parser) Parse(r io.Reader) (*domain.Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err = decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// 跳过导出信息等前置说明，定位表头
+	for i, row := range rows {
+		header := trimAll(row)
+		src, ok := detect(header)
+		if !ok {
+			continue
+		}
+		return src.parse(header, rows[i+1:])
+	}
+	return nil, domain.ErrUnknownStatement
+}
+
+// decode 支付宝导出为 GBK 编码，微信支付为带 BOM 的 UTF-8
+func decode(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if utf8.Valid(data) {
+		return data, nil
+	}
+	return simplifiedchinese.GBK.NewDecoder().Bytes(data)
+}
+
+func detect(header []string) (source, bool) {
+	has := make(map[string]bool, len(header))
+	for _, h := range header {
+		has[h] = true
+	}
+	contains := func(names []string) bool {
+		for _, n := range names {
+			if has[n] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, src := range sources {
+		c := src.columns
+		if contains(c.date) && contains(c.amount) && contains(c.direction) && contains(c.id) {
+			return src, true
+		}
+	}
+	return source{}, false
+}
+
+func (s source) parse(header []string, rows [][]string) (*domain.Statement, error) {
+	st := &domain.Statement{
+		Source: s.name,
+		Bills:  make([]*domain.Bill, 0, len(rows)),
+	}
+	for _, row := range rows {
+		row = trimAll(row)
+		// 支付宝文件末尾以 ---- 分隔的汇总说明
+		if len(row) == 0 || row[0] == "" || strings.HasPrefix(row[0], "-") {
+			continue
+		}
+		r := make(record, len(header))
+		for i, h := range header {
+			if i < len(row) {
+				r[h] = row[i]
+			}
+		}
+		bill, ok := s.toBill(r)
+		if !ok {
+			st.Skipped++
+			continue
+		}
+		st.Bills = append(st.Bills, bill)
+	}
+	return st, nil
+}
+
+func (s source) toBill(r record) (*domain.Bill, bool) {
+	c := s.columns
+	if s.isClosed(r, c) {
+		return nil, false
+	}
+	date, ok := parseDate(r.get(c.date))
+	if !ok {
+		return nil, false
+	}
+	amount, ok := parseAmount(r.get(c.amount))
+	if !ok {
+		return nil, false
+	}
+
+	bill := &domain.Bill{
+		Remark:        remark(r.get(c.goods), r.get(c.counterpart)),
+		Amount:        amount,
+		Date:          date.UnixNano() / 1e6,
+		TransactionID: r.get(c.id),
+	}
+	direction := r.get(c.direction)
+	switch {
+	case s.isRefund(r, c) && direction != string(common.Pay):
+		bill.Expenses = string(common.Income)
+		bill.Categories = []string{refundCategory}
+	case direction == string(common.Pay) || direction == string(common.Income):
+		bill.Expenses = direction
+		bill.Categories = []string{category(r.get(c.category))}
+	default:
+		// 不计收支，如余额宝转入、零钱提现等
+		return nil, false
+	}
+	return bill, true
+}
+
+func parseDate(v string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseAmount 兼容 ¥25.00、1,000.00 等格式
+func parseAmount(v string) (float64, bool) {
+	v = strings.NewReplacer("¥", "", "￥", "", ",", "", " ", "").Replace(v)
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f == 0 {
+		return 0, false
+	}
+	return math.Abs(f), true
+}
+
+// remark 优先使用商品说明，缺失时使用交易对方
+func remark(goods, counterpart string) string {
+	if goods == "" || goods == "/" {
+		return counterpart
+	}
+	return goods
+}
+
+func category(v string) string {
+	if v == "" || v == "/" {
+		return "其他"
+	}
+	return v
+}
+
+// trimAll 去除单元格首尾的空白及制表符，导出文件中的单号以 \t 结尾防止被识别为数字
+func trimAll(row []string) []string {
+	res := make([]string, len(row))
+	for i, v := range row {
+		res[i] = strings.TrimSpace(v)
+	}
+	return res
+}
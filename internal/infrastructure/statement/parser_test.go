@@ -0,0 +1,142 @@
+package statement
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wangyuheng/richman/internal/domain"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const alipayCSV = `------------------------------------------------------------------------------------
+导出信息:
+姓名：老王
+起始时间：[2026-10-01 00:00:00]    终止时间：[2026-10-31 23:59:59]
+------------------------支付宝（中国）网络技术有限公司  电子客户回单------------------------
+交易时间,交易分类,交易对方,对方账号,商品说明,收/支,金额,收/付款方式,交易状态,交易订单号,商家订单号,备注,
+2026-10-01 12:30:00,餐饮美食,兰州拉面,/,牛肉面,支出,25.00,花呗,交易成功,2026100122001	,M001	,,
+2026-10-02 09:00:00,退款,兰州拉面,/,退款-牛肉面,不计收支,25.00,花呗,退款成功,2026100222002	,M001	,,
+2026-10-03 10:00:00,投资理财,余额宝,/,余额宝-自动转入,不计收支,"1,000.00",余额,交易成功,2026100322003	,,,
+2026-10-04 18:00:00,转账红包,小李,/,收钱码收款,收入,88.00,,交易成功,2026100422004	,,,
+2026-10-05 20:00:00,日用百货,超市,/,/,支出,30.00,余额,交易关闭,2026100522005	,,,
+------------------------------------------------------------------------------------
+`
+
+const wechatCSV = "\xEF\xBB\xBF" + `微信支付账单明细,,,,,,,,,,
+微信昵称：[老王],,,,,,,,,,
+----------------------微信支付账单明细列表--------------------,,,,,,,,,,
+交易时间,交易类型,交易对方,商品,收/支,金额(元),支付方式,当前状态,交易单号,商户单号,备注
+2026-10-01 08:00:00,商户消费,便利店,咖啡,支出,¥12.50,零钱,支付成功,4200001	,,/
+2026-10-02 08:00:00,商户消费-退款,便利店,/,收入,¥12.50,零钱,已全额退款,4200002	,,/
+2026-10-03 08:00:00,零钱提现,招商银行,/,/,¥100.00,零钱,提现已到账,4200003	,,/
+2026-10-04 08:00:00,转账,小李,/,支出,¥50.00,零钱,对方已退还,4200004	,,/
+`
+
+func gbk(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func millis(s string) int64 {
+	d, _ := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+	return d.UnixNano() / 1e6
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantSource  string
+		wantBills   []*domain.Bill
+		wantSkipped int
+	}{
+		{
+			name:       "支付宝 GBK 编码，跳过导出说明、不计收支及交易关闭",
+			data:       gbk(t, alipayCSV),
+			wantSource: domain.StatementAlipay,
+			wantBills: []*domain.Bill{
+				{Remark: "牛肉面", Amount: 25, Date: millis("2026-10-01 12:30:00"), TransactionID: "2026100122001", Expenses: "支出", Categories: []string{"餐饮美食"}},
+				{Remark: "退款-牛肉面", Amount: 25, Date: millis("2026-10-02 09:00:00"), TransactionID: "2026100222002", Expenses: "收入", Categories: []string{refundCategory}},
+				{Remark: "收钱码收款", Amount: 88, Date: millis("2026-10-04 18:00:00"), TransactionID: "2026100422004", Expenses: "收入", Categories: []string{"转账红包"}},
+			},
+			wantSkipped: 2,
+		},
+		{
+			name:       "支付宝 UTF-8 编码",
+			data:       []byte(alipayCSV),
+			wantSource: domain.StatementAlipay,
+			wantBills: []*domain.Bill{
+				{Remark: "牛肉面", Amount: 25, Date: millis("2026-10-01 12:30:00"), TransactionID: "2026100122001", Expenses: "支出", Categories: []string{"餐饮美食"}},
+				{Remark: "退款-牛肉面", Amount: 25, Date: millis("2026-10-02 09:00:00"), TransactionID: "2026100222002", Expenses: "收入", Categories: []string{refundCategory}},
+				{Remark: "收钱码收款", Amount: 88, Date: millis("2026-10-04 18:00:00"), TransactionID: "2026100422004", Expenses: "收入", Categories: []string{"转账红包"}},
+			},
+			wantSkipped: 2,
+		},
+		{
+			name:       "微信支付带 BOM，退款记为收入，商品为 / 时使用交易对方",
+			data:       []byte(wechatCSV),
+			wantSource: domain.StatementWechat,
+			wantBills: []*domain.Bill{
+				{Remark: "咖啡", Amount: 12.5, Date: millis("2026-10-01 08:00:00"), TransactionID: "4200001", Expenses: "支出", Categories: []string{"商户消费"}},
+				{Remark: "便利店", Amount: 12.5, Date: millis("2026-10-02 08:00:00"), TransactionID: "4200002", Expenses: "收入", Categories: []string{refundCategory}},
+				{Remark: "小李", Amount: 50, Date: millis("2026-10-04 08:00:00"), TransactionID: "4200004", Expenses: "支出", Categories: []string{"转账"}},
+			},
+			wantSkipped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := NewParser().Parse(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if st.Source != tt.wantSource {
+				t.Errorf("Parse() source = %s, want %s", st.Source, tt.wantSource)
+			}
+			if !reflect.DeepEqual(st.Bills, tt.wantBills) {
+				for _, it := range st.Bills {
+					t.Logf("got %+v", it)
+				}
+				t.Errorf("Parse() bills mismatch")
+			}
+			if st.Skipped != tt.wantSkipped {
+				t.Errorf("Parse() skipped = %d, want %d", st.Skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestParseUnknown(t *testing.T) {
+	_, err := NewParser().Parse(strings.NewReader("日期,金额\n2026-10-01,25\n"))
+	if err != domain.ErrUnknownStatement {
+		t.Errorf("Parse() error = %v, want %v", err, domain.ErrUnknownStatement)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{in: "25.00", want: 25, ok: true},
+		{in: "¥1,000.50", want: 1000.5, ok: true},
+		{in: "￥ 8", want: 8, ok: true},
+		{in: "-12.5", want: 12.5, ok: true},
+		{in: "0.00"},
+		{in: "abc"},
+	}
+	for _, tt := range tests {
+		got, ok := parseAmount(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseAmount(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package statement
+
+import (
+	"strings"
+
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+// wechat 微信支付账单明细，退款为单独的一行，交易类型以 -退款 结尾
+var wechat = source{
+	name: domain.StatementWechat,
+	columns: columns{
+		date:        []string{"交易时间"},
+		category:    []string{"交易类型"},
+		counterpart: []string{"交易对方"},
+		goods:       []string{"商品"},
+		direction:   []string{"收/支"},
+		amount:      []string{"金额(元)", "金额（元）"},
+		status:      []string{"当前状态"},
+		id:          []string{"交易单号"},
+	},
+	isRefund: func(r record, c columns) bool {
+		return strings.Contains(r.get(c.category), "退款")
+	},
+	isClosed: func(r record, c columns) bool {
+		status := r.get(c.status)
+		return strings.Contains(status, "失败") || strings.Contains(status, "已关闭")
+	},
+}
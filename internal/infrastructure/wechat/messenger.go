@@ -0,0 +1,140 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	errCodeInvalidToken = 40001
+	errCodeTokenExpired = 42001
+	// tokenRefreshAhead 提前刷新 access_token，避免临界时间失效
+	tokenRefreshAhead = 5 * time.Minute
+)
+
+type apiResp struct {
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type textMessage struct {
+	ToUser  string `json:"touser"`
+	MsgType string `json:"msgtype"`
+	Text    struct {
+		Content string `json:"content"`
+	} `json:"text"`
+}
+
+// messenger 通过客服消息接口主动回复用户
+type messenger struct {
+	apiURL    string
+	appID     string
+	appSecret string
+	client    *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expireAt    time.Time
+}
+
+func NewMessenger(cfg *config.Config) domain.Messenger {
+	return &messenger{
+		apiURL:    strings.TrimSuffix(cfg.WechatAPIURL, "/"),
+		appID:     cfg.WechatAppID,
+		appSecret: cfg.WechatAppSecret,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (m *messenger) SendText(ctx context.Context, UID, content string) error {
+	msg := textMessage{
+		ToUser:  UID,
+		MsgType: "text",
+	}
+	msg.Text.Content = content
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	for retry := 0; ; retry++ {
+		token, err := m.token(ctx)
+		if err != nil {
+			return err
+		}
+		var res apiResp
+		err = m.do(ctx, http.MethodPost, fmt.Sprintf("%s/cgi-bin/message/custom/send?access_token=%s", m.apiURL, url.QueryEscape(token)), payload, &res)
+		if err != nil {
+			return err
+		}
+		if (res.ErrCode == errCodeInvalidToken || res.ErrCode == errCodeTokenExpired) && retry == 0 {
+			m.invalidate(token)
+			continue
+		}
+		if res.ErrCode != 0 {
+			logrus.WithContext(ctx).Errorf("send wechat custom message fail! uid:%s, resp:%+v", UID, res)
+			return fmt.Errorf("send wechat custom message fail: %d %s", res.ErrCode, res.ErrMsg)
+		}
+		return nil
+	}
+}
+
+// token 获取 access_token，过期前自动刷新
+func (m *messenger) token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.accessToken != "" && time.Now().Before(m.expireAt) {
+		return m.accessToken, nil
+	}
+
+	var res apiResp
+	err := m.do(ctx, http.MethodGet, fmt.Sprintf("%s/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s",
+		m.apiURL, url.QueryEscape(m.appID), url.QueryEscape(m.appSecret)), nil, &res)
+	if err != nil {
+		return "", err
+	}
+	if res.ErrCode != 0 || res.AccessToken == "" {
+		logrus.WithContext(ctx).Errorf("get wechat access token fail! resp:%+v", res)
+		return "", fmt.Errorf("get wechat access token fail: %d %s", res.ErrCode, res.ErrMsg)
+	}
+	m.accessToken = res.AccessToken
+	m.expireAt = time.Now().Add(time.Duration(res.ExpiresIn)*time.Second - tokenRefreshAhead)
+	return m.accessToken, nil
+}
+
+func (m *messenger) invalidate(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.accessToken == token {
+		m.accessToken = ""
+	}
+}
+
+func (m *messenger) do(ctx context.Context, method, u string, payload []byte, res interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("call wechat api err! method:%s", method)
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(res)
+}
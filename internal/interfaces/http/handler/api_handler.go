@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// apiOperatorKey 鉴权通过后保存当前用户
+	apiOperatorKey  = "API_OPERATOR"
+	apiDateLayout   = "2006-01-02"
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// APIHandler 开放接口，通过 Authorization: Bearer <token> 鉴权
+type APIHandler interface {
+	Auth(ctx *gin.Context)
+	GetMe(ctx *gin.Context)
+	ListLedgers(ctx *gin.Context)
+	ListBills(ctx *gin.Context)
+	GetBill(ctx *gin.Context)
+	CreateBill(ctx *gin.Context)
+	UpdateBill(ctx *gin.Context)
+	DeleteBill(ctx *gin.Context)
+	GetReport(ctx *gin.Context)
+}
+
+type apiHandler struct {
+	authUseCase   usecase.AuthUseCase
+	billUseCase   usecase.BillUseCase
+	ledgerUseCase usecase.LedgerUseCase
+}
+
+func NewAPIHandler(authUseCase usecase.AuthUseCase, billUseCase usecase.BillUseCase, ledgerUseCase usecase.LedgerUseCase) APIHandler {
+	return &apiHandler{
+		authUseCase:   authUseCase,
+		billUseCase:   billUseCase,
+		ledgerUseCase: ledgerUseCase,
+	}
+}
+
+func (a *apiHandler) Auth(ctx *gin.Context) {
+	// 令牌只从 Header 读取，避免出现在访问日志及浏览器历史中
+	token := strings.TrimSpace(strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer "))
+	operator, err := a.authUseCase.Authenticate(token)
+	if err == usecase.ErrAPIDisabled {
+		abortWithError(ctx, 403, err.Error())
+		return
+	}
+	if err != nil {
+		abortWithError(ctx, 401, err.Error())
+		return
+	}
+	ctx.Set(apiOperatorKey, operator)
+	ctx.Set(common.CurrentUserID, operator.UID)
+	ctx.Next()
+}
+
+func (a *apiHandler) GetMe(ctx *gin.Context) {
+	ctx.JSON(200, a.operator(ctx))
+}
+
+func (a *apiHandler) ListLedgers(ctx *gin.Context) {
+	ledgers := make([]*domain.Ledger, 0)
+	for _, it := range a.ledgerUseCase.List(a.operator(ctx).UID) {
+		ledgers = append(ledgers, it.Ledger)
+	}
+	ctx.JSON(200, gin.H{"items": ledgers})
+}
+
+func (a *apiHandler) ListBills(ctx *gin.Context) {
+	filter, err := parseBillFilter(ctx)
+	if err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	page, pageSize := parsePage(ctx)
+	ledger, ok := a.ledger(ctx)
+	if !ok {
+		return
+	}
+	bills, total, err := a.billUseCase.Page(ctx, ledger, filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("api list bills fail")
+		abortWithError(ctx, 500, err.Error())
+		return
+	}
+	ctx.JSON(200, BillPage{
+		Items:    bills,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+func (a *apiHandler) GetBill(ctx *gin.Context) {
+	ledger, ok := a.ledger(ctx)
+	if !ok {
+		return
+	}
+	bill, exists := a.billUseCase.Get(ledger.AppToken, ledger.TableToken, ctx.Param("id"))
+	if !exists {
+		abortWithError(ctx, 404, common.BillNotFound)
+		return
+	}
+	ctx.JSON(200, bill)
+}
+
+func (a *apiHandler) CreateBill(ctx *gin.Context) {
+	var req BillReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	if req.Amount == nil || req.Remark == nil {
+		abortWithError(ctx, 400, "remark and amount are required")
+		return
+	}
+	operator := a.operator(ctx)
+	bill := &domain.Bill{
+		AuthorID:   operator.UID,
+		AuthorName: operator.Name,
+	}
+	if err := req.apply(bill); err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	ledger, ok := a.writableLedger(ctx)
+	if !ok {
+		return
+	}
+	if req.Currency == nil {
+		bill.Currency = ledger.BillCurrency("")
+	}
+	if _, err := a.billUseCase.Convert(ctx, ledger, bill); err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	if err := a.billUseCase.Save(ledger.AppToken, ledger.TableToken, bill); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("api save bill fail")
+		abortWithError(ctx, 500, err.Error())
+		return
+	}
+	ctx.JSON(201, bill)
+}
+
+// UpdateBill 只更新请求中包含的字段，记账人保持不变，成员修改他人的账单时不影响成员统计及分摊结算
+func (a *apiHandler) UpdateBill(ctx *gin.Context) {
+	var req BillReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	ledger, ok := a.writableLedger(ctx)
+	if !ok {
+		return
+	}
+	bill, exists := a.billUseCase.Get(ledger.AppToken, ledger.TableToken, ctx.Param("id"))
+	if !exists {
+		abortWithError(ctx, 404, common.BillNotFound)
+		return
+	}
+	if err := req.apply(bill); err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	if _, err := a.billUseCase.Convert(ctx, ledger, bill); err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	if err := a.billUseCase.Update(ledger.AppToken, ledger.TableToken, bill); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("api update bill fail")
+		abortWithError(ctx, 500, err.Error())
+		return
+	}
+	ctx.JSON(200, bill)
+}
+
+func (a *apiHandler) DeleteBill(ctx *gin.Context) {
+	ledger, ok := a.writableLedger(ctx)
+	if !ok {
+		return
+	}
+	bill, exists := a.billUseCase.Get(ledger.AppToken, ledger.TableToken, ctx.Param("id"))
+	if !exists {
+		abortWithError(ctx, 404, common.BillNotFound)
+		return
+	}
+	if err := a.billUseCase.Delete(ledger.AppToken, ledger.TableToken, bill); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("api delete bill fail")
+		abortWithError(ctx, 500, err.Error())
+		return
+	}
+	ctx.Status(204)
+}
+
+// GetReport 收支汇总及分类明细，未指定时间范围时默认本月
+func (a *apiHandler) GetReport(ctx *gin.Context) {
+	filter, err := parseBillFilter(ctx)
+	if err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	ledger, ok := a.ledger(ctx)
+	if !ok {
+		return
+	}
+	summary := a.billUseCase.Query(ctx, ledger, filter)
+	report := Report{
+		StartDate:   summary.StartDate.Format(apiDateLayout),
+		EndDate:     summary.EndDate.Format(apiDateLayout),
+		Income:      summary.Income,
+		Pay:         summary.Pay,
+		Count:       summary.Count,
+		Unconverted: summary.Unconverted,
+		Categories:  make([]ReportCategory, 0, len(summary.Categories)),
+	}
+	for _, c := range summary.Categories {
+		report.Categories = append(report.Categories, ReportCategory{
+			Category: c.Category,
+			Expenses: string(c.Expenses),
+			Amount:   c.Amount,
+			Count:    c.Count,
+		})
+	}
+	ctx.JSON(200, report)
+}
+
+func (a *apiHandler) operator(ctx *gin.Context) *domain.User {
+	return ctx.MustGet(apiOperatorKey).(*domain.User)
+}
+
+// ledger 查询当前用户的账本，不存在时自动分配
+func (a *apiHandler) ledger(ctx *gin.Context) (*domain.Ledger, bool) {
+	operator := a.operator(ctx)
+	if ledger, exists := a.ledgerUseCase.Active(operator.UID); exists {
+		return ledger, true
+	}
+	ledger, err := a.ledgerUseCase.Allocated(*operator)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("api allocate ledger fail")
+		abortWithError(ctx, 500, err.Error())
+		return nil, false
+	}
+	return ledger, true
+}
+
+// writableLedger 查询当前用户可以记账的账本，只读成员返回 403
+func (a *apiHandler) writableLedger(ctx *gin.Context) (*domain.Ledger, bool) {
+	ledger, ok := a.ledger(ctx)
+	if !ok {
+		return nil, false
+	}
+	if member, exists := a.ledgerUseCase.Member(ledger, a.operator(ctx).UID); exists && !member.Writable() {
+		abortWithError(ctx, 403, "ledger is read only")
+		return nil, false
+	}
+	return ledger, true
+}
+
+func abortWithError(ctx *gin.Context, code int, msg string) {
+	ctx.AbortWithStatusJSON(code, gin.H{"error": msg})
+}
+
+// parseBillFilter 解析查询参数 start_date、end_date(yyyy-mm-dd)、expenses、category
+func parseBillFilter(ctx *gin.Context) (usecase.BillFilter, error) {
+	filter := usecase.BillFilter{
+		Category: strings.TrimSpace(ctx.Query("category")),
+	}
+	if v := ctx.Query("start_date"); v != "" {
+		start, err := time.ParseInLocation(apiDateLayout, v, time.Local)
+		if err != nil {
+			return filter, err
+		}
+		filter.StartDate = start
+	}
+	if v := ctx.Query("end_date"); v != "" {
+		end, err := time.ParseInLocation(apiDateLayout, v, time.Local)
+		if err != nil {
+			return filter, err
+		}
+		filter.EndDate = end
+	}
+	if v := ctx.Query("expenses"); v != "" {
+		expenses, ok := parseExpenses(v)
+		if !ok {
+			return filter, errInvalidExpenses
+		}
+		filter.Expenses = expenses
+	}
+	return filter, nil
+}
+
+func parsePage(ctx *gin.Context) (int, int) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+// parseExpenses 兼容中文及英文的收支类型
+func parseExpenses(v string) (common.Expenses, bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case string(common.Income), "income":
+		return common.Income, true
+	case string(common.Pay), "pay", "expense":
+		return common.Pay, true
+	}
+	return "", false
+}
+
+var (
+	errInvalidExpenses = fmt.Errorf("expenses must be 收入/income or 支出/pay")
+	errInvalidCurrency = fmt.Errorf("currency must be an ISO 4217 code such as USD")
+)
+
+// BillReq 新增或修改账单的请求，修改时为空的字段保持不变
+type BillReq struct {
+	Remark     *string  `json:"remark"`
+	Amount     *float64 `json:"amount"`
+	Expenses   *string  `json:"expenses"`
+	Categories []string `json:"categories"`
+	// Date 账单日期，格式为 yyyy-mm-dd，新增时默认为今天
+	Date *string `json:"date"`
+	// Currency 币种代码，如 USD，新增时默认为账本本位币
+	Currency *string `json:"currency"`
+}
+
+func (r BillReq) apply(bill *domain.Bill) error {
+	if r.Remark != nil {
+		bill.Remark = *r.Remark
+	}
+	if r.Amount != nil {
+		if len(bill.Splits) > 0 {
+			splits, err := domain.RescaleSplits(bill.Splits, *r.Amount)
+			if err != nil {
+				return err
+			}
+			bill.Splits = splits
+		}
+		bill.Amount = *r.Amount
+	}
+	if r.Expenses != nil {
+		expenses, ok := parseExpenses(*r.Expenses)
+		if !ok {
+			return errInvalidExpenses
+		}
+		bill.Expenses = string(expenses)
+	}
+	if r.Categories != nil {
+		bill.Categories = r.Categories
+	}
+	if r.Date != nil {
+		date, err := time.ParseInLocation(apiDateLayout, *r.Date, time.Local)
+		if err != nil {
+			return err
+		}
+		bill.Date = date.UnixNano() / 1e6
+	}
+	if r.Currency != nil {
+		bill.Currency = ""
+		if *r.Currency != "" {
+			currency, ok := domain.ParseCurrency(*r.Currency)
+			if !ok {
+				return errInvalidCurrency
+			}
+			bill.Currency = currency
+		}
+		// 人民币不记录币种
+		if bill.Currency == domain.DefaultCurrency {
+			bill.Currency = ""
+		}
+	}
+	return nil
+}
+
+type BillPage struct {
+	Items    []*domain.Bill `json:"items"`
+	Total    int            `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+type Report struct {
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date"`
+	Income    float64 `json:"income"`
+	Pay       float64 `json:"pay"`
+	Count     int     `json:"count"`
+	// Unconverted 缺少汇率、未计入统计的外币账单数
+	Unconverted int              `json:"unconverted"`
+	Categories  []ReportCategory `json:"categories"`
+}
+
+type ReportCategory struct {
+	Category string  `json:"category"`
+	Expenses string  `json:"expenses"`
+	Amount   float64 `json:"amount"`
+	Count    int     `json:"count"`
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"github.com/wangyuheng/richman/config"
 	"github.com/wangyuheng/richman/internal/usecase"
 )
@@ -10,6 +11,8 @@ import (
 type DevboxHandler interface {
 	GetUserByID(ctx *gin.Context)
 	PreparedLedger(ctx *gin.Context)
+	MockWechatToken(ctx *gin.Context)
+	MockWechatCustomSend(ctx *gin.Context)
 }
 
 type devboxHandler struct {
@@ -34,3 +37,21 @@ func (d *devboxHandler) GetUserByID(ctx *gin.Context) {
 func (d *devboxHandler) PreparedLedger(ctx *gin.Context) {
 	ctx.JSON(200, d.ledger.PreparedAllocated())
 }
+
+// MockWechatToken 模拟公众号 access_token 接口，配合 WECHAT_MOCK 及 WECHAT_API_URL 本地测试
+func (d *devboxHandler) MockWechatToken(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{
+		"access_token": fmt.Sprintf("mock-%s", ctx.Query("appid")),
+		"expires_in":   7200,
+	})
+}
+
+// MockWechatCustomSend 模拟公众号客服消息接口，只记录消息长度，不记录 access_token 及消息内容
+func (d *devboxHandler) MockWechatCustomSend(ctx *gin.Context) {
+	body, _ := ctx.GetRawData()
+	logrus.WithContext(ctx).Infof("mock wechat custom send. size:%d", len(body))
+	ctx.JSON(200, gin.H{
+		"errcode": 0,
+		"errmsg":  "ok",
+	})
+}
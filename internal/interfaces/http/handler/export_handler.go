@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"strings"
+)
+
+const defaultExportFormat = "xlsx"
+
+// ExportHandler 账单导出，Download 通过签名链接访问，Export 需要开放接口鉴权
+type ExportHandler interface {
+	Download(ctx *gin.Context)
+	Export(ctx *gin.Context)
+}
+
+type exportHandler struct {
+	exportUseCase usecase.ExportUseCase
+	ledgerUseCase usecase.LedgerUseCase
+}
+
+func NewExportHandler(exportUseCase usecase.ExportUseCase, ledgerUseCase usecase.LedgerUseCase) ExportHandler {
+	return &exportHandler{
+		exportUseCase: exportUseCase,
+		ledgerUseCase: ledgerUseCase,
+	}
+}
+
+func (e *exportHandler) Download(ctx *gin.Context) {
+	req, err := e.exportUseCase.ParseDownload(ctx.Request.URL.Query())
+	if err != nil {
+		abortWithError(ctx, 403, err.Error())
+		return
+	}
+	// 链接签发后用户可能已退出账本，下载时重新校验
+	ledger, exists := e.ledgerUseCase.Get(req.UID, req.LedgerID)
+	if !exists {
+		abortWithError(ctx, 403, "ledger not accessible")
+		return
+	}
+	e.write(ctx, ledger, req.Format, req.Filter)
+}
+
+// Export 查询参数同账单列表，另支持 format，默认 xlsx
+func (e *exportHandler) Export(ctx *gin.Context) {
+	filter, err := parseBillFilter(ctx)
+	if err != nil {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	operator := ctx.MustGet(apiOperatorKey).(*domain.User)
+	ledger, exists := e.ledgerUseCase.Active(operator.UID)
+	if !exists {
+		abortWithError(ctx, 404, "ledger not found")
+		return
+	}
+	e.write(ctx, ledger, strings.ToLower(ctx.DefaultQuery("format", defaultExportFormat)), filter)
+}
+
+func (e *exportHandler) write(ctx *gin.Context, ledger *domain.Ledger, format string, filter usecase.BillFilter) {
+	contentType, ok := e.exportUseCase.ContentType(format)
+	if !ok {
+		abortWithError(ctx, 400, fmt.Sprintf("format must be one of %s", strings.Join(e.exportUseCase.Formats(), ", ")))
+		return
+	}
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(format, filter)))
+	if err := e.exportUseCase.Export(ctx, ctx.Writer, ledger, filter, format); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("export bill fail")
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			abortWithError(ctx, 500, err.Error())
+		}
+	}
+}
+
+// exportFilename 如: richman-bills-2026-10-01-2026-10-18.xlsx
+func exportFilename(format string, filter usecase.BillFilter) string {
+	name := "richman-bills"
+	if !filter.StartDate.IsZero() {
+		name += "-" + filter.StartDate.Format(apiDateLayout)
+	}
+	if !filter.EndDate.IsZero() {
+		name += "-" + filter.EndDate.Format(apiDateLayout)
+	}
+	return name + "." + format
+}
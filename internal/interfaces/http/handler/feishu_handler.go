@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	lru "github.com/hashicorp/golang-lru"
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/infrastructure/feishu"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"runtime/debug"
+	"strings"
+)
+
+const (
+	feishuURLVerification = "url_verification"
+	feishuMessageReceive  = "im.message.receive_v1"
+)
+
+// errFeishuUnverifiable 未配置 Verification Token 及 Encrypt Key，无法校验事件来源
+var errFeishuUnverifiable = errors.New("LARK_VERIFICATION_TOKEN or LARK_ENCRYPT_KEY is required")
+
+type FeishuHandler interface {
+	Webhook(ctx *gin.Context)
+}
+
+type feishuHandler struct {
+	assistant         usecase.Assistant
+	verificationToken string
+	encryptKey        string
+	// events 飞书推送失败会重试，按 event_id 排重
+	events    *lru.Cache
+	messenger feishu.Messenger
+	jobs      chan FeishuMessageEvent
+}
+
+func NewFeishuHandler(cfg *config.Config, assistant usecase.Assistant, messenger feishu.Messenger) FeishuHandler {
+	events, _ := lru.New(1024)
+	f := &feishuHandler{
+		assistant:         assistant,
+		verificationToken: cfg.LarkVerificationToken,
+		encryptKey:        cfg.LarkEncryptKey,
+		events:            events,
+		messenger:         messenger,
+	}
+	f.startWorkers(cfg.LarkWorkers)
+	return f
+}
+
+// Webhook 接收飞书事件回调，需在 3 秒内应答，消息处理完成后通过消息接口回复
+func (f *feishuHandler) Webhook(ctx *gin.Context) {
+	evt, err := f.decodeEvent(ctx)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("decode feishu event fail!")
+		if errors.Is(err, errFeishuUnverifiable) {
+			_ = ctx.AbortWithError(403, err)
+			return
+		}
+		_ = ctx.AbortWithError(400, err)
+		return
+	}
+	logger := logrus.WithContext(ctx).WithField("eventID", evt.Header.EventID).WithField("eventType", evt.Header.EventType)
+
+	if evt.Type == feishuURLVerification {
+		logger.Info("feishu url verification")
+		ctx.JSON(200, gin.H{"challenge": evt.Challenge})
+		return
+	}
+	if evt.Header.EventType != feishuMessageReceive {
+		logger.Info("ignore event")
+		ctx.JSON(200, gin.H{})
+		return
+	}
+	if evt.Event.Sender.SenderType != "user" {
+		logger.Info("ignore message not sent by user")
+		ctx.JSON(200, gin.H{})
+		return
+	}
+	if ok, _ := f.events.ContainsOrAdd(evt.Header.EventID, true); ok {
+		logger.Info("event already accepted")
+		ctx.JSON(200, gin.H{})
+		return
+	}
+	select {
+	case f.jobs <- evt.Event:
+	default:
+		logger.Warn("feishu job queue is full")
+		f.reply(ctx, evt.Event.Message.MessageID, common.SystemBusy)
+	}
+	ctx.JSON(200, gin.H{})
+}
+
+func (f *feishuHandler) startWorkers(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	f.jobs = make(chan FeishuMessageEvent, n*16)
+	for i := 0; i < n; i++ {
+		go func() {
+			for evt := range f.jobs {
+				f.process(evt)
+			}
+		}()
+	}
+}
+
+// decodeEvent 校验签名及 Verification Token，配置 Encrypt Key 时解密事件体
+func (f *feishuHandler) decodeEvent(ctx *gin.Context) (FeishuEvent, error) {
+	var evt FeishuEvent
+	body, err := ctx.GetRawData()
+	if err != nil {
+		return evt, err
+	}
+	if f.encryptKey == "" && f.verificationToken == "" {
+		return evt, errFeishuUnverifiable
+	}
+	if f.encryptKey != "" {
+		signature := ctx.GetHeader("X-Lark-Signature")
+		expected := larkevent.Signature(ctx.GetHeader("X-Lark-Request-Timestamp"), ctx.GetHeader("X-Lark-Request-Nonce"), f.encryptKey, string(body))
+		if signature == "" || subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+			return evt, fmt.Errorf("check sign fail")
+		}
+	}
+	if err = json.Unmarshal(body, &evt); err != nil {
+		return evt, fmt.Errorf("unmarshal event json fail")
+	}
+	if evt.Encrypt != "" {
+		if f.encryptKey == "" {
+			return evt, fmt.Errorf("encrypt key is required for encrypted event")
+		}
+		plaintext, err := larkevent.EventDecrypt(evt.Encrypt, f.encryptKey)
+		if err != nil {
+			return evt, err
+		}
+		evt = FeishuEvent{}
+		if err = json.Unmarshal(plaintext, &evt); err != nil {
+			return evt, fmt.Errorf("unmarshal decrypted event json fail")
+		}
+	}
+	// url_verification 及 1.0 版本事件 token 在外层，2.0 版本在 header 中
+	token := evt.Token
+	if evt.Header.Token != "" {
+		token = evt.Header.Token
+	}
+	if f.verificationToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(f.verificationToken)) != 1 {
+		return evt, fmt.Errorf("verification token mismatch")
+	}
+	return evt, nil
+}
+
+func (f *feishuHandler) process(evt FeishuMessageEvent) {
+	UID := evt.Sender.SenderID.OpenID
+	ctx := context.WithValue(context.Background(), common.CurrentUserID, UID)
+	logger := logrus.WithContext(ctx).WithField("messageID", evt.Message.MessageID)
+	logger.Infof("receive feishu message %+v", evt.Message)
+
+	var res string
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Errorf("handle feishu message panic! err:%+v, stack:%s", p, debug.Stack())
+			res = fmt.Sprintf("something is wrong with %s", p)
+		}
+		f.reply(ctx, evt.Message.MessageID, res)
+	}()
+
+	if evt.Message.MessageType != "text" {
+		logger.Infof("unsupported msg type %s", evt.Message.MessageType)
+		res = common.UnsupportedMsgType
+		return
+	}
+	text := evt.Message.Text()
+	if text == "" {
+		return
+	}
+	reply, err := f.assistant.Handle(ctx, usecase.InboundMessage{
+		Channel:   usecase.ChannelFeishu,
+		UserID:    UID,
+		MessageID: evt.Message.MessageID,
+		Text:      text,
+	})
+	if err != nil {
+		res = common.Err(err)
+		return
+	}
+	res = reply.Text
+}
+
+func (f *feishuHandler) reply(ctx context.Context, messageID, res string) {
+	if res == "" {
+		return
+	}
+	if err := f.messenger.Reply(ctx, messageID, res); err != nil {
+		logrus.WithContext(ctx).WithField("messageID", messageID).WithError(err).Error("reply feishu message fail")
+	}
+}
+
+type FeishuEvent struct {
+	// Schema 2.0 版本事件为 2.0
+	Schema string             `json:"schema"`
+	Header FeishuEventHeader  `json:"header"`
+	Event  FeishuMessageEvent `json:"event"`
+	// Encrypt 配置 Encrypt Key 后的加密事件体
+	Encrypt string `json:"encrypt"`
+	// Type url_verification 为请求地址校验
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+}
+
+type FeishuEventHeader struct {
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	CreateTime string `json:"create_time"`
+	Token      string `json:"token"`
+	AppID      string `json:"app_id"`
+	TenantKey  string `json:"tenant_key"`
+}
+
+type FeishuMessageEvent struct {
+	Sender  FeishuSender  `json:"sender"`
+	Message FeishuMessage `json:"message"`
+}
+
+type FeishuSender struct {
+	SenderID   FeishuUserID `json:"sender_id"`
+	SenderType string       `json:"sender_type"`
+	TenantKey  string       `json:"tenant_key"`
+}
+
+type FeishuUserID struct {
+	UnionID string `json:"union_id"`
+	UserID  string `json:"user_id"`
+	OpenID  string `json:"open_id"`
+}
+
+type FeishuMessage struct {
+	MessageID string `json:"message_id"`
+	RootID    string `json:"root_id"`
+	ParentID  string `json:"parent_id"`
+	ChatID    string `json:"chat_id"`
+	// ChatType p2p 单聊，group 群聊
+	ChatType    string `json:"chat_type"`
+	MessageType string `json:"message_type"`
+	// Content 消息内容 JSON，文本消息为 {"text":"..."}
+	Content  string          `json:"content"`
+	Mentions []FeishuMention `json:"mentions"`
+}
+
+type FeishuMention struct {
+	// Key 文本中的占位符，如 @_user_1
+	Key  string       `json:"key"`
+	ID   FeishuUserID `json:"id"`
+	Name string       `json:"name"`
+}
+
+// Text 解析文本消息内容，并去掉 @ 机器人等占位符
+func (m FeishuMessage) Text() string {
+	var content struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(m.Content), &content); err != nil {
+		return ""
+	}
+	text := content.Text
+	for _, mention := range m.Mentions {
+		text = strings.ReplaceAll(text, mention.Key, "")
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize 账单流水文件大小上限
+const maxImportSize = 20 << 20
+
+// ImportHandler 导入支付宝、微信支付账单流水，需要开放接口鉴权
+type ImportHandler interface {
+	Import(ctx *gin.Context)
+}
+
+type importHandler struct {
+	importUseCase usecase.ImportUseCase
+	ledgerUseCase usecase.LedgerUseCase
+}
+
+func NewImportHandler(importUseCase usecase.ImportUseCase, ledgerUseCase usecase.LedgerUseCase) ImportHandler {
+	return &importHandler{
+		importUseCase: importUseCase,
+		ledgerUseCase: ledgerUseCase,
+	}
+}
+
+// Import 文件通过 multipart 的 file 字段或直接作为请求体上传，dry_run=true 时只预览不保存
+func (i *importHandler) Import(ctx *gin.Context) {
+	dryRun, _ := strconv.ParseBool(ctx.Query("dry_run"))
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize)
+
+	var r io.Reader = ctx.Request.Body
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		fh, err := ctx.FormFile("file")
+		if err != nil {
+			abortWithError(ctx, 400, err.Error())
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			abortWithError(ctx, 400, err.Error())
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	operator := ctx.MustGet(apiOperatorKey).(*domain.User)
+	ledger, exists := i.ledgerUseCase.Active(operator.UID)
+	if !exists {
+		var err error
+		if ledger, err = i.ledgerUseCase.Allocated(*operator); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("api allocate ledger fail")
+			abortWithError(ctx, 500, err.Error())
+			return
+		}
+	}
+	if member, exists := i.ledgerUseCase.Member(ledger, operator.UID); exists && !member.Writable() {
+		abortWithError(ctx, 403, "ledger is read only")
+		return
+	}
+	res, err := i.importUseCase.Import(ctx, ledger, *operator, r, dryRun)
+	if err == domain.ErrUnknownStatement {
+		abortWithError(ctx, 400, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("import bills fail")
+		abortWithError(ctx, 500, err.Error())
+		return
+	}
+	ctx.JSON(200, ImportResp{
+		Source:     res.Source,
+		DryRun:     res.DryRun,
+		Imported:   len(res.Bills),
+		Duplicated: res.Duplicated,
+		Skipped:    res.Skipped,
+		Items:      res.Bills,
+	})
+}
+
+type ImportResp struct {
+	Source string `json:"source"`
+	DryRun bool   `json:"dry_run"`
+	// Imported 导入的账单数，DryRun 时为待导入的账单数
+	Imported   int            `json:"imported"`
+	Duplicated int            `json:"duplicated"`
+	Skipped    int            `json:"skipped"`
+	Items      []*domain.Bill `json:"items"`
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// wechatCrypto 公众号安全模式消息加解密
+// https://developers.weixin.qq.com/doc/offiaccount/Message_Management/Message_encryption_and_decryption_instructions.html
+type wechatCrypto struct {
+	token string
+	appID string
+	key   []byte
+}
+
+func newWechatCrypto(token, encodingAESKey, appID string) (*wechatCrypto, error) {
+	if len(encodingAESKey) != 43 {
+		return nil, fmt.Errorf("illegal EncodingAESKey length %d", len(encodingAESKey))
+	}
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("illegal EncodingAESKey: %w", err)
+	}
+	return &wechatCrypto{
+		token: token,
+		appID: appID,
+		key:   key,
+	}, nil
+}
+
+// Decrypt 校验 msg_signature 后解密，返回明文 xml
+func (c *wechatCrypto) Decrypt(msgSignature, timestamp, nonce, encrypt string) ([]byte, error) {
+	if !equalSignature(msgSignature, signature(c.token, timestamp, nonce, encrypt)) {
+		return nil, fmt.Errorf("check msg_signature fail")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("illegal ciphertext length %d", len(ciphertext))
+	}
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, c.key[:aes.BlockSize]).CryptBlocks(plaintext, ciphertext)
+	plaintext, err = pkcs7Unpad(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	// random(16B) + msg_len(4B) + msg + appid
+	if len(plaintext) < 20 {
+		return nil, fmt.Errorf("illegal plaintext length %d", len(plaintext))
+	}
+	msgLen := int(binary.BigEndian.Uint32(plaintext[16:20]))
+	if msgLen < 0 || 20+msgLen > len(plaintext) {
+		return nil, fmt.Errorf("illegal msg length %d", msgLen)
+	}
+	msg := plaintext[20 : 20+msgLen]
+	if appID := string(plaintext[20+msgLen:]); c.appID != "" && appID != c.appID {
+		return nil, fmt.Errorf("appid mismatch %s", appID)
+	}
+	return msg, nil
+}
+
+// Encrypt 加密回复消息，并生成安全模式的响应报文
+func (c *wechatCrypto) Encrypt(msg []byte, timestamp, nonce string) ([]byte, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	msgLen := make([]byte, 4)
+	binary.BigEndian.PutUint32(msgLen, uint32(len(msg)))
+
+	plaintext := bytes.Join([][]byte{random, msgLen, msg, []byte(c.appID)}, nil)
+	plaintext = pkcs7Pad(plaintext)
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, c.key[:aes.BlockSize]).CryptBlocks(ciphertext, plaintext)
+
+	encrypt := base64.StdEncoding.EncodeToString(ciphertext)
+	return xml.Marshal(WxEncryptResp{
+		Encrypt:      cdata{encrypt},
+		MsgSignature: cdata{signature(c.token, timestamp, nonce, encrypt)},
+		TimeStamp:    timestamp,
+		Nonce:        cdata{nonce},
+	})
+}
+
+// pkcs7BlockSize 微信使用 32 字节补位
+const pkcs7BlockSize = 32
+
+func pkcs7Pad(b []byte) []byte {
+	n := pkcs7BlockSize - len(b)%pkcs7BlockSize
+	return append(b, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func pkcs7Unpad(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty plaintext")
+	}
+	n := int(b[len(b)-1])
+	if n < 1 || n > pkcs7BlockSize || n > len(b) {
+		return nil, fmt.Errorf("illegal padding %d", n)
+	}
+	return b[:len(b)-n], nil
+}
+
+func signature(parts ...string) string {
+	l := sort.StringSlice(parts)
+	sort.Strings(l)
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(l, ""))))
+}
+
+// equalSignature 常量时间比较签名，避免通过响应时间推测签名
+func equalSignature(got, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(expected)) == 1
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type WxEncryptResp struct {
+	XMLName      xml.Name `xml:"xml"`
+	Encrypt      cdata    `xml:"Encrypt"`
+	MsgSignature cdata    `xml:"MsgSignature"`
+	TimeStamp    string   `xml:"TimeStamp"`
+	Nonce        cdata    `xml:"Nonce"`
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEncodingAESKey 微信文档中的示例 EncodingAESKey
+const testEncodingAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+func TestWechatCryptoRoundTrip(t *testing.T) {
+	c, err := newWechatCrypto("tk", testEncodingAESKey, "wx123")
+	if err != nil {
+		t.Fatalf("newWechatCrypto() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{name: "文本消息", msg: "<xml><Content><![CDATA[午饭 25]]></Content></xml>"},
+		{name: "空消息", msg: ""},
+		{name: "长度恰好为补位整数倍", msg: strings.Repeat("a", pkcs7BlockSize*2-20-len("wx123"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := c.Encrypt([]byte(tt.msg), "1700000000", "nonce")
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+			var resp WxEncryptResp
+			if err := xml.Unmarshal(data, &resp); err != nil {
+				t.Fatalf("unmarshal encrypt resp error = %v", err)
+			}
+			got, err := c.Decrypt(resp.MsgSignature.Value, resp.TimeStamp, resp.Nonce.Value, resp.Encrypt.Value)
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if string(got) != tt.msg {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.msg)
+			}
+		})
+	}
+}
+
+func TestWechatCryptoDecryptIllegal(t *testing.T) {
+	c, _ := newWechatCrypto("tk", testEncodingAESKey, "wx123")
+	data, _ := c.Encrypt([]byte("<xml></xml>"), "1700000000", "nonce")
+	var resp WxEncryptResp
+	_ = xml.Unmarshal(data, &resp)
+
+	other, _ := newWechatCrypto("tk", testEncodingAESKey, "wx456")
+	tests := []struct {
+		name      string
+		crypto    *wechatCrypto
+		signature string
+		encrypt   string
+	}{
+		{name: "签名错误", crypto: c, signature: "bad", encrypt: resp.Encrypt.Value},
+		{name: "appid 不一致", crypto: other, signature: resp.MsgSignature.Value, encrypt: resp.Encrypt.Value},
+		{name: "密文长度错误", crypto: c, signature: signature("tk", "1700000000", "nonce", "YWJj"), encrypt: "YWJj"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.crypto.Decrypt(tt.signature, resp.TimeStamp, resp.Nonce.Value, tt.encrypt); err == nil {
+				t.Error("Decrypt() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestNewWechatCryptoIllegalKey(t *testing.T) {
+	for _, key := range []string{"", "short", testEncodingAESKey + "H", strings.Repeat("!", 43)} {
+		if _, err := newWechatCrypto("tk", key, ""); err == nil {
+			t.Errorf("newWechatCrypto(%q) error = nil, want error", key)
+		}
+	}
+}
+
+func TestPkcs7(t *testing.T) {
+	for n := 0; n <= pkcs7BlockSize*2; n++ {
+		b := bytes.Repeat([]byte{'x'}, n)
+		padded := pkcs7Pad(append([]byte{}, b...))
+		if len(padded)%pkcs7BlockSize != 0 || len(padded) <= n {
+			t.Fatalf("pkcs7Pad(%d) length = %d", n, len(padded))
+		}
+		got, err := pkcs7Unpad(padded)
+		if err != nil || !bytes.Equal(got, b) {
+			t.Fatalf("pkcs7Unpad(pkcs7Pad(%d)) = %v, %v", n, got, err)
+		}
+	}
+	if _, err := pkcs7Unpad([]byte{0}); err == nil {
+		t.Error("pkcs7Unpad() with zero padding error = nil, want error")
+	}
+}
+
+func TestWechatCheck(t *testing.T) {
+	w := &wechatHandler{}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-wechatTimestampTolerance-time.Minute).Unix(), 10)
+	tests := []struct {
+		name      string
+		token     string
+		sign      string
+		timestamp string
+		want      bool
+	}{
+		{name: "签名正确", token: "tk", sign: signature("tk", now, "nonce"), timestamp: now, want: true},
+		{name: "签名错误", token: "tk", sign: signature("other", now, "nonce"), timestamp: now},
+		{name: "未配置令牌", token: "", sign: signature("", now, "nonce"), timestamp: now},
+		{name: "没有签名", token: "tk", sign: "", timestamp: now},
+		{name: "时间戳过期", token: "tk", sign: signature("tk", stale, "nonce"), timestamp: stale},
+		{name: "时间戳格式错误", token: "tk", sign: signature("tk", "abc", "nonce"), timestamp: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.check(tt.token, tt.sign, tt.timestamp, "nonce"); got != tt.want {
+				t.Errorf("check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,8 +2,6 @@ package handler
 
 import (
 	"context"
-	"crypto/sha1"
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -14,7 +12,6 @@ import (
 	"github.com/wangyuheng/richman/internal/domain"
 	"github.com/wangyuheng/richman/internal/usecase"
 	"runtime/debug"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,15 +23,22 @@ type WechatHandler interface {
 }
 
 type wechatHandler struct {
-	token         string
-	resCache      *lru.Cache
-	aiService     domain.AIService
-	billUseCase   usecase.BillUseCase
-	userUseCase   usecase.UserUseCase
-	ledgerUseCase usecase.LedgerUseCase
-	running       *running
+	assistant usecase.Assistant
+	token     string
+	resCache  *lru.Cache
+	running   *running
+	messenger domain.Messenger
+	async     bool
+	jobs      chan WxReq
+	crypto    *wechatCrypto
 }
 
+// wechatEncryptKey 标记本次请求为安全模式，回复需要加密
+const wechatEncryptKey = "WECHAT_ENCRYPT"
+
+// wechatTimestampTolerance 回调请求 timestamp 与服务器时间的最大偏差，超出视为重放
+const wechatTimestampTolerance = 5 * time.Minute
+
 type running struct {
 	toggle *lru.Cache
 }
@@ -52,20 +56,30 @@ func (r *running) IsRunning(msgID string) bool {
 }
 
 // func NewWechatHandler(cfg *config.Config, user biz.User, aiService client.OpenaiService) WechatHandler {
-func NewWechatHandler(cfg *config.Config, billUseCase usecase.BillUseCase, aiService domain.AIService, ledgerUseCase usecase.LedgerUseCase, userUseCase usecase.UserUseCase) WechatHandler {
+func NewWechatHandler(cfg *config.Config, assistant usecase.Assistant, messenger domain.Messenger) (WechatHandler, error) {
 	resCache, _ := lru.New(256)
 	runningCache, _ := lru.New(256)
-	return &wechatHandler{
-		token:         cfg.WechatToken,
-		resCache:      resCache,
-		aiService:     aiService,
-		billUseCase:   billUseCase,
-		ledgerUseCase: ledgerUseCase,
-		userUseCase:   userUseCase,
+	w := &wechatHandler{
+		assistant: assistant,
+		token:     cfg.WechatToken,
+		resCache:  resCache,
 		running: &running{
 			toggle: runningCache,
 		},
+		messenger: messenger,
+		async:     cfg.WechatAsync,
+	}
+	if cfg.WechatEncodingAESKey != "" {
+		crypto, err := newWechatCrypto(cfg.WechatToken, cfg.WechatEncodingAESKey, cfg.WechatAppID)
+		if err != nil {
+			return nil, err
+		}
+		w.crypto = crypto
+	}
+	if w.async {
+		w.startWorkers(cfg.WechatWorkers)
 	}
+	return w, nil
 }
 
 func (w *wechatHandler) CheckSignature(ctx *gin.Context) {
@@ -90,14 +104,17 @@ func (w *wechatHandler) CheckSignature(ctx *gin.Context) {
 }
 
 func (w *wechatHandler) Dispatch(ctx *gin.Context) {
-	var req WxReq
-	var err error
-	if err = ctx.BindXML(&req); err != nil {
-		logrus.Error("unmarshal req xml fail!", err)
-		_ = ctx.AbortWithError(400, fmt.Errorf("unmarshal req xml fail"))
+	req, err := w.decodeReq(ctx)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("decode req fail!")
+		_ = ctx.AbortWithError(400, err)
 		return
 	}
 	ctx.Set(common.CurrentUserID, req.FromUserName)
+	// 事件推送没有 MsgId，使用 FromUserName + CreateTime 排重
+	if req.MsgID == "" {
+		req.MsgID = fmt.Sprintf("%s:%d", req.FromUserName, req.CreateTime)
+	}
 
 	logger := logrus.WithContext(ctx).WithField("req", fmt.Sprintf("%+v", req))
 	logger.Info("receive req xml")
@@ -109,6 +126,10 @@ func (w *wechatHandler) Dispatch(ctx *gin.Context) {
 			return
 		}
 	}()
+	if w.async {
+		w.dispatchAsync(ctx, req)
+		return
+	}
 	if w.running.IsRunning(req.MsgID) {
 		for i := 0; i < 5; i++ {
 			logger.Info("wait for running finish")
@@ -127,7 +148,7 @@ func (w *wechatHandler) Dispatch(ctx *gin.Context) {
 	defer func() {
 		w.running.End(req.MsgID)
 	}()
-	res, err := w.handleWechatTextMessage(ctx, req.Content, req.FromUserName)
+	res, err := w.handleWechatMessage(ctx, req)
 	if err != nil {
 		w.resCache.Add(req.MsgID, common.Err(err))
 		w.returnTextMsg(ctx, req.ToUserName, req.FromUserName, common.Err(err))
@@ -138,273 +159,174 @@ func (w *wechatHandler) Dispatch(ctx *gin.Context) {
 	return
 }
 
-func (w *wechatHandler) handleWechatTextMessage(ctx context.Context, content, UID string) (string, error) {
-	cmd := common.Trim(content)
-
-	var js map[string]string
-	if json.Unmarshal([]byte(cmd), &js) == nil {
-		return common.NotSupport, nil
+// decodeReq 校验签名并解析请求，安全模式下解密消息体
+func (w *wechatHandler) decodeReq(ctx *gin.Context) (WxReq, error) {
+	var req WxReq
+	timestamp := ctx.Query("timestamp")
+	nonce := ctx.Query("nonce")
+	if !w.check(w.token, ctx.Query("signature"), timestamp, nonce) {
+		return req, fmt.Errorf("check sign fail")
 	}
-	if cmd == "搞一个" {
-		return common.NotSupport, nil
+	body, err := ctx.GetRawData()
+	if err != nil {
+		return req, err
 	}
-
-	operator := &domain.User{
-		UID: UID,
+	if err = xml.Unmarshal(body, &req); err != nil {
+		return req, fmt.Errorf("unmarshal req xml fail")
 	}
-
-	resp, err := w.aiService.CallFunctions(ctx, cmd, buildAIFunctions())
-	if err != nil {
-		return "", err
+	if ctx.Query("encrypt_type") != "aes" {
+		return req, nil
 	}
-	h := w.buildHandler(resp.FunctionCall, resp.Content)
-	if h.NeedAuth {
-		logrus.WithContext(ctx).Infof("exec handler %s", h.Name)
-		userExist := false
-		operator, userExist = w.userUseCase.GetByID(UID)
-		if !userExist || operator.Name == "" {
-			logrus.WithContext(ctx).Info("user not found, input required.")
-			return common.NotFoundUserName, nil
+	if w.crypto == nil {
+		// 兼容模式同时包含明文，未配置 EncodingAESKey 时按明文处理
+		if req.MsgType != "" {
+			return req, nil
 		}
+		return req, fmt.Errorf("EncodingAESKey is required in safe mode")
+	}
+	plaintext, err := w.crypto.Decrypt(ctx.Query("msg_signature"), timestamp, nonce, req.Encrypt)
+	if err != nil {
+		return req, err
+	}
+	var decrypted WxReq
+	if err = xml.Unmarshal(plaintext, &decrypted); err != nil {
+		return req, fmt.Errorf("unmarshal decrypted xml fail")
 	}
-	return h.Handle(operator)
+	ctx.Set(wechatEncryptKey, true)
+	return decrypted, nil
 }
 
-func buildAIFunctions() domain.AI {
-	currentTime := time.Now().Format("2006-01-02 15:04:05")
-	currentDate := time.Now().Format("2006/01/02")
-	expenses := []string{"收入", "支出"}
-	bookkeepingRequired := []string{"remark", "amount", "expenses", "category"}
-	return domain.AI{
-		Introduction: fmt.Sprintf("你叫Richman 是一个基于飞书表格的记账软件。当前时间是 %s 如果不明确用户的意图，可以指导用户使用这个如见。比如：可以通过输入包子花了15或者工资收入100 用来记账", currentTime),
-		Functions: []domain.AIFunction{
-			{
-				Name:        "bookkeeping",
-				Description: "记账工具，支持记录收入支出",
-				Parameters: domain.AIParameter{
-					Type: "object",
-					Properties: map[string]domain.AIProperty{
-						"remark": {
-							Type:        "string",
-							Description: "名称或描述",
-						},
-						"amount": {
-							Type:        "string",
-							Description: "账单金额 format by float64",
-						},
-						"expenses": {
-							Type:        "string",
-							Description: "收入还是支出",
-							Enum:        &expenses,
-						},
-						"category": {
-							Type:        "string",
-							Description: "账单分类",
-						},
-					},
-					Required: &bookkeepingRequired,
-				},
-			},
-			{
-				Name:        "query_bill",
-				Description: "查询账单信息",
-				Parameters: domain.AIParameter{
-					Type: "object",
-					Properties: map[string]domain.AIProperty{
-						"start_date": {
-							Type:        "string",
-							Description: fmt.Sprintf("查账开始时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
-						},
-						"end_date": {
-							Type:        "string",
-							Description: fmt.Sprintf("查账结束时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
-						},
-						"expenses": {
-							Type:        "string",
-							Description: "收入还是支出",
-							Enum:        &expenses,
-						},
-						"category": {
-							Type:        "string",
-							Description: "要查询的账单分类",
-						},
-					},
-				},
-			},
-			{
-				Name:        "get_ledger",
-				Description: "获取账本信息，如: URL",
-				Parameters: domain.AIParameter{
-					Type:       "object",
-					Properties: map[string]domain.AIProperty{},
-				},
-			},
-			{
-				Name:        "get_category",
-				Description: "获取分类",
-				Parameters: domain.AIParameter{
-					Type:       "object",
-					Properties: map[string]domain.AIProperty{},
-				},
-			},
-			{
-				Name:        "get_user_identity",
-				Description: "获取用户的称呼",
-				Parameters: domain.AIParameter{
-					Type: "object",
-					Properties: map[string]domain.AIProperty{
-						"name": {
-							Type:        "string",
-							Description: "用户希望被称呼的名字",
-						},
-					},
-				},
-			},
-			{
-				Name:        "get_source_code",
-				Description: "获取源代码",
-				Parameters: domain.AIParameter{
-					Type:       "object",
-					Properties: map[string]domain.AIProperty{},
-				},
-			},
-		},
+// dispatchAsync 立即应答微信，避免 5 秒超时，处理结果通过客服消息推送
+func (w *wechatHandler) dispatchAsync(ctx *gin.Context, req WxReq) {
+	logger := logrus.WithContext(ctx).WithField("msgID", req.MsgID)
+	// 微信重试时 MsgID 不变，已受理的消息直接应答
+	if ok, _ := w.resCache.ContainsOrAdd(req.MsgID, ""); ok {
+		logger.Info("msg already accepted")
+		_, _ = ctx.Writer.WriteString("success")
+		return
+	}
+	select {
+	case w.jobs <- req:
+		_, _ = ctx.Writer.WriteString("success")
+	default:
+		// 队列已满时不再同步处理，移除排重记录以便用户重新发送
+		logger.Warn("wechat job queue is full")
+		w.resCache.Remove(req.MsgID)
+		w.returnTextMsg(ctx, req.ToUserName, req.FromUserName, common.SystemBusy)
 	}
-
 }
-func (w *wechatHandler) buildHandler(call *domain.AIFunctionCall, content string) Handler {
-	if call != nil {
-		switch call.Name {
-		case "get_source_code":
-			return Handler{
-				Name:     call.Name,
-				NeedAuth: false,
-				Handle: func(operator *domain.User) (string, error) {
-					return "https://github.com/wangyuheng/richman", nil
-				},
-			}
-		case "get_ledger":
-			return Handler{
-				Name:     call.Name,
-				NeedAuth: true,
-				Handle: func(operator *domain.User) (string, error) {
-					var ledger *domain.Ledger
-					var err error
-					ledger, exists := w.ledgerUseCase.QueryByUID(operator.UID)
-					if !exists {
-						ledger, err = w.ledgerUseCase.Allocated(*operator)
-						if err != nil {
-							return "", err
-						}
-					}
-					return ledger.URL, nil
-				},
-			}
-		case "get_category":
-			return Handler{
-				Name:     call.Name,
-				NeedAuth: true,
-				Handle: func(operator *domain.User) (string, error) {
-					ledger, exists := w.ledgerUseCase.QueryByUID(operator.UID)
-					if !exists {
-						ledger, _ = w.ledgerUseCase.Allocated(*operator)
-					}
-					return strings.Join(w.billUseCase.ListCategory(ledger.AppToken, ledger.TableToken), "\r\n"), nil
-				},
-			}
-		case "query_bill":
-			return Handler{
-				Name:     call.Name,
-				NeedAuth: true,
-				Handle: func(operator *domain.User) (string, error) {
-					ledger, exists := w.ledgerUseCase.QueryByUID(operator.UID)
-					if !exists {
-						ledger, _ = w.ledgerUseCase.Allocated(*operator)
-					}
-					in := w.billUseCase.CurMonthTotal(ledger.AppToken, ledger.TableToken, common.Income, 0)
-					out := w.billUseCase.CurMonthTotal(ledger.AppToken, ledger.TableToken, common.Pay, 0)
 
-					return common.Analysis(in, out), nil
-				},
-			}
-		case "bookkeeping":
-			return Handler{
-				Name:     call.Name,
-				NeedAuth: true,
-				Handle: func(operator *domain.User) (string, error) {
-					var args BookkeepingArgs
-					_ = json.Unmarshal([]byte(call.Arguments), &args)
-					amount, err := strconv.ParseFloat(args.Amount, 64)
-					if err != nil {
-						return common.AmountIllegal, nil
-					}
-					ledger, exists := w.ledgerUseCase.QueryByUID(operator.UID)
-					if !exists {
-						ledger, _ = w.ledgerUseCase.Allocated(*operator)
-					}
-					total := w.billUseCase.CurMonthTotal(ledger.AppToken, ledger.TableToken, common.Expenses(args.Expenses), amount)
-					if err := w.billUseCase.Save(ledger.AppToken, ledger.TableToken, &domain.Bill{
-						Remark:     args.Remark,
-						Categories: []string{args.Category},
-						Amount:     amount,
-						Expenses:   args.Expenses,
-						AuthorID:   operator.UID,
-						AuthorName: operator.Name,
-					}); err != nil {
-						return "", err
-					}
-					return common.RecordSuccess(total, common.Expenses(args.Expenses)), nil
-				},
+func (w *wechatHandler) startWorkers(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	w.jobs = make(chan WxReq, n*16)
+	for i := 0; i < n; i++ {
+		go func() {
+			for req := range w.jobs {
+				w.process(req)
 			}
-		case "get_user_identity":
-			return Handler{
-				Name:     call.Name,
-				NeedAuth: false,
-				Handle: func(operator *domain.User) (string, error) {
-					var args GetUserIdentityArgs
-					_ = json.Unmarshal([]byte(call.Arguments), &args)
-					operator.Name = args.Name
+		}()
+	}
+}
 
-					if err := w.userUseCase.Save(*operator); err != nil {
-						logrus.WithError(err).Error("save operator fail")
-						return "", err
-					}
-					go func() {
-						if _, exist := w.ledgerUseCase.QueryByUID(operator.UID); !exist {
-							_, _ = w.ledgerUseCase.Allocated(*operator)
-						}
-					}()
+func (w *wechatHandler) process(req WxReq) {
+	ctx := context.WithValue(context.Background(), common.CurrentUserID, req.FromUserName)
+	logger := logrus.WithContext(ctx).WithField("req", fmt.Sprintf("%+v", req))
+	var res string
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Errorf("handle async req panic! err:%+v, stack:%s", p, debug.Stack())
+			res = fmt.Sprintf("something is wrong with %s", p)
+		}
+		w.resCache.Add(req.MsgID, res)
+		if res == "" {
+			return
+		}
+		if err := w.messenger.SendText(ctx, req.FromUserName, res); err != nil {
+			logger.WithError(err).Error("send async reply fail")
+		}
+	}()
+	var err error
+	res, err = w.handleWechatMessage(ctx, req)
+	if err != nil {
+		res = common.Err(err)
+	}
+}
 
-					return common.Welcome(operator.Name), nil
-				},
-			}
+// handleWechatMessage 按消息类型及事件分发，返回空字符串时不回复用户
+func (w *wechatHandler) handleWechatMessage(ctx context.Context, req WxReq) (string, error) {
+	// 部分客户端及测试工具的 CDATA 中带有换行或空格
+	switch strings.TrimSpace(req.MsgType) {
+	case "text":
+		return w.handle(ctx, req, usecase.InboundMessage{Text: req.Content})
+	case "voice":
+		// 需在公众号后台开启语音识别
+		if strings.TrimSpace(req.Recognition) == "" {
+			return common.VoiceNotRecognized, nil
 		}
+		return w.handle(ctx, req, usecase.InboundMessage{Text: req.Recognition})
+	case "event":
+		return w.handleWechatEvent(ctx, req)
+	default:
+		logrus.WithContext(ctx).Infof("unsupported msg type %s", req.MsgType)
+		return common.UnsupportedMsgType, nil
 	}
-	if content != "" {
-		return Handler{
-			Name:     "ai answer",
-			NeedAuth: true,
-			Handle: func(operator *domain.User) (string, error) {
-				return content, nil
-			},
+}
+
+func (w *wechatHandler) handleWechatEvent(ctx context.Context, req WxReq) (string, error) {
+	logger := logrus.WithContext(ctx).WithField("event", req.Event).WithField("eventKey", req.EventKey)
+	switch strings.TrimSpace(req.Event) {
+	case "subscribe":
+		return w.handle(ctx, req, usecase.InboundMessage{Command: usecase.CommandWelcome})
+	case "unsubscribe":
+		logger.Info("user unsubscribe")
+		return "", nil
+	case "CLICK":
+		name, ok := menuCommands[strings.TrimSpace(req.EventKey)]
+		if !ok {
+			logger.Warn("unknown menu key")
+			return common.UnsupportedMsgType, nil
 		}
+		return w.handle(ctx, req, usecase.InboundMessage{Command: name})
+	default:
+		logger.Info("ignore event")
+		return "", nil
 	}
-	return Handler{
-		Name:     "NoThing",
-		NeedAuth: false,
-		Handle: func(operator *domain.User) (string, error) {
-			return "拜个早年吧", nil
-		},
+}
+
+// handle 补充渠道及用户信息后交由 Assistant 处理
+func (w *wechatHandler) handle(ctx context.Context, req WxReq, msg usecase.InboundMessage) (string, error) {
+	msg.Channel = usecase.ChannelWechat
+	msg.UserID = req.FromUserName
+	msg.MessageID = req.MsgID
+	reply, err := w.assistant.Handle(ctx, msg)
+	if err != nil {
+		return "", err
 	}
+	return reply.Text, nil
 }
 
-type Handler struct {
-	Name     string
-	NeedAuth bool
-	Handle   func(operator *domain.User) (string, error)
+const (
+	MenuViewLedger   = "VIEW_LEDGER"
+	MenuMonthSummary = "MONTH_SUMMARY"
+	MenuViewCategory = "VIEW_CATEGORY"
+	MenuUndoLast     = "UNDO_LAST"
+)
+
+// menuCommands 自定义菜单 CLICK 事件的 key 与指令的映射
+var menuCommands = map[string]string{
+	MenuViewLedger:   "get_ledger",
+	MenuMonthSummary: "query_bill",
+	MenuViewCategory: "get_category",
+	MenuUndoLast:     "undo_last",
 }
 
 func (w *wechatHandler) returnTextMsg(ctx *gin.Context, from, to, content string) {
+	if content == "" {
+		_, _ = ctx.Writer.WriteString("success")
+		return
+	}
 	res, _ := xml.Marshal(WxResp{
 		ToUserName:   to,
 		FromUserName: from,
@@ -412,17 +334,35 @@ func (w *wechatHandler) returnTextMsg(ctx *gin.Context, from, to, content string
 		MsgType:      "text",
 		Content:      content,
 	})
+	if ctx.GetBool(wechatEncryptKey) {
+		timestamp := ctx.Query("timestamp")
+		if timestamp == "" {
+			timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+		}
+		encrypted, err := w.crypto.Encrypt(res, timestamp, ctx.Query("nonce"))
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("encrypt resp fail!")
+			_, _ = ctx.Writer.WriteString("success")
+			return
+		}
+		res = encrypted
+	}
 	_, _ = ctx.Writer.Write(res)
 }
 
-func (w *wechatHandler) check(token, signature, timestamp, nonce string) bool {
-	l := sort.StringSlice{token, timestamp, nonce}
-	sort.Strings(l)
-	str := strings.Join(l, "")
-	if signature == fmt.Sprintf("%x", sha1.Sum([]byte(str))) {
-		return true
+// check 校验回调签名及时间戳，未配置 token 时拒绝所有请求
+func (w *wechatHandler) check(token, sign, timestamp, nonce string) bool {
+	if token == "" || sign == "" {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > wechatTimestampTolerance || d < -wechatTimestampTolerance {
+		return false
 	}
-	return false
+	return equalSignature(sign, signature(token, timestamp, nonce))
 }
 
 type WxReq struct {
@@ -439,6 +379,26 @@ type WxReq struct {
 	Content string `xml:"Content"`
 	// MsgID 消息类型（消息id，64位整型）
 	MsgID string `xml:"MsgId"`
+	// MediaID 图片、语音、视频消息的媒体id
+	MediaID string `xml:"MediaId"`
+	// Format 语音格式，如amr，speex等
+	Format string `xml:"Format"`
+	// Recognition 语音识别结果，UTF8编码
+	Recognition string `xml:"Recognition"`
+	// PicURL 图片链接
+	PicURL string `xml:"PicUrl"`
+	// LocationX 地理位置纬度
+	LocationX float64 `xml:"Location_X"`
+	// LocationY 地理位置经度
+	LocationY float64 `xml:"Location_Y"`
+	// Label 地理位置信息
+	Label string `xml:"Label"`
+	// Event 事件类型，subscribe(订阅)、unsubscribe(取消订阅)、CLICK(点击菜单)等
+	Event string `xml:"Event"`
+	// EventKey 事件KEY值，CLICK 事件中与自定义菜单接口中KEY值对应
+	EventKey string `xml:"EventKey"`
+	// Encrypt 安全模式及兼容模式下的加密消息体
+	Encrypt string `xml:"Encrypt"`
 }
 type WxResp struct {
 	XMLName xml.Name `xml:"xml"`
@@ -453,14 +413,3 @@ type WxResp struct {
 	// Content 文本消息内容
 	Content string `xml:"Content"`
 }
-
-type BookkeepingArgs struct {
-	Remark   string `json:"remark"`
-	Amount   string `json:"amount"`
-	Expenses string `json:"expenses"`
-	Category string `json:"category"`
-}
-
-type GetUserIdentityArgs struct {
-	Name string `json:"name"`
-}
@@ -2,10 +2,11 @@ package http
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/wangyuheng/richman/config"
 	"github.com/wangyuheng/richman/internal/interfaces/http/handler"
 )
 
-func NewEngine(wh handler.WechatHandler, dev handler.DevboxHandler) *gin.Engine {
+func NewEngine(cfg *config.Config, wh handler.WechatHandler, fh handler.FeishuHandler, api handler.APIHandler, eh handler.ExportHandler, ih handler.ImportHandler, dev handler.DevboxHandler) *gin.Engine {
 	router := gin.Default()
 
 	router.GET("", func(ctx *gin.Context) {
@@ -17,6 +18,10 @@ func NewEngine(wh handler.WechatHandler, dev handler.DevboxHandler) *gin.Engine
 	{
 		devbox.Any("GetUserByID", dev.GetUserByID)
 		devbox.Any("PreparedLedger", dev.PreparedLedger)
+		if cfg.WechatMock {
+			devbox.GET("wx/cgi-bin/token", dev.MockWechatToken)
+			devbox.POST("wx/cgi-bin/message/custom/send", dev.MockWechatCustomSend)
+		}
 	}
 
 	v2 := router.Group("/v2")
@@ -28,5 +33,27 @@ func NewEngine(wh handler.WechatHandler, dev handler.DevboxHandler) *gin.Engine
 		}
 	}
 
+	feishu := router.Group("/feishu")
+	{
+		feishu.POST("webhook", fh.Webhook)
+		feishu.POST("webhook/:app_id", fh.Webhook)
+	}
+
+	router.GET("/download/bills", eh.Download)
+
+	v1 := router.Group("/api/v1", api.Auth)
+	{
+		v1.GET("users/me", api.GetMe)
+		v1.GET("ledgers", api.ListLedgers)
+		v1.GET("bills", api.ListBills)
+		v1.POST("bills", api.CreateBill)
+		v1.GET("bills/:id", api.GetBill)
+		v1.PUT("bills/:id", api.UpdateBill)
+		v1.DELETE("bills/:id", api.DeleteBill)
+		v1.GET("reports", api.GetReport)
+		v1.GET("exports", eh.Export)
+		v1.POST("imports", ih.Import)
+	}
+
 	return router
 }
@@ -0,0 +1,41 @@
+package task
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"time"
+)
+
+// RecurringTask 定时生成到期的周期账单，启动时先执行一次，补记停机期间到期的账单
+type RecurringTask struct {
+	timer     *time.Ticker
+	recurring usecase.RecurringUseCase
+}
+
+func (t *RecurringTask) Start() {
+	go func() {
+		t.materialize()
+		for range t.timer.C {
+			t.materialize()
+		}
+	}()
+}
+
+func (t *RecurringTask) materialize() {
+	defer func() {
+		if e := recover(); e != nil {
+			logrus.Errorf("materialize recurring bills fail! err: %v", e)
+		}
+	}()
+	if n := t.recurring.Materialize(context.Background(), time.Now()); n > 0 {
+		logrus.Infof("定时执行周期账单，记账 %d 笔", n)
+	}
+}
+
+func NewRecurringTask(recurring usecase.RecurringUseCase) *RecurringTask {
+	return &RecurringTask{
+		timer:     time.NewTicker(10 * time.Minute),
+		recurring: recurring,
+	}
+}
@@ -0,0 +1,40 @@
+package task
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"time"
+)
+
+// ReminderTask 定时提醒长时间没有记账的用户
+type ReminderTask struct {
+	timer    *time.Ticker
+	reminder usecase.ReminderUseCase
+}
+
+func (t *ReminderTask) Start() {
+	go func() {
+		for range t.timer.C {
+			t.remind()
+		}
+	}()
+}
+
+func (t *ReminderTask) remind() {
+	defer func() {
+		if e := recover(); e != nil {
+			logrus.Errorf("remind inactive users fail! err: %v", e)
+		}
+	}()
+	if n := t.reminder.Remind(context.Background(), time.Now()); n > 0 {
+		logrus.Infof("定时执行记账提醒 %d 人", n)
+	}
+}
+
+func NewReminderTask(reminder usecase.ReminderUseCase) *ReminderTask {
+	return &ReminderTask{
+		timer:    time.NewTicker(30 * time.Minute),
+		reminder: reminder,
+	}
+}
@@ -0,0 +1,40 @@
+package task
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/usecase"
+	"time"
+)
+
+// ReportTask 定时推送用户订阅的收支报告
+type ReportTask struct {
+	timer  *time.Ticker
+	report usecase.ReportUseCase
+}
+
+func (t *ReportTask) Start() {
+	go func() {
+		for range t.timer.C {
+			t.push()
+		}
+	}()
+}
+
+func (t *ReportTask) push() {
+	defer func() {
+		if e := recover(); e != nil {
+			logrus.Errorf("push reports fail! err: %v", e)
+		}
+	}()
+	if n := t.report.Push(context.Background(), time.Now()); n > 0 {
+		logrus.Infof("定时推送收支报告 %d 份", n)
+	}
+}
+
+func NewReportTask(report usecase.ReportUseCase) *ReportTask {
+	return &ReportTask{
+		timer:  time.NewTicker(5 * time.Minute),
+		report: report,
+	}
+}
@@ -12,6 +12,19 @@ type Tasker interface {
 	Start()
 }
 
+// Tasks 依次启动多个定时任务
+type Tasks []Tasker
+
+func (t Tasks) Start() {
+	for _, it := range t {
+		it.Start()
+	}
+}
+
+func NewTasks(warm *WarmTask, recurring *RecurringTask, report *ReportTask, reminder *ReminderTask) Tasker {
+	return Tasks{warm, recurring, report, reminder}
+}
+
 type WarmTask struct {
 	timer      *time.Ticker
 	warmTimer  *time.Ticker
@@ -38,7 +51,7 @@ func (t *WarmTask) Start() {
 	}()
 }
 
-func NewWarmTask(ledger usecase.LedgerUseCase, userRepo domain.UserRepository, ledgerRepo domain.LedgerRepository) Tasker {
+func NewWarmTask(ledger usecase.LedgerUseCase, userRepo domain.UserRepository, ledgerRepo domain.LedgerRepository) *WarmTask {
 	return &WarmTask{
 		timer:      time.NewTicker(5 * time.Minute),
 		warmTimer:  time.NewTicker(1 * time.Hour),
@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+)
+
+const (
+	ChannelWechat = "wechat"
+	ChannelFeishu = "feishu"
+)
+
+// CommandWelcome 欢迎语，用于关注事件等场景，不对 AI 开放
+const CommandWelcome = "welcome"
+
+// InboundMessage 各消息渠道统一后的入站消息
+type InboundMessage struct {
+	// Channel 消息渠道，如 wechat、feishu
+	Channel string
+	// UserID 渠道内的用户标识，如公众号 OpenID、飞书 open_id
+	UserID    string
+	MessageID string
+	Text      string
+	// Command 直接执行的指令，如菜单点击，为空时解析 Text
+	Command string
+}
+
+// Reply 处理结果，Text 为空时无需回复用户
+type Reply struct {
+	Text string
+	// Command 实际执行的指令
+	Command string
+}
+
+// Assistant 渠道无关的指令处理，负责意图解析、用户校验及指令执行
+type Assistant interface {
+	Handle(ctx context.Context, msg InboundMessage) (*Reply, error)
+}
+
+type assistant struct {
+	aiService        domain.AIService
+	intentParser     domain.IntentParser
+	ruleFirst        bool
+	billUseCase      BillUseCase
+	userUseCase      UserUseCase
+	ledgerUseCase    LedgerUseCase
+	authUseCase      AuthUseCase
+	exportUseCase    ExportUseCase
+	budgetUseCase    BudgetUseCase
+	recurringUseCase RecurringUseCase
+	reportUseCase    ReportUseCase
+	reminderUseCase  ReminderUseCase
+}
+
+func NewAssistant(cfg *config.Config, billUseCase BillUseCase, aiService domain.AIService, intentParser domain.IntentParser, ledgerUseCase LedgerUseCase, userUseCase UserUseCase, authUseCase AuthUseCase, exportUseCase ExportUseCase, budgetUseCase BudgetUseCase, recurringUseCase RecurringUseCase, reportUseCase ReportUseCase, reminderUseCase ReminderUseCase) Assistant {
+	return &assistant{
+		aiService:        aiService,
+		intentParser:     intentParser,
+		ruleFirst:        cfg.AiRuleFirst,
+		billUseCase:      billUseCase,
+		ledgerUseCase:    ledgerUseCase,
+		userUseCase:      userUseCase,
+		authUseCase:      authUseCase,
+		exportUseCase:    exportUseCase,
+		budgetUseCase:    budgetUseCase,
+		recurringUseCase: recurringUseCase,
+		reportUseCase:    reportUseCase,
+		reminderUseCase:  reminderUseCase,
+	}
+}
+
+func (a *assistant) Handle(ctx context.Context, msg InboundMessage) (*Reply, error) {
+	logrus.WithContext(ctx).
+		WithField("channel", msg.Channel).
+		WithField("messageID", msg.MessageID).
+		Infof("handle inbound message %+v", msg)
+	if msg.Command != "" {
+		return a.execute(ctx, &domain.AIFunctionCall{Name: msg.Command, Arguments: "{}"}, "", msg.UserID, msg.Channel)
+	}
+
+	cmd := common.Trim(msg.Text)
+	var js map[string]string
+	if json.Unmarshal([]byte(cmd), &js) == nil {
+		return &Reply{Text: common.NotSupport}, nil
+	}
+	if cmd == "搞一个" {
+		return &Reply{Text: common.NotSupport}, nil
+	}
+
+	resp, err := a.callFunctions(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return a.execute(ctx, resp.FunctionCall, resp.Content, msg.UserID, msg.Channel)
+}
+
+// execute 执行指令，需要登录的指令会先校验用户，channel 为主动推送时使用的渠道
+func (a *assistant) execute(ctx context.Context, call *domain.AIFunctionCall, content, UID, channel string) (*Reply, error) {
+	operator := &domain.User{
+		UID: UID,
+	}
+
+	h := a.buildHandler(ctx, call, content, channel)
+	if h.NeedAuth {
+		logrus.WithContext(ctx).Infof("exec handler %s", h.Name)
+		userExist := false
+		operator, userExist = a.userUseCase.GetByID(UID)
+		if !userExist || operator.Name == "" {
+			logrus.WithContext(ctx).Info("user not found, input required.")
+			return &Reply{Text: common.NotFoundUserName, Command: h.Name}, nil
+		}
+		a.reminderUseCase.Touch(ctx, UID, channel)
+	}
+	if h.Write && !a.writable(UID) {
+		return &Reply{Text: common.LedgerReadOnly, Command: h.Name}, nil
+	}
+	text, err := h.Handle(operator)
+	if err != nil {
+		return nil, err
+	}
+	return &Reply{Text: text, Command: h.Name}, nil
+}
+
+// ledger 用户的当前账本，还没有账本时分配新账本
+func (a *assistant) ledger(operator *domain.User) (*domain.Ledger, error) {
+	if ledger, exists := a.ledgerUseCase.Active(operator.UID); exists {
+		return ledger, nil
+	}
+	return a.ledgerUseCase.Allocated(*operator)
+}
+
+// writable 用户是否可以在当前账本记账，还没有账本时会在记账时分配
+func (a *assistant) writable(UID string) bool {
+	ledger, exists := a.ledgerUseCase.Active(UID)
+	if !exists {
+		return true
+	}
+	member, exists := a.ledgerUseCase.Member(ledger, UID)
+	return !exists || member.Writable()
+}
+
+// callFunctions 调用 AI 解析用户意图，AI 不可用时使用规则解析兜底
+func (a *assistant) callFunctions(ctx context.Context, content string) (*domain.AIMessage, error) {
+	if a.ruleFirst {
+		if call, ok := a.intentParser.Parse(ctx, content); ok {
+			return &domain.AIMessage{Role: "assistant", FunctionCall: call}, nil
+		}
+	}
+	resp, err := a.aiService.CallFunctions(ctx, content, buildAIFunctions())
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Warn("call ai fail, fallback to rule parser")
+		if call, ok := a.intentParser.Parse(ctx, content); ok {
+			return &domain.AIMessage{Role: "assistant", FunctionCall: call}, nil
+		}
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,1078 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (a *assistant) buildHandler(ctx context.Context, call *domain.AIFunctionCall, content, channel string) command {
+	if call != nil {
+		switch call.Name {
+		case "get_source_code":
+			return command{
+				Name:     call.Name,
+				NeedAuth: false,
+				Handle: func(operator *domain.User) (string, error) {
+					return "https://github.com/wangyuheng/richman", nil
+				},
+			}
+		case "get_ledger":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					if ledger.URL == "" {
+						return common.LocalLedger(ledger.Name), nil
+					}
+					return ledger.URL, nil
+				},
+			}
+		case "get_category":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					return strings.Join(a.billUseCase.ListCategory(ledger.AppToken, ledger.TableToken), "\r\n"), nil
+				},
+			}
+		case "query_bill":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args QueryBillArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					summary := a.billUseCase.Query(ctx, ledger, args.Filter())
+
+					details := make([]string, 0, len(summary.Categories))
+					for _, it := range summary.Categories {
+						details = append(details, common.CategoryDetail(it.Category, it.Expenses, it.Amount, it.Count))
+					}
+					return common.QueryResult(summary.StartDate.Format(queryDateLayout), summary.EndDate.Format(queryDateLayout), summary.Income, summary.Pay, details, summary.Unconverted), nil
+				},
+			}
+		case "query_member_spending":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args QueryMemberSpendingArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					filter := QueryBillArgs{StartDate: args.StartDate, EndDate: args.EndDate}.Filter().withDefaults(time.Now())
+					members := a.billUseCase.QueryByMember(ctx, ledger, filter)
+
+					details := make([]string, 0, len(members))
+					unconverted := 0
+					for _, it := range members {
+						unconverted += it.Unconverted
+						// 只有缺少汇率账单的成员不展示
+						if it.Count == 0 {
+							continue
+						}
+						top := make([]string, 0, reportTopCategories)
+						for _, c := range it.Categories {
+							if len(top) == reportTopCategories {
+								break
+							}
+							top = append(top, common.CategoryAmount(c.Category, c.Amount))
+						}
+						details = append(details, common.MemberSpendingDetail(len(details)+1, it.AuthorName, it.Income, it.Pay, it.Count, top))
+					}
+					return common.MemberSpending(filter.StartDate.Format(queryDateLayout), filter.EndDate.Format(queryDateLayout), details, unconverted), nil
+				},
+			}
+		case "export_bill":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args ExportBillArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					// 链接中固定时间范围，避免跨月打开时内容变化
+					filter := QueryBillArgs{StartDate: args.StartDate, EndDate: args.EndDate}.Filter().withDefaults(time.Now())
+					ledger, exists := a.ledgerUseCase.Active(operator.UID)
+					if !exists {
+						return common.BillNotFound, nil
+					}
+					link, err := a.exportUseCase.DownloadURL(operator.UID, ledger, args.format(), filter)
+					if err == ErrExportDisabled {
+						return common.ExportDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.ExportLink(filter.StartDate.Format(queryDateLayout), filter.EndDate.Format(queryDateLayout), args.format(), link), nil
+				},
+			}
+		case "bookkeeping":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args BookkeepingArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					amount, err := strconv.ParseFloat(args.Amount, 64)
+					if err != nil {
+						return common.AmountIllegal, nil
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					currency, ok := billCurrency(ledger, args.Currency)
+					if !ok {
+						return common.CurrencyIllegal, nil
+					}
+					bill := &domain.Bill{
+						Remark:     args.Remark,
+						Categories: []string{args.Category},
+						Amount:     amount,
+						Expenses:   args.Expenses,
+						AuthorID:   operator.UID,
+						AuthorName: operator.Name,
+						Currency:   currency,
+					}
+					converted, err := a.billUseCase.Convert(ctx, ledger, bill)
+					if err != nil {
+						logrus.WithContext(ctx).WithError(err).Warnf("convert bill currency fail! currency:%s", currency)
+						return common.RateNotFound(bill.CurrencyCode(), ledger.BaseCurrency()), nil
+					}
+					total := a.billUseCase.CurMonthTotal(ctx, ledger, common.Expenses(args.Expenses), converted)
+					budgets := a.budgetUseCase.Check(ctx, ledger, bill)
+					if err := a.billUseCase.Save(ledger.AppToken, ledger.TableToken, bill); err != nil {
+						return "", err
+					}
+					lines := []string{common.RecordSuccess(total, common.Expenses(args.Expenses))}
+					if bill.CurrencyCode() != ledger.BaseCurrency() {
+						lines = append(lines, common.CurrencyConverted(amount, bill.CurrencyCode(), converted, ledger.BaseCurrency()))
+					}
+					lines = append(lines, budgetLines(budgets)...)
+					return strings.Join(lines, "\r\n"), nil
+				},
+			}
+		case "split_bill":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args SplitBillArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					amount, err := strconv.ParseFloat(args.Amount, 64)
+					if err != nil {
+						return common.AmountIllegal, nil
+					}
+					shares, err := args.shares()
+					if err != nil {
+						return common.SplitIllegal, nil
+					}
+					names, self := args.participants(operator.Name)
+					splits, err := domain.NewSplits(amount, names, args.Mode, shares)
+					if err == domain.ErrSplitIllegal {
+						return common.SplitIllegal, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					currency, ok := billCurrency(ledger, args.Currency)
+					if !ok {
+						return common.CurrencyIllegal, nil
+					}
+					// 参与人为账本成员时记录成员标识，记账人本人直接使用操作人标识，避免成员称呼过期时结算成两个人
+					uids := make(map[string][]string)
+					for _, it := range a.ledgerUseCase.Members(ledger) {
+						if it.UID != operator.UID {
+							uids[it.Name] = append(uids[it.Name], it.UID)
+						}
+					}
+					details := make([]string, 0, len(splits))
+					for i := range splits {
+						switch matched := uids[splits[i].Name]; {
+						case self[i] || (splits[i].Name == operator.Name && len(matched) == 0):
+							splits[i].UID = operator.UID
+						case len(matched) > 1:
+							return common.SplitMemberAmbiguous(splits[i].Name), nil
+						case len(matched) == 1:
+							splits[i].UID = matched[0]
+						}
+						details = append(details, common.SplitShare(splits[i].Name, splits[i].Amount))
+					}
+					bill := &domain.Bill{
+						Remark:     args.Remark,
+						Categories: []string{args.Category},
+						Amount:     amount,
+						Expenses:   string(common.Pay),
+						AuthorID:   operator.UID,
+						AuthorName: operator.Name,
+						Currency:   currency,
+						Splits:     splits,
+					}
+					converted, err := a.billUseCase.Convert(ctx, ledger, bill)
+					if err != nil {
+						logrus.WithContext(ctx).WithError(err).Warnf("convert bill currency fail! currency:%s", currency)
+						return common.RateNotFound(bill.CurrencyCode(), ledger.BaseCurrency()), nil
+					}
+					total := a.billUseCase.CurMonthTotal(ctx, ledger, common.Pay, converted)
+					budgets := a.budgetUseCase.Check(ctx, ledger, bill)
+					if err := a.billUseCase.Save(ledger.AppToken, ledger.TableToken, bill); err != nil {
+						return "", err
+					}
+					lines := []string{common.RecordSuccess(total, common.Pay)}
+					if bill.CurrencyCode() != ledger.BaseCurrency() {
+						lines = append(lines, common.CurrencyConverted(amount, bill.CurrencyCode(), converted, ledger.BaseCurrency()))
+					}
+					lines = append(lines, common.SplitDetail(operator.Name, details))
+					lines = append(lines, budgetLines(budgets)...)
+					return strings.Join(lines, "\r\n"), nil
+				},
+			}
+		case "settle_up":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args QueryMemberSpendingArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					filter := QueryBillArgs{StartDate: args.StartDate, EndDate: args.EndDate}.Filter().withDefaults(time.Now())
+					_, transfers := a.billUseCase.Settle(ctx, ledger, filter)
+					lines := make([]string, 0, len(transfers))
+					for _, it := range transfers {
+						lines = append(lines, common.SettleTransfer(it.From, it.To, it.Amount))
+					}
+					return common.SettleResult(filter.StartDate.Format(queryDateLayout), filter.EndDate.Format(queryDateLayout), lines), nil
+				},
+			}
+		case "set_budget":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args SetBudgetArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					amount, err := strconv.ParseFloat(args.Amount, 64)
+					if err != nil {
+						return common.AmountIllegal, nil
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					category := strings.TrimSpace(args.Category)
+					status, err := a.budgetUseCase.Set(ctx, ledger, category, amount)
+					if err != nil {
+						return "", err
+					}
+					if amount <= 0 {
+						return common.BudgetRemoved(category), nil
+					}
+					return common.BudgetSet(category, status.Amount, status.Spent), nil
+				},
+			}
+		case "add_recurring_bill":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args RecurringBillArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					amount, err := strconv.ParseFloat(args.Amount, 64)
+					if err != nil || amount <= 0 {
+						return common.AmountIllegal, nil
+					}
+					day, err := strconv.Atoi(args.Day)
+					if err != nil || day < 1 || day > 31 {
+						return common.RecurringDayIllegal, nil
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					rule := &domain.RecurringBill{
+						Day:        day,
+						Remark:     args.Remark,
+						Category:   args.Category,
+						Amount:     amount,
+						Expenses:   args.expenses(),
+						AuthorID:   operator.UID,
+						AuthorName: operator.Name,
+					}
+					err = a.recurringUseCase.Add(ctx, ledger, rule)
+					if err == domain.ErrRecurringDisabled {
+						return common.RecurringDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					next, _ := rule.NextDueDate()
+					return common.RecurringAdded(recurringDesc(rule), next.Format(queryDateLayout)), nil
+				},
+			}
+		case "list_recurring_bill":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					ledger, exists := a.ledgerUseCase.Active(operator.UID)
+					if !exists {
+						return common.RecurringEmpty, nil
+					}
+					rules := a.recurringUseCase.List(ctx, ledger)
+					if len(rules) == 0 {
+						return common.RecurringEmpty, nil
+					}
+					lines := make([]string, 0, len(rules))
+					for _, it := range rules {
+						lines = append(lines, recurringDesc(it))
+					}
+					return strings.Join(lines, "\r\n"), nil
+				},
+			}
+		case "delete_recurring_bill":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args DeleteBillArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, exists := a.ledgerUseCase.Active(operator.UID)
+					if !exists {
+						return common.RecurringNotFound, nil
+					}
+					rule, exists, err := a.recurringUseCase.Remove(ctx, ledger, strings.TrimSpace(args.Remark))
+					if err != nil {
+						return "", err
+					}
+					if !exists {
+						return common.RecurringNotFound, nil
+					}
+					return common.RecurringRemoved(recurringDesc(rule)), nil
+				},
+			}
+		case "subscribe_report":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args ReportArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					name, ok := ReportName(args.Frequency)
+					hour, err := args.hour()
+					if !ok || err != nil {
+						return common.ReportIllegal, nil
+					}
+					if _, err = a.ledger(operator); err != nil {
+						return "", err
+					}
+					it, err := a.reportUseCase.Subscribe(ctx, *operator, channel, args.Frequency, hour)
+					if err == domain.ErrSubscriptionDisabled {
+						return common.ReportDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.ReportSubscribed(common.ReportSubscription(name, it.Hour)), nil
+				},
+			}
+		case "unsubscribe_report":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args ReportArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					name, ok := ReportName(args.Frequency)
+					if !ok {
+						return common.ReportIllegal, nil
+					}
+					exists, err := a.reportUseCase.Unsubscribe(ctx, operator.UID, args.Frequency)
+					if err == domain.ErrSubscriptionDisabled {
+						return common.ReportDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					if !exists {
+						return common.ReportNotFound, nil
+					}
+					return common.ReportUnsubscribed(name), nil
+				},
+			}
+		case "list_report_subscription":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					subscriptions := a.reportUseCase.List(ctx, operator.UID)
+					if len(subscriptions) == 0 {
+						return common.ReportEmpty, nil
+					}
+					lines := make([]string, 0, len(subscriptions))
+					for _, it := range subscriptions {
+						if name, ok := ReportName(it.Frequency); ok {
+							lines = append(lines, common.ReportSubscription(name, it.Hour))
+						}
+					}
+					return strings.Join(lines, "\r\n"), nil
+				},
+			}
+		case "get_report":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args ReportArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					if _, ok := ReportName(args.Frequency); !ok {
+						return common.ReportIllegal, nil
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					return a.reportUseCase.Build(ctx, ledger, args.Frequency, time.Now()), nil
+				},
+			}
+		case "set_reminder":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args SetReminderArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					enabled := args.Enabled != "false"
+					err := a.reminderUseCase.SetEnabled(ctx, operator.UID, channel, enabled)
+					if err == domain.ErrReminderDisabled {
+						return common.ReminderDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					if enabled {
+						return common.ReminderOn, nil
+					}
+					return common.ReminderOff, nil
+				},
+			}
+		case "batch_bookkeeping":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args BatchBookkeepingArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					if len(args.Bills) == 0 {
+						return common.AmountIllegal, nil
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					bills := make([]*domain.Bill, 0, len(args.Bills))
+					lines := make([]string, 0, len(args.Bills))
+					amounts := make(map[common.Expenses]float64)
+					for _, it := range args.Bills {
+						amount, err := strconv.ParseFloat(it.Amount, 64)
+						if err != nil {
+							return common.AmountIllegal, nil
+						}
+						currency, ok := billCurrency(ledger, it.Currency)
+						if !ok {
+							return common.CurrencyIllegal, nil
+						}
+						expenses := common.Expenses(it.Expenses)
+						if expenses != common.Income {
+							expenses = common.Pay
+						}
+						bill := &domain.Bill{
+							Remark:     it.Remark,
+							Categories: []string{it.Category},
+							Amount:     amount,
+							Expenses:   string(expenses),
+							AuthorID:   operator.UID,
+							AuthorName: operator.Name,
+							Currency:   currency,
+						}
+						converted, err := a.billUseCase.Convert(ctx, ledger, bill)
+						if err != nil {
+							logrus.WithContext(ctx).WithError(err).Warnf("convert bill currency fail! currency:%s", currency)
+							return common.RateNotFound(bill.CurrencyCode(), ledger.BaseCurrency()), nil
+						}
+						amounts[expenses] += converted
+						bills = append(bills, bill)
+						lines = append(lines, common.BillDesc(it.Remark, []string{it.Category}, amount, currency, string(expenses)))
+					}
+					totals := make(map[common.Expenses]float64)
+					for expenses, amount := range amounts {
+						totals[expenses] = a.billUseCase.CurMonthTotal(ctx, ledger, expenses, amount)
+					}
+					if err := a.billUseCase.SaveBatch(ledger.AppToken, ledger.TableToken, bills); err != nil {
+						return "", err
+					}
+					return common.BatchRecordSuccess(lines, totals), nil
+				},
+			}
+		case "update_bill":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args UpdateBillArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, exists := a.ledgerUseCase.Active(operator.UID)
+					if !exists {
+						return common.BillNotFound, nil
+					}
+					latest, exists := a.billUseCase.Latest(ledger.AppToken, ledger.TableToken, *operator, args.TargetRemark)
+					if !exists {
+						return common.BillNotFound, nil
+					}
+					// 修改副本，校验或保存失败时缓存中的账单保持不变
+					b := *latest
+					bill := &b
+					if args.Amount != "" {
+						amount, err := strconv.ParseFloat(args.Amount, 64)
+						if err != nil {
+							return common.AmountIllegal, nil
+						}
+						if len(bill.Splits) > 0 {
+							// 分摊账单按原有比例重新分摊
+							if bill.Splits, err = domain.RescaleSplits(bill.Splits, amount); err != nil {
+								return common.SplitIllegal, nil
+							}
+						}
+						bill.Amount = amount
+					}
+					if args.Remark != "" {
+						bill.Remark = args.Remark
+					}
+					if args.Category != "" {
+						bill.Categories = []string{args.Category}
+					}
+					if args.Expenses != "" {
+						bill.Expenses = args.Expenses
+					}
+					if args.Currency != "" {
+						currency, ok := billCurrency(ledger, args.Currency)
+						if !ok {
+							return common.CurrencyIllegal, nil
+						}
+						bill.Currency = currency
+						if _, err := a.billUseCase.Convert(ctx, ledger, bill); err != nil {
+							return common.RateNotFound(bill.CurrencyCode(), ledger.BaseCurrency()), nil
+						}
+					}
+					bill.AuthorID = operator.UID
+					bill.AuthorName = operator.Name
+					if err := a.billUseCase.Update(ledger.AppToken, ledger.TableToken, bill); err != nil {
+						return "", err
+					}
+					return common.UpdateSuccess(common.BillDesc(bill.Remark, bill.Categories, bill.Amount, bill.Currency, bill.Expenses)), nil
+				},
+			}
+		case "delete_bill", "undo_last":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Write:    true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args DeleteBillArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, exists := a.ledgerUseCase.Active(operator.UID)
+					if !exists {
+						return common.BillNotFound, nil
+					}
+					bill, exists := a.billUseCase.Latest(ledger.AppToken, ledger.TableToken, *operator, args.Remark)
+					if !exists {
+						return common.BillNotFound, nil
+					}
+					if err := a.billUseCase.Delete(ledger.AppToken, ledger.TableToken, bill); err != nil {
+						return "", err
+					}
+					return common.DeleteSuccess(common.BillDesc(bill.Remark, bill.Categories, bill.Amount, bill.Currency, bill.Expenses)), nil
+				},
+			}
+		case "create_ledger":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args LedgerNameArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					name := strings.TrimSpace(args.Name)
+					if name == "" {
+						return common.LedgerNameRequired, nil
+					}
+					ledger, err := a.ledgerUseCase.Create(ctx, *operator, name)
+					if err == domain.ErrMemberDisabled {
+						return common.MemberDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.LedgerCreated(ledger.Name), nil
+				},
+			}
+		case "list_ledger":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					if _, err := a.ledger(operator); err != nil {
+						return "", err
+					}
+					ledgers := a.ledgerUseCase.List(operator.UID)
+					lines := make([]string, 0, len(ledgers))
+					for i, it := range ledgers {
+						lines = append(lines, common.LedgerItem(i+1, it.Ledger.Name, it.Member.Role, it.Active))
+					}
+					return strings.Join(lines, "\r\n"), nil
+				},
+			}
+		case "switch_ledger":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args LedgerNameArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, err := a.ledgerUseCase.Switch(ctx, operator.UID, strings.TrimSpace(args.Name))
+					if err == ErrLedgerNotFound {
+						return common.LedgerNotFound, nil
+					}
+					if err == domain.ErrMemberDisabled {
+						return common.MemberDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.LedgerSwitched(ledger.Name), nil
+				},
+			}
+		case "rename_ledger":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args LedgerNameArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					name := strings.TrimSpace(args.Name)
+					if name == "" {
+						return common.LedgerNameRequired, nil
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					err = a.ledgerUseCase.Rename(ctx, ledger, *operator, name)
+					if err == ErrOwnerRequired {
+						return common.RenameForbidden, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.LedgerRenamed(name), nil
+				},
+			}
+		case "set_base_currency":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args BaseCurrencyArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					currency, ok := domain.ParseCurrency(args.Currency)
+					if !ok {
+						return common.CurrencyIllegal, nil
+					}
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					err = a.ledgerUseCase.SetCurrency(ctx, ledger, *operator, currency)
+					if err == ErrOwnerRequired {
+						return common.CurrencyForbidden, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.BaseCurrencySet(ledger.Name, currency), nil
+				},
+			}
+		case "invite_member":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args InviteMemberArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					invite, err := a.ledgerUseCase.Invite(ctx, ledger, *operator, args.Role)
+					if err == domain.ErrMemberDisabled {
+						return common.MemberDisabled, nil
+					}
+					if err == ErrOwnerRequired {
+						return common.InviteForbidden, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.LedgerInvite(invite.Code, invite.Role, int(inviteTTL.Hours())), nil
+				},
+			}
+		case "join_ledger":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					var args JoinLedgerArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					ledger, member, err := a.ledgerUseCase.Join(ctx, *operator, args.Code)
+					if err == domain.ErrMemberDisabled {
+						return common.MemberDisabled, nil
+					}
+					if err == ErrInviteInvalid {
+						return common.InviteInvalid, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.LedgerJoined(ledger.Name, member.Role), nil
+				},
+			}
+		case "list_member":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					ledger, err := a.ledger(operator)
+					if err != nil {
+						return "", err
+					}
+					members := a.ledgerUseCase.Members(ledger)
+					lines := make([]string, 0, len(members))
+					for _, it := range members {
+						lines = append(lines, common.LedgerMember(it.Name, it.Role))
+					}
+					return strings.Join(lines, "\r\n"), nil
+				},
+			}
+		case CommandWelcome:
+			return command{
+				Name:     call.Name,
+				NeedAuth: false,
+				Handle: func(operator *domain.User) (string, error) {
+					if user, exist := a.userUseCase.GetByID(operator.UID); exist && user.Name != "" {
+						return common.Welcome(user.Name), nil
+					}
+					return common.Subscribe, nil
+				},
+			}
+		case "get_api_token":
+			return command{
+				Name:     call.Name,
+				NeedAuth: true,
+				Handle: func(operator *domain.User) (string, error) {
+					token, expireAt, err := a.authUseCase.IssueToken(operator.UID)
+					if err == ErrAPIDisabled {
+						return common.APIDisabled, nil
+					}
+					if err != nil {
+						return "", err
+					}
+					return common.APIToken(token, expireAt.Format(queryDateLayout)), nil
+				},
+			}
+		case "get_user_identity":
+			return command{
+				Name:     call.Name,
+				NeedAuth: false,
+				Handle: func(operator *domain.User) (string, error) {
+					var args GetUserIdentityArgs
+					_ = json.Unmarshal([]byte(call.Arguments), &args)
+					operator.Name = args.Name
+
+					if err := a.userUseCase.Save(*operator); err != nil {
+						logrus.WithError(err).Error("save operator fail")
+						return "", err
+					}
+					go func() {
+						if _, exist := a.ledgerUseCase.Active(operator.UID); !exist {
+							_, _ = a.ledgerUseCase.Allocated(*operator)
+						}
+					}()
+
+					return common.Welcome(operator.Name), nil
+				},
+			}
+		}
+	}
+	if content != "" {
+		return command{
+			Name:     "ai answer",
+			NeedAuth: true,
+			Handle: func(operator *domain.User) (string, error) {
+				return content, nil
+			},
+		}
+	}
+	return command{
+		Name:     "NoThing",
+		NeedAuth: false,
+		Handle: func(operator *domain.User) (string, error) {
+			return "拜个早年吧", nil
+		},
+	}
+}
+
+// command 指令处理器，NeedAuth 为 true 时需要先设置称呼，Write 为 true 时需要当前账本的记账权限
+type command struct {
+	Name     string
+	NeedAuth bool
+	Write    bool
+	Handle   func(operator *domain.User) (string, error)
+}
+
+type BookkeepingArgs struct {
+	Remark   string `json:"remark"`
+	Amount   string `json:"amount"`
+	Expenses string `json:"expenses"`
+	Category string `json:"category"`
+	// Currency 币种名称或代码，为空时为账本本位币
+	Currency string `json:"currency"`
+}
+
+type SplitBillArgs struct {
+	Remark   string `json:"remark"`
+	Amount   string `json:"amount"`
+	Category string `json:"category"`
+	Currency string `json:"currency"`
+	// Participants 参与人称呼，「我」为记账人
+	Participants []string `json:"participants"`
+	// Mode equal、ratio、exact，默认 equal
+	Mode string `json:"mode"`
+	// Shares 按比例分摊时为比例，指定金额时为每人金额，与 Participants 一一对应
+	Shares []string `json:"shares"`
+}
+
+// participants 参与人称呼，「我」替换为记账人称呼，isSelf 标记对应的参与人是否为记账人本人
+func (a SplitBillArgs) participants(self string) (names []string, isSelf []bool) {
+	names = make([]string, 0, len(a.Participants))
+	isSelf = make([]bool, 0, len(a.Participants))
+	for _, it := range a.Participants {
+		it = strings.TrimSpace(it)
+		me := it == "我" || it == "自己" || it == "本人"
+		if me {
+			it = self
+		}
+		names = append(names, it)
+		isSelf = append(isSelf, me)
+	}
+	return names, isSelf
+}
+
+func (a SplitBillArgs) shares() ([]float64, error) {
+	res := make([]float64, 0, len(a.Shares))
+	for _, it := range a.Shares {
+		f, err := strconv.ParseFloat(strings.TrimSpace(it), 64)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+// billCurrency 解析账单币种，未指定时为账本本位币
+func billCurrency(ledger *domain.Ledger, currency string) (string, bool) {
+	if strings.TrimSpace(currency) == "" {
+		return ledger.BillCurrency(""), true
+	}
+	code, ok := domain.ParseCurrency(currency)
+	if !ok {
+		return "", false
+	}
+	return ledger.BillCurrency(code), true
+}
+
+// budgetLines 记账后回复的预算剩余及提醒
+func budgetLines(budgets []BudgetStatus) []string {
+	lines := make([]string, 0, len(budgets))
+	for _, it := range budgets {
+		lines = append(lines, common.BudgetRemaining(it.Category, it.Remaining()))
+		if it.Alert > 0 {
+			lines = append(lines, common.BudgetAlert(it.Category, it.Alert))
+		}
+	}
+	return lines
+}
+
+type BatchBookkeepingArgs struct {
+	Bills []BookkeepingArgs `json:"bills"`
+}
+
+type UpdateBillArgs struct {
+	TargetRemark string `json:"target_remark"`
+	Remark       string `json:"remark"`
+	Amount       string `json:"amount"`
+	Expenses     string `json:"expenses"`
+	Category     string `json:"category"`
+	Currency     string `json:"currency"`
+}
+
+type DeleteBillArgs struct {
+	Remark string `json:"remark"`
+}
+
+const queryDateLayout = "2006/01/02"
+
+type QueryBillArgs struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Expenses  string `json:"expenses"`
+	Category  string `json:"category"`
+}
+
+func (a QueryBillArgs) Filter() BillFilter {
+	filter := BillFilter{
+		Category: strings.TrimSpace(a.Category),
+	}
+	if start, err := time.ParseInLocation(queryDateLayout, a.StartDate, time.Local); err == nil {
+		filter.StartDate = start
+	}
+	if end, err := time.ParseInLocation(queryDateLayout, a.EndDate, time.Local); err == nil {
+		filter.EndDate = end
+	}
+	if e := common.Expenses(a.Expenses); e == common.Income || e == common.Pay {
+		filter.Expenses = e
+	}
+	return filter
+}
+
+type QueryMemberSpendingArgs struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+type SetBudgetArgs struct {
+	// Category 为空时设置总预算
+	Category string `json:"category"`
+	Amount   string `json:"amount"`
+}
+
+type ExportBillArgs struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Format    string `json:"format"`
+}
+
+func (a ExportBillArgs) format() string {
+	if a.Format == "" {
+		return "xlsx"
+	}
+	return strings.ToLower(a.Format)
+}
+
+type RecurringBillArgs struct {
+	// Day 每月几号
+	Day      string `json:"day"`
+	Remark   string `json:"remark"`
+	Amount   string `json:"amount"`
+	Expenses string `json:"expenses"`
+	Category string `json:"category"`
+}
+
+func (a RecurringBillArgs) expenses() string {
+	if common.Expenses(a.Expenses) == common.Income {
+		return string(common.Income)
+	}
+	return string(common.Pay)
+}
+
+func recurringDesc(rule *domain.RecurringBill) string {
+	return common.RecurringDesc(rule.Day, common.BillDesc(rule.Remark, []string{rule.Category}, rule.Amount, "", rule.Expenses))
+}
+
+type ReportArgs struct {
+	// Frequency daily、weekly、monthly
+	Frequency string `json:"frequency"`
+	// Hour 推送时间，为空时使用默认时间
+	Hour string `json:"hour"`
+}
+
+func (a ReportArgs) hour() (int, error) {
+	if a.Hour == "" {
+		return -1, nil
+	}
+	hour, err := strconv.Atoi(a.Hour)
+	if err == nil && (hour < 0 || hour > 23) {
+		err = fmt.Errorf("illegal hour %d", hour)
+	}
+	return hour, err
+}
+
+type SetReminderArgs struct {
+	// Enabled 为 false 时关闭提醒
+	Enabled string `json:"enabled"`
+}
+
+type LedgerNameArgs struct {
+	// Name 账本名称，切换账本时也可以是账本列表中的序号
+	Name string `json:"name"`
+}
+
+type BaseCurrencyArgs struct {
+	Currency string `json:"currency"`
+}
+
+type InviteMemberArgs struct {
+	// Role editor 或 viewer，默认 editor
+	Role string `json:"role"`
+}
+
+type JoinLedgerArgs struct {
+	Code string `json:"code"`
+}
+
+type GetUserIdentityArgs struct {
+	Name string `json:"name"`
+}
@@ -0,0 +1,581 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/wangyuheng/richman/internal/domain"
+	"time"
+)
+
+func buildAIFunctions() domain.AI {
+	currentTime := time.Now().Format("2006-01-02 15:04:05")
+	currentDate := time.Now().Format("2006/01/02")
+	expenses := []string{"收入", "支出"}
+	bookkeepingRequired := []string{"remark", "amount", "expenses", "category"}
+	batchBookkeepingRequired := []string{"bills"}
+	splitModes := []string{domain.SplitEqual, domain.SplitRatio, domain.SplitExact}
+	splitBillRequired := []string{"remark", "amount", "category", "participants"}
+	deleteBillRequired := []string{"remark"}
+	setBudgetRequired := []string{"amount"}
+	reportFrequencies := []string{domain.ReportDaily, domain.ReportWeekly, domain.ReportMonthly}
+	reportRequired := []string{"frequency"}
+	reminderEnabled := []string{"true", "false"}
+	setReminderRequired := []string{"enabled"}
+	memberRoles := []string{domain.LedgerEditor, domain.LedgerViewer}
+	joinLedgerRequired := []string{"code"}
+	ledgerNameRequired := []string{"name"}
+	baseCurrencyRequired := []string{"currency"}
+	recurringBillRequired := []string{"day", "remark", "amount", "expenses", "category"}
+	exportFormats := []string{"xlsx", "csv", "json", "beancount", "ledger", "hledger"}
+	return domain.AI{
+		Introduction: fmt.Sprintf("你叫Richman 是一个基于飞书表格的记账软件。当前时间是 %s 如果不明确用户的意图，可以指导用户使用这个如见。比如：可以通过输入包子花了15或者工资收入100 用来记账", currentTime),
+		Functions: []domain.AIFunction{
+			{
+				Name:        "bookkeeping",
+				Description: "记账工具，支持记录收入支出",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"remark": {
+							Type:        "string",
+							Description: "名称或描述",
+						},
+						"amount": {
+							Type:        "string",
+							Description: "账单金额 format by float64",
+						},
+						"expenses": {
+							Type:        "string",
+							Description: "收入还是支出",
+							Enum:        &expenses,
+						},
+						"category": {
+							Type:        "string",
+							Description: "账单分类",
+						},
+						"currency": {
+							Type:        "string",
+							Description: "金额的币种，如美元、日元或 USD、JPY，人民币或未提及时为空",
+						},
+					},
+					Required: &bookkeepingRequired,
+				},
+			},
+			{
+				Name:        "batch_bookkeeping",
+				Description: "批量记账，一条消息包含多笔账单时使用，如：早餐 12 午饭 35 打车 28",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"bills": {
+							Type:        "array",
+							Description: "账单列表",
+							Items: &domain.AIProperty{
+								Type:        "object",
+								Description: "单笔账单",
+								Properties: map[string]domain.AIProperty{
+									"remark": {
+										Type:        "string",
+										Description: "名称或描述",
+									},
+									"amount": {
+										Type:        "string",
+										Description: "账单金额 format by float64",
+									},
+									"expenses": {
+										Type:        "string",
+										Description: "收入还是支出",
+										Enum:        &expenses,
+									},
+									"category": {
+										Type:        "string",
+										Description: "账单分类",
+									},
+									"currency": {
+										Type:        "string",
+										Description: "金额的币种，如美元、日元或 USD、JPY，人民币或未提及时为空",
+									},
+								},
+								Required: &bookkeepingRequired,
+							},
+						},
+					},
+					Required: &batchBookkeepingRequired,
+				},
+			},
+			{
+				Name:        "split_bill",
+				Description: "记一笔由记账人付款、多人分摊的支出，如：AA 晚饭 300 我和小王、房租 3000 我和小王按 2:1 分摊",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"remark": {
+							Type:        "string",
+							Description: "名称或描述",
+						},
+						"amount": {
+							Type:        "string",
+							Description: "账单总金额 format by float64",
+						},
+						"category": {
+							Type:        "string",
+							Description: "账单分类",
+						},
+						"currency": {
+							Type:        "string",
+							Description: "金额的币种，如美元、日元或 USD、JPY，人民币或未提及时为空",
+						},
+						"participants": {
+							Type:        "array",
+							Description: "参与分摊的人，包括记账人自己时使用「我」",
+							Items: &domain.AIProperty{
+								Type:        "string",
+								Description: "参与人称呼",
+							},
+						},
+						"mode": {
+							Type:        "string",
+							Description: "分摊方式，equal 平均分摊，ratio 按比例，exact 指定每人金额，默认 equal",
+							Enum:        &splitModes,
+						},
+						"shares": {
+							Type:        "array",
+							Description: "与参与人一一对应，ratio 时为比例，exact 时为每人金额，equal 时为空",
+							Items: &domain.AIProperty{
+								Type:        "string",
+								Description: "比例或金额 format by float64",
+							},
+						},
+					},
+					Required: &splitBillRequired,
+				},
+			},
+			{
+				Name:        "settle_up",
+				Description: "按分摊账单计算成员之间的欠款，给出最少的转账方案，如：结算、谁欠谁",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"start_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("结算开始时间，今天的日期是 %s，格式为 yyyy/mm/dd，默认本月", currentDate),
+						},
+						"end_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("结算结束时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
+						},
+					},
+				},
+			},
+			{
+				Name:        "update_bill",
+				Description: "修改已记录的账单，如：刚才那笔改成 25",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"target_remark": {
+							Type:        "string",
+							Description: "要修改的账单名称，不指定时修改最近一笔",
+						},
+						"remark": {
+							Type:        "string",
+							Description: "修改后的名称或描述",
+						},
+						"amount": {
+							Type:        "string",
+							Description: "修改后的账单金额 format by float64",
+						},
+						"expenses": {
+							Type:        "string",
+							Description: "修改后的收入还是支出",
+							Enum:        &expenses,
+						},
+						"category": {
+							Type:        "string",
+							Description: "修改后的账单分类",
+						},
+						"currency": {
+							Type:        "string",
+							Description: "修改后的币种，如美元、USD",
+						},
+					},
+				},
+			},
+			{
+				Name:        "delete_bill",
+				Description: "删除指定名称的最近一笔账单",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"remark": {
+							Type:        "string",
+							Description: "要删除的账单名称",
+						},
+					},
+					Required: &deleteBillRequired,
+				},
+			},
+			{
+				Name:        "undo_last",
+				Description: "撤销最近记录的一笔账单",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "query_bill",
+				Description: "查询账单信息",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"start_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("查账开始时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
+						},
+						"end_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("查账结束时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
+						},
+						"expenses": {
+							Type:        "string",
+							Description: "收入还是支出",
+							Enum:        &expenses,
+						},
+						"category": {
+							Type:        "string",
+							Description: "要查询的账单分类",
+						},
+					},
+				},
+			},
+			{
+				Name:        "query_member_spending",
+				Description: "按账本成员统计收支及支出最多的分类，如：谁花得最多、这个月每个人花了多少",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"start_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("统计开始时间，今天的日期是 %s，格式为 yyyy/mm/dd，默认本月", currentDate),
+						},
+						"end_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("统计结束时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
+						},
+					},
+				},
+			},
+			{
+				Name:        "set_budget",
+				Description: "设置每月预算，支持总预算及分类预算，金额为 0 时取消预算",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"category": {
+							Type:        "string",
+							Description: "预算分类，总预算时为空",
+						},
+						"amount": {
+							Type:        "string",
+							Description: "每月预算金额 format by float64",
+						},
+					},
+					Required: &setBudgetRequired,
+				},
+			},
+			{
+				Name:        "add_recurring_bill",
+				Description: "添加每月固定日期自动记账的周期账单，如：每月1号房租 3000、每月15号工资 20000",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"day": {
+							Type:        "string",
+							Description: "每月几号记账，1 到 31",
+						},
+						"remark": {
+							Type:        "string",
+							Description: "名称或描述",
+						},
+						"amount": {
+							Type:        "string",
+							Description: "账单金额 format by float64",
+						},
+						"expenses": {
+							Type:        "string",
+							Description: "收入还是支出",
+							Enum:        &expenses,
+						},
+						"category": {
+							Type:        "string",
+							Description: "账单分类",
+						},
+					},
+					Required: &recurringBillRequired,
+				},
+			},
+			{
+				Name:        "list_recurring_bill",
+				Description: "查看已添加的周期账单",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "delete_recurring_bill",
+				Description: "删除指定名称的周期账单，不再自动记账",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"remark": {
+							Type:        "string",
+							Description: "要删除的周期账单名称",
+						},
+					},
+					Required: &deleteBillRequired,
+				},
+			},
+			{
+				Name:        "subscribe_report",
+				Description: "订阅定期推送的收支报告，如：订阅日报 21点、每周推送周报",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"frequency": {
+							Type:        "string",
+							Description: "报告频率，daily 日报、weekly 周报、monthly 月报",
+							Enum:        &reportFrequencies,
+						},
+						"hour": {
+							Type:        "string",
+							Description: "推送时间，0 到 23 点，不指定时使用默认时间",
+						},
+					},
+					Required: &reportRequired,
+				},
+			},
+			{
+				Name:        "unsubscribe_report",
+				Description: "取消订阅收支报告",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"frequency": {
+							Type:        "string",
+							Description: "报告频率，daily 日报、weekly 周报、monthly 月报",
+							Enum:        &reportFrequencies,
+						},
+					},
+					Required: &reportRequired,
+				},
+			},
+			{
+				Name:        "list_report_subscription",
+				Description: "查看已订阅的收支报告",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "get_report",
+				Description: "立即查看今天、本周或本月的收支报告",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"frequency": {
+							Type:        "string",
+							Description: "报告频率，daily 日报、weekly 周报、monthly 月报",
+							Enum:        &reportFrequencies,
+						},
+					},
+					Required: &reportRequired,
+				},
+			},
+			{
+				Name:        "set_reminder",
+				Description: "开启或关闭长时间没有记账时的提醒，如：关闭提醒",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"enabled": {
+							Type:        "string",
+							Description: "true 开启，false 关闭",
+							Enum:        &reminderEnabled,
+						},
+					},
+					Required: &setReminderRequired,
+				},
+			},
+			{
+				Name:        "create_ledger",
+				Description: "新建一个账本并切换为当前账本，如：新建账本 副业",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"name": {
+							Type:        "string",
+							Description: "账本名称",
+						},
+					},
+					Required: &ledgerNameRequired,
+				},
+			},
+			{
+				Name:        "list_ledger",
+				Description: "查看用户创建及加入的所有账本",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "switch_ledger",
+				Description: "切换当前账本，之后的记账和查询使用该账本，如：切换账本 家庭",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"name": {
+							Type:        "string",
+							Description: "账本名称或账本列表中的序号",
+						},
+					},
+					Required: &ledgerNameRequired,
+				},
+			},
+			{
+				Name:        "rename_ledger",
+				Description: "修改当前账本的名称，如：账本改名 家庭",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"name": {
+							Type:        "string",
+							Description: "新的账本名称",
+						},
+					},
+					Required: &ledgerNameRequired,
+				},
+			},
+			{
+				Name:        "set_base_currency",
+				Description: "设置当前账本的本位币，统计时外币账单换算为本位币，如：本位币 美元",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"currency": {
+							Type:        "string",
+							Description: "币种名称或代码，如人民币、USD",
+						},
+					},
+					Required: &baseCurrencyRequired,
+				},
+			},
+			{
+				Name:        "invite_member",
+				Description: "邀请家人、室友等加入当前账本一起记账，返回邀请码",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"role": {
+							Type:        "string",
+							Description: "成员权限，editor 可以记账，viewer 只能查看，默认 editor",
+							Enum:        &memberRoles,
+						},
+					},
+				},
+			},
+			{
+				Name:        "join_ledger",
+				Description: "凭邀请码加入他人的账本，如：加入账本 ABC123",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"code": {
+							Type:        "string",
+							Description: "邀请码",
+						},
+					},
+					Required: &joinLedgerRequired,
+				},
+			},
+			{
+				Name:        "list_member",
+				Description: "查看当前账本的成员",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "export_bill",
+				Description: "导出账单文件，返回下载链接",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"start_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("导出开始时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
+						},
+						"end_date": {
+							Type:        "string",
+							Description: fmt.Sprintf("导出结束时间，今天的日期是 %s，格式为 yyyy/mm/dd", currentDate),
+						},
+						"format": {
+							Type:        "string",
+							Description: "文件格式，默认 xlsx",
+							Enum:        &exportFormats,
+						},
+					},
+				},
+			},
+			{
+				Name:        "get_ledger",
+				Description: "获取账本信息，如: URL",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "get_category",
+				Description: "获取分类",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "get_user_identity",
+				Description: "获取用户的称呼",
+				Parameters: domain.AIParameter{
+					Type: "object",
+					Properties: map[string]domain.AIProperty{
+						"name": {
+							Type:        "string",
+							Description: "用户希望被称呼的名字",
+						},
+					},
+				},
+			},
+			{
+				Name:        "get_api_token",
+				Description: "获取开放接口的访问令牌",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+			{
+				Name:        "get_source_code",
+				Description: "获取源代码",
+				Parameters: domain.AIParameter{
+					Type:       "object",
+					Properties: map[string]domain.AIProperty{},
+				},
+			},
+		},
+	}
+
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAPIDisabled  = errors.New("api is disabled")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// 签名用途，不同用途的签名互不通用，避免下载链接的签名被当作令牌使用
+const (
+	purposeAPIToken = "api-token"
+	PurposeDownload = "download"
+)
+
+// AuthUseCase 开放接口的令牌签发及校验
+type AuthUseCase interface {
+	// IssueToken 为用户签发令牌，返回令牌及过期时间，更换 API_SECRET 后全部失效
+	IssueToken(UID string) (string, time.Time, error)
+	// Authenticate 校验令牌及有效期并返回对应的用户
+	Authenticate(token string) (*domain.User, error)
+	// Sign 按用途对任意内容签名，用于生成下载链接等
+	Sign(purpose, payload string) (string, error)
+	// Verify 校验 Sign 生成的签名，用途须一致，未开放接口时始终失败
+	Verify(purpose, payload, signature string) bool
+}
+
+type authUseCase struct {
+	secret      []byte
+	tokenTTL    time.Duration
+	userUseCase UserUseCase
+}
+
+func NewAuthUseCase(cfg *config.Config, userUseCase UserUseCase) AuthUseCase {
+	return &authUseCase{
+		secret:      []byte(cfg.APISecret),
+		tokenTTL:    cfg.APITokenTTL,
+		userUseCase: userUseCase,
+	}
+}
+
+// IssueToken 令牌格式为 base64(UID).过期时间戳.签名
+func (a *authUseCase) IssueToken(UID string) (string, time.Time, error) {
+	if len(a.secret) == 0 {
+		return "", time.Time{}, ErrAPIDisabled
+	}
+	expireAt := time.Now().Add(a.tokenTTL)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(UID)) + "." + strconv.FormatInt(expireAt.Unix(), 10)
+	return payload + "." + a.sign(purposeAPIToken, payload), expireAt, nil
+}
+
+func (a *authUseCase) Authenticate(token string) (*domain.User, error) {
+	if len(a.secret) == 0 {
+		return nil, ErrAPIDisabled
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(a.sign(purposeAPIToken, payload))) {
+		return nil, ErrInvalidToken
+	}
+	expireAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expireAt {
+		return nil, ErrInvalidToken
+	}
+	UID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	user, exist := a.userUseCase.GetByID(string(UID))
+	if !exist || user.Name == "" {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
+
+func (a *authUseCase) Sign(purpose, payload string) (string, error) {
+	if len(a.secret) == 0 {
+		return "", ErrAPIDisabled
+	}
+	return a.sign(purpose, payload), nil
+}
+
+func (a *authUseCase) Verify(purpose, payload, signature string) bool {
+	if len(a.secret) == 0 {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(a.sign(purpose, payload)))
+}
+
+// sign HMAC-SHA256 签名，签名内容以用途为前缀
+func (a *authUseCase) sign(purpose, payload string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(purpose + "\n" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
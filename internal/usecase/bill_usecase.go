@@ -1,34 +1,179 @@
 package usecase
 
 import (
+	"context"
 	"fmt"
 	"github.com/geeklubcn/feishu-bitable-db/db"
+	"github.com/sirupsen/logrus"
 	"github.com/wangyuheng/richman/internal/common"
 	"github.com/wangyuheng/richman/internal/domain"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
 
 type BillUseCase interface {
 	Save(appToken, tableToken string, bill *domain.Bill) error
+	SaveBatch(appToken, tableToken string, bills []*domain.Bill) error
 	GetCategory(appToken, tableToken, remark string) []string
-	CurMonthTotal(appToken, tableToken string, expenses common.Expenses, amount float64) float64
+	// CurMonthTotal 本月收入或支出的合计加上 amount，按账本本位币计算
+	CurMonthTotal(ctx context.Context, ledger *domain.Ledger, expenses common.Expenses, amount float64) float64
+	// Convert 按账单日期的汇率将账单金额换算为账本本位币
+	Convert(ctx context.Context, ledger *domain.Ledger, bill *domain.Bill) (float64, error)
 	ListCategory(appToken, tableToken string) []string
+	Query(ctx context.Context, ledger *domain.Ledger, filter BillFilter) *BillSummary
+	// Settle 汇总分摊账单的净额，并生成结清的转账
+	Settle(ctx context.Context, ledger *domain.Ledger, filter BillFilter) ([]domain.Balance, []domain.Transfer)
+	// QueryByMember 按记账人统计收支，按支出倒序
+	QueryByMember(ctx context.Context, ledger *domain.Ledger, filter BillFilter) []*MemberSummary
+	// List 按条件查询账单明细，未指定时间范围时不限制，按日期倒序
+	List(ctx context.Context, ledger *domain.Ledger, filter BillFilter) []*domain.Bill
+	// Scan 按条件逐页遍历账单，按日期顺序回调 fn，未指定时间范围时不限制
+	Scan(ctx context.Context, ledger *domain.Ledger, filter BillFilter, fn func(bill *domain.Bill) error) error
+	// Page 按条件分页查询账单明细，按日期倒序，返回当前页账单及符合条件的总数
+	Page(ctx context.Context, ledger *domain.Ledger, filter BillFilter, offset, limit int) ([]*domain.Bill, int, error)
+	Get(appToken, tableToken, id string) (*domain.Bill, bool)
+	Update(appToken, tableToken string, bill *domain.Bill) error
+	Delete(appToken, tableToken string, bill *domain.Bill) error
+	// Latest 查询操作人最近的一笔账单，remark 为空时不限制名称
+	Latest(appToken, tableToken string, operator domain.User, remark string) (*domain.Bill, bool)
+}
+
+// BillFilter 账单查询条件，零值字段不参与过滤
+type BillFilter struct {
+	// StartDate 开始日期（含）
+	StartDate time.Time
+	// EndDate 结束日期（含）
+	EndDate  time.Time
+	Expenses common.Expenses
+	Category string
+}
+
+type BillSummary struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Income    float64
+	Pay       float64
+	Count     int
+	// Unconverted 缺少汇率、未计入统计的外币账单数
+	Unconverted int
+	Categories  []CategorySummary
+}
+
+// MemberSummary 记账人在查询范围内的收支，Categories 为支出分类，按金额倒序
+type MemberSummary struct {
+	AuthorID   string
+	AuthorName string
+	Income     float64
+	Pay        float64
+	Count      int
+	// Unconverted 缺少汇率、未计入统计的外币账单数
+	Unconverted int
+	Categories  []CategorySummary
+}
+
+type CategorySummary struct {
+	Category string
+	Expenses common.Expenses
+	Amount   float64
+	Count    int
 }
 
 type billUseCase struct {
 	billRepository domain.BillRepository
+	rates          domain.RateProvider
 	cache          sync.Map
 }
 
-func NewBillUseCase(billRepository domain.BillRepository) BillUseCase {
+func NewBillUseCase(billRepository domain.BillRepository, rates domain.RateProvider) BillUseCase {
 	return &billUseCase{
 		billRepository: billRepository,
+		rates:          rates,
 	}
 }
 
 func (b *billUseCase) Save(appToken, tableToken string, bill *domain.Bill) error {
-	return b.billRepository.Save(appToken, tableToken, bill)
+	if err := b.billRepository.Save(appToken, tableToken, bill); err != nil {
+		return err
+	}
+	if bill.ID != "" {
+		b.cache.Store(b.lastBillCacheKey(appToken, bill.AuthorID), bill)
+	}
+	return nil
+}
+
+func (b *billUseCase) SaveBatch(appToken, tableToken string, bills []*domain.Bill) error {
+	if len(bills) == 0 {
+		return nil
+	}
+	if err := b.billRepository.SaveBatch(appToken, tableToken, bills); err != nil {
+		return err
+	}
+	if last := bills[len(bills)-1]; last.ID != "" {
+		b.cache.Store(b.lastBillCacheKey(appToken, last.AuthorID), last)
+	}
+	return nil
+}
+
+func (b *billUseCase) Update(appToken, tableToken string, bill *domain.Bill) error {
+	if err := b.billRepository.Update(appToken, tableToken, bill); err != nil {
+		return err
+	}
+	if v, ok := b.cache.Load(b.lastBillCacheKey(appToken, bill.AuthorID)); ok {
+		if vv, ok := v.(*domain.Bill); ok && vv.ID == bill.ID {
+			b.cache.Store(b.lastBillCacheKey(appToken, bill.AuthorID), bill)
+		}
+	}
+	return nil
+}
+
+func (b *billUseCase) Delete(appToken, tableToken string, bill *domain.Bill) error {
+	if err := b.billRepository.Delete(appToken, tableToken, bill.ID); err != nil {
+		return err
+	}
+	if v, ok := b.cache.Load(b.lastBillCacheKey(appToken, bill.AuthorID)); ok {
+		if vv, ok := v.(*domain.Bill); ok && vv.ID == bill.ID {
+			b.cache.Delete(b.lastBillCacheKey(appToken, bill.AuthorID))
+		}
+	}
+	return nil
+}
+
+func (b *billUseCase) Latest(appToken, tableToken string, operator domain.User, remark string) (*domain.Bill, bool) {
+	if v, ok := b.cache.Load(b.lastBillCacheKey(appToken, operator.UID)); ok {
+		if vv, ok := v.(*domain.Bill); ok && (remark == "" || vv.Remark == remark) {
+			return vv, true
+		}
+	}
+
+	// 按记账人ID查找，记账人ID字段创建之前的旧账单按记账人名称兜底
+	var latest *domain.Bill
+	for _, author := range []db.SearchCmd{
+		{Key: domain.BillTableAuthorID, Operator: "=", Val: operator.UID},
+		{Key: domain.BillTableAuthor, Operator: "=", Val: operator.Name},
+	} {
+		ss := []db.SearchCmd{author}
+		if remark != "" {
+			ss = append(ss, db.SearchCmd{
+				Key:      domain.BillTableRemark,
+				Operator: "=",
+				Val:      remark,
+			})
+		}
+		for _, r := range b.billRepository.Search(appToken, tableToken, ss) {
+			if author.Key == domain.BillTableAuthor && r.AuthorID != "" {
+				continue
+			}
+			if latest == nil || r.Date > latest.Date {
+				latest = r
+			}
+		}
+	}
+	if latest == nil {
+		return nil, false
+	}
+	return latest, true
 }
 
 func (b *billUseCase) GetCategory(appToken, tableToken, remark string) []string {
@@ -71,9 +216,9 @@ func (b *billUseCase) GetCategory(appToken, tableToken, remark string) []string
 	return nil
 }
 
-func (b *billUseCase) CurMonthTotal(appToken, tableToken string, expenses common.Expenses, amount float64) float64 {
+func (b *billUseCase) CurMonthTotal(ctx context.Context, ledger *domain.Ledger, expenses common.Expenses, amount float64) float64 {
 	var total float64
-	records := b.billRepository.Search(appToken, tableToken, []db.SearchCmd{
+	records := b.billRepository.Search(ledger.AppToken, ledger.TableToken, []db.SearchCmd{
 		{
 			Key:      domain.BillTableMonth,
 			Operator: "=",
@@ -86,13 +231,77 @@ func (b *billUseCase) CurMonthTotal(appToken, tableToken string, expenses common
 		},
 	})
 
+	conv := b.converter(ledger)
 	for _, r := range records {
-		total += r.Amount
+		if amount, ok := conv.amount(ctx, r); ok {
+			total += amount
+		}
 	}
 
 	return total + amount
 }
 
+func (b *billUseCase) Convert(ctx context.Context, ledger *domain.Ledger, bill *domain.Bill) (float64, error) {
+	base := ledger.BaseCurrency()
+	if bill.CurrencyCode() == base {
+		return bill.Amount, nil
+	}
+	rate, err := b.rates.Rate(ctx, bill.CurrencyCode(), base, rateDate(bill))
+	if err != nil {
+		return 0, err
+	}
+	return math.Round(bill.Amount*rate*100) / 100, nil
+}
+
+// rateDate 换算使用的汇率日期，没有日期的账单使用当天汇率
+func rateDate(bill *domain.Bill) time.Time {
+	if bill.Date != 0 {
+		return time.Unix(0, bill.Date*1e6)
+	}
+	return time.Now()
+}
+
+// converter 单次统计内换算外币金额，同一币种同一天的汇率只查询一次，查询失败的币种不再重复查询
+type converter struct {
+	rates  domain.RateProvider
+	base   string
+	rate   map[string]float64
+	failed map[string]bool
+}
+
+func (b *billUseCase) converter(ledger *domain.Ledger) *converter {
+	return &converter{
+		rates:  b.rates,
+		base:   ledger.BaseCurrency(),
+		rate:   make(map[string]float64),
+		failed: make(map[string]bool),
+	}
+}
+
+// amount 统计时使用的本位币金额，没有汇率时返回 false，外币金额不能直接与本位币相加，由调用方排除
+func (c *converter) amount(ctx context.Context, bill *domain.Bill) (float64, bool) {
+	currency := bill.CurrencyCode()
+	if currency == c.base {
+		return bill.Amount, true
+	}
+	if c.failed[currency] {
+		return 0, false
+	}
+	date := rateDate(bill)
+	key := currency + ":" + date.Format("2006-01-02")
+	rate, ok := c.rate[key]
+	if !ok {
+		var err error
+		if rate, err = c.rates.Rate(ctx, currency, c.base, date); err != nil {
+			logrus.WithContext(ctx).WithError(err).Warnf("convert bill currency fail! id:%s, currency:%s", bill.ID, bill.Currency)
+			c.failed[currency] = true
+			return 0, false
+		}
+		c.rate[key] = rate
+	}
+	return math.Round(bill.Amount*rate*100) / 100, true
+}
+
 func (b *billUseCase) ListCategory(appToken, tableToken string) []string {
 	records := b.billRepository.Search(appToken, tableToken, []db.SearchCmd{})
 	// distinct
@@ -116,6 +325,225 @@ func (b *billUseCase) ListCategory(appToken, tableToken string) []string {
 	return nil
 }
 
+func (b *billUseCase) Query(ctx context.Context, ledger *domain.Ledger, filter BillFilter) *BillSummary {
+	filter = filter.withDefaults(time.Now())
+	records := b.billRepository.Search(ledger.AppToken, ledger.TableToken, filter.searchCmds())
+
+	summary := &BillSummary{
+		StartDate:  filter.StartDate,
+		EndDate:    filter.EndDate,
+		Categories: make([]CategorySummary, 0),
+	}
+	idx := make(map[string]int)
+	conv := b.converter(ledger)
+	for _, r := range records {
+		amount, ok := conv.amount(ctx, r)
+		if !ok {
+			summary.Unconverted++
+			continue
+		}
+		expenses := common.Expenses(r.Expenses)
+		if expenses == common.Income {
+			summary.Income += amount
+		} else {
+			expenses = common.Pay
+			summary.Pay += amount
+		}
+		summary.Count++
+
+		category := "未分类"
+		if len(r.Categories) > 0 && r.Categories[0] != "" {
+			category = r.Categories[0]
+		}
+		key := fmt.Sprintf("%s:%s", expenses, category)
+		i, ok := idx[key]
+		if !ok {
+			i = len(summary.Categories)
+			idx[key] = i
+			summary.Categories = append(summary.Categories, CategorySummary{Category: category, Expenses: expenses})
+		}
+		summary.Categories[i].Amount += amount
+		summary.Categories[i].Count++
+	}
+	sort.SliceStable(summary.Categories, func(i, j int) bool {
+		if summary.Categories[i].Expenses != summary.Categories[j].Expenses {
+			return summary.Categories[i].Expenses == common.Income
+		}
+		return summary.Categories[i].Amount > summary.Categories[j].Amount
+	})
+	return summary
+}
+
+func (b *billUseCase) QueryByMember(ctx context.Context, ledger *domain.Ledger, filter BillFilter) []*MemberSummary {
+	filter = filter.withDefaults(time.Now())
+	records := b.billRepository.Search(ledger.AppToken, ledger.TableToken, filter.searchCmds())
+
+	// 旧账单只有记账人名称，按名称归到同一记账人
+	ids := make(map[string]string)
+	for _, r := range records {
+		if r.AuthorID != "" && r.AuthorName != "" {
+			ids[r.AuthorName] = r.AuthorID
+		}
+	}
+	res := make([]*MemberSummary, 0)
+	idx := make(map[string]*MemberSummary)
+	categories := make(map[string]map[string]int)
+	conv := b.converter(ledger)
+	for _, r := range records {
+		key := r.AuthorID
+		if key == "" {
+			key = ids[r.AuthorName]
+		}
+		if key == "" {
+			key = "name:" + r.AuthorName
+		}
+		it, ok := idx[key]
+		if !ok {
+			it = &MemberSummary{AuthorID: r.AuthorID, AuthorName: r.AuthorName, Categories: make([]CategorySummary, 0)}
+			if it.AuthorID == "" {
+				it.AuthorID = ids[r.AuthorName]
+			}
+			idx[key] = it
+			categories[key] = make(map[string]int)
+			res = append(res, it)
+		}
+		if it.AuthorName == "" {
+			it.AuthorName = r.AuthorName
+		}
+		amount, ok := conv.amount(ctx, r)
+		if !ok {
+			it.Unconverted++
+			continue
+		}
+		it.Count++
+		if common.Expenses(r.Expenses) == common.Income {
+			it.Income += amount
+			continue
+		}
+		it.Pay += amount
+
+		category := "未分类"
+		if len(r.Categories) > 0 && r.Categories[0] != "" {
+			category = r.Categories[0]
+		}
+		i, ok := categories[key][category]
+		if !ok {
+			i = len(it.Categories)
+			categories[key][category] = i
+			it.Categories = append(it.Categories, CategorySummary{Category: category, Expenses: common.Pay})
+		}
+		it.Categories[i].Amount += amount
+		it.Categories[i].Count++
+	}
+	for _, it := range res {
+		sort.SliceStable(it.Categories, func(i, j int) bool {
+			return it.Categories[i].Amount > it.Categories[j].Amount
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Pay > res[j].Pay
+	})
+	return res
+}
+
+func (b *billUseCase) Settle(ctx context.Context, ledger *domain.Ledger, filter BillFilter) ([]domain.Balance, []domain.Transfer) {
+	filter = filter.withDefaults(time.Now())
+	records := b.billRepository.Search(ledger.AppToken, ledger.TableToken, filter.searchCmds())
+	bills := make([]*domain.Bill, 0, len(records))
+	conv := b.converter(ledger)
+	for _, r := range records {
+		if len(r.Splits) == 0 || r.CurrencyCode() == ledger.BaseCurrency() {
+			bills = append(bills, r)
+			continue
+		}
+		// 外币账单的分摊金额按本位币等比例换算，没有汇率时不参与结算
+		amount, ok := conv.amount(ctx, r)
+		if !ok {
+			continue
+		}
+		if splits, err := domain.RescaleSplits(r.Splits, amount); err == nil {
+			r.Splits = splits
+			bills = append(bills, r)
+		}
+	}
+	balances := domain.Balances(bills)
+	return balances, domain.Settle(balances)
+}
+
+func (b *billUseCase) List(ctx context.Context, ledger *domain.Ledger, filter BillFilter) []*domain.Bill {
+	records := b.billRepository.Search(ledger.AppToken, ledger.TableToken, filter.searchCmds())
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Date > records[j].Date
+	})
+	return records
+}
+
+func (b *billUseCase) Page(ctx context.Context, ledger *domain.Ledger, filter BillFilter, offset, limit int) ([]*domain.Bill, int, error) {
+	return b.billRepository.Page(ledger.AppToken, ledger.TableToken, filter.searchCmds(), offset, limit)
+}
+
+func (b *billUseCase) Scan(ctx context.Context, ledger *domain.Ledger, filter BillFilter, fn func(bill *domain.Bill) error) error {
+	return b.billRepository.Scan(ledger.AppToken, ledger.TableToken, filter.searchCmds(), fn)
+}
+
+func (b *billUseCase) Get(appToken, tableToken, id string) (*domain.Bill, bool) {
+	return b.billRepository.Get(appToken, tableToken, id)
+}
+
+// withDefaults 未指定时间范围时默认查询本月
+func (f BillFilter) withDefaults(now time.Time) BillFilter {
+	if f.StartDate.IsZero() && f.EndDate.IsZero() {
+		f.StartDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		f.EndDate = now
+	} else if f.EndDate.IsZero() {
+		f.EndDate = now
+	} else if f.StartDate.IsZero() {
+		f.StartDate = time.Date(f.EndDate.Year(), f.EndDate.Month(), 1, 0, 0, 0, 0, f.EndDate.Location())
+	}
+	if f.EndDate.Before(f.StartDate) {
+		f.StartDate, f.EndDate = f.EndDate, f.StartDate
+	}
+	return f
+}
+
+func (f BillFilter) searchCmds() []db.SearchCmd {
+	ss := make([]db.SearchCmd, 0)
+	if !f.StartDate.IsZero() {
+		ss = append(ss, db.SearchCmd{
+			Key:      domain.BillTableDate,
+			Operator: ">=",
+			Val:      domain.DateVal(f.StartDate),
+		})
+	}
+	if !f.EndDate.IsZero() {
+		// 结束日期包含当天
+		ss = append(ss, db.SearchCmd{
+			Key:      domain.BillTableDate,
+			Operator: "<",
+			Val:      domain.DateVal(f.EndDate.AddDate(0, 0, 1)),
+		})
+	}
+	if f.Expenses != "" {
+		ss = append(ss, db.SearchCmd{
+			Key:      domain.BillTableExpenses,
+			Operator: "=",
+			Val:      string(f.Expenses),
+		})
+	}
+	if f.Category != "" {
+		ss = append(ss, db.SearchCmd{
+			Key:      domain.BillTableCategory,
+			Operator: "=",
+			Val:      f.Category,
+		})
+	}
+	return ss
+}
+
 func (b *billUseCase) categoryCacheKey(appToken, remark string) string {
 	return fmt.Sprintf("bill:category:appToken:%s:remark:%s", appToken, remark)
 }
+
+func (b *billUseCase) lastBillCacheKey(appToken, UID string) string {
+	return fmt.Sprintf("bill:last:appToken:%s:uid:%s", appToken, UID)
+}
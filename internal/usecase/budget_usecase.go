@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"sort"
+)
+
+// BudgetStatus 本月预算的使用情况
+type BudgetStatus struct {
+	domain.Budget
+	// Spent 本月已支出，Check 时包含待记入的账单
+	Spent float64
+	// Alert 本次记账跨过的最高提醒阈值（百分比），未跨过时为 0
+	Alert int
+}
+
+func (s BudgetStatus) Remaining() float64 {
+	return s.Amount - s.Spent
+}
+
+type BudgetUseCase interface {
+	// Set 设置月度预算，category 为空时为总预算，amount 不大于 0 时取消预算
+	Set(ctx context.Context, ledger *domain.Ledger, category string, amount float64) (*BudgetStatus, error)
+	// Check 计算记入 bill 后相关预算的使用情况，bill 尚未保存，收入或未设置预算时返回空
+	Check(ctx context.Context, ledger *domain.Ledger, bill *domain.Bill) []BudgetStatus
+	// Status 本月各预算的使用情况，总预算在前，未设置预算时返回空
+	Status(ctx context.Context, ledger *domain.Ledger) []BudgetStatus
+}
+
+type budgetUseCase struct {
+	thresholds       []int
+	billUseCase      BillUseCase
+	ledgerRepository domain.LedgerRepository
+}
+
+func NewBudgetUseCase(cfg *config.Config, billUseCase BillUseCase, ledgerRepository domain.LedgerRepository) BudgetUseCase {
+	thresholds := append([]int(nil), cfg.BudgetAlertThresholds...)
+	sort.Ints(thresholds)
+	return &budgetUseCase{
+		thresholds:       thresholds,
+		billUseCase:      billUseCase,
+		ledgerRepository: ledgerRepository,
+	}
+}
+
+func (b *budgetUseCase) Set(ctx context.Context, ledger *domain.Ledger, category string, amount float64) (*BudgetStatus, error) {
+	budgets := make([]domain.Budget, 0, len(ledger.Budgets)+1)
+	for _, it := range ledger.Budgets {
+		if it.Category != category {
+			budgets = append(budgets, it)
+		}
+	}
+	status := &BudgetStatus{Budget: domain.Budget{Category: category, Amount: amount}}
+	if amount > 0 {
+		budgets = append(budgets, status.Budget)
+	}
+	// 保存成功后再修改缓存中的账本，避免保存失败时内存与表格不一致
+	updated := *ledger
+	updated.Budgets = budgets
+	if err := b.ledgerRepository.UpdateBudgets(&updated); err != nil {
+		return nil, err
+	}
+	ledger.Budgets = budgets
+	status.Spent = b.spent(b.monthSummary(ctx, ledger), category)
+	return status, nil
+}
+
+func (b *budgetUseCase) Check(ctx context.Context, ledger *domain.Ledger, bill *domain.Bill) []BudgetStatus {
+	if len(ledger.Budgets) == 0 || common.Expenses(bill.Expenses) == common.Income {
+		return nil
+	}
+	category := "未分类"
+	if len(bill.Categories) > 0 && bill.Categories[0] != "" {
+		category = bill.Categories[0]
+	}
+
+	amount, err := b.billUseCase.Convert(ctx, ledger, bill)
+	if err != nil {
+		amount = bill.Amount
+	}
+	var summary *BillSummary
+	res := make([]BudgetStatus, 0)
+	for _, it := range ledger.Budgets {
+		if it.Category != "" && it.Category != category {
+			continue
+		}
+		if summary == nil {
+			summary = b.monthSummary(ctx, ledger)
+		}
+		before := b.spent(summary, it.Category)
+		status := BudgetStatus{Budget: it, Spent: before + amount}
+		for _, t := range b.thresholds {
+			line := it.Amount * float64(t) / 100
+			if before < line && status.Spent >= line {
+				status.Alert = t
+			}
+		}
+		res = append(res, status)
+	}
+	// 总预算在前
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Category == "" && res[j].Category != ""
+	})
+	return res
+}
+
+func (b *budgetUseCase) Status(ctx context.Context, ledger *domain.Ledger) []BudgetStatus {
+	if len(ledger.Budgets) == 0 {
+		return nil
+	}
+	summary := b.monthSummary(ctx, ledger)
+	res := make([]BudgetStatus, 0, len(ledger.Budgets))
+	for _, it := range ledger.Budgets {
+		res = append(res, BudgetStatus{Budget: it, Spent: b.spent(summary, it.Category)})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Category == "" && res[j].Category != ""
+	})
+	return res
+}
+
+func (b *budgetUseCase) monthSummary(ctx context.Context, ledger *domain.Ledger) *BillSummary {
+	return b.billUseCase.Query(ctx, ledger, BillFilter{Expenses: common.Pay})
+}
+
+// spent 本月支出，category 为空时为全部支出
+func (b *budgetUseCase) spent(summary *BillSummary, category string) float64 {
+	if category == "" {
+		return summary.Pay
+	}
+	var spent float64
+	for _, c := range summary.Categories {
+		if c.Category == category && c.Expenses == common.Pay {
+			spent += c.Amount
+		}
+	}
+	return spent
+}
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/domain"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// downloadLinkTTL 下载链接有效期
+	downloadLinkTTL    = 24 * time.Hour
+	downloadDateLayout = "2006-01-02"
+)
+
+var (
+	ErrExportDisabled  = errors.New("SERVER_URL and API_SECRET are required for download link")
+	ErrInvalidDownload = errors.New("invalid or expired download link")
+)
+
+// DownloadReq 下载链接中携带的导出参数
+type DownloadReq struct {
+	UID string
+	// LedgerID 生成链接时的账本，下载时需要校验用户仍是账本成员
+	LedgerID string
+	Format   string
+	Filter   BillFilter
+}
+
+type ExportUseCase interface {
+	Formats() []string
+	ContentType(format string) (string, bool)
+	// Export 按日期顺序导出账本中符合条件的账单，未指定时间范围时导出全部
+	Export(ctx context.Context, w io.Writer, ledger *domain.Ledger, filter BillFilter, format string) error
+	// DownloadURL 生成限时有效的签名下载链接，链接固定为 ledger 的账单
+	DownloadURL(UID string, ledger *domain.Ledger, format string, filter BillFilter) (string, error)
+	// ParseDownload 校验下载链接的签名及有效期
+	ParseDownload(query url.Values) (*DownloadReq, error)
+}
+
+type exportUseCase struct {
+	serverURL    string
+	billUseCase  BillUseCase
+	authUseCase  AuthUseCase
+	billExporter domain.BillExporter
+}
+
+func NewExportUseCase(cfg *config.Config, billUseCase BillUseCase, authUseCase AuthUseCase, billExporter domain.BillExporter) ExportUseCase {
+	return &exportUseCase{
+		serverURL:    strings.TrimSuffix(cfg.ServerURL, "/"),
+		billUseCase:  billUseCase,
+		authUseCase:  authUseCase,
+		billExporter: billExporter,
+	}
+}
+
+func (e *exportUseCase) Formats() []string {
+	return e.billExporter.Formats()
+}
+
+func (e *exportUseCase) ContentType(format string) (string, bool) {
+	return e.billExporter.ContentType(format)
+}
+
+func (e *exportUseCase) Export(ctx context.Context, w io.Writer, ledger *domain.Ledger, filter BillFilter, format string) error {
+	if _, ok := e.ContentType(format); !ok {
+		return fmt.Errorf("unsupported export format %s", format)
+	}
+	return e.billExporter.Export(w, format, func(fn func(bill *domain.Bill) error) error {
+		return e.billUseCase.Scan(ctx, ledger, filter, fn)
+	})
+}
+
+func (e *exportUseCase) DownloadURL(UID string, ledger *domain.Ledger, format string, filter BillFilter) (string, error) {
+	if e.serverURL == "" {
+		return "", ErrExportDisabled
+	}
+	if _, ok := e.ContentType(format); !ok {
+		return "", fmt.Errorf("unsupported export format %s", format)
+	}
+	values := url.Values{}
+	values.Set("uid", UID)
+	values.Set("ledger", ledger.ID)
+	values.Set("format", format)
+	if !filter.StartDate.IsZero() {
+		values.Set("start_date", filter.StartDate.Format(downloadDateLayout))
+	}
+	if !filter.EndDate.IsZero() {
+		values.Set("end_date", filter.EndDate.Format(downloadDateLayout))
+	}
+	values.Set("expires", strconv.FormatInt(time.Now().Add(downloadLinkTTL).Unix(), 10))
+	sign, err := e.authUseCase.Sign(PurposeDownload, values.Encode())
+	if err == ErrAPIDisabled {
+		return "", ErrExportDisabled
+	}
+	if err != nil {
+		return "", err
+	}
+	values.Set("sign", sign)
+	return fmt.Sprintf("%s/download/bills?%s", e.serverURL, values.Encode()), nil
+}
+
+func (e *exportUseCase) ParseDownload(query url.Values) (*DownloadReq, error) {
+	values := url.Values{}
+	for k, v := range query {
+		if k != "sign" {
+			values[k] = v
+		}
+	}
+	if !e.authUseCase.Verify(PurposeDownload, values.Encode(), query.Get("sign")) {
+		return nil, ErrInvalidDownload
+	}
+	expires, err := strconv.ParseInt(values.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, ErrInvalidDownload
+	}
+	req := &DownloadReq{
+		UID:      values.Get("uid"),
+		LedgerID: values.Get("ledger"),
+		Format:   values.Get("format"),
+	}
+	if req.UID == "" || req.LedgerID == "" {
+		return nil, ErrInvalidDownload
+	}
+	if v := values.Get("start_date"); v != "" {
+		if req.Filter.StartDate, err = time.ParseInLocation(downloadDateLayout, v, time.Local); err != nil {
+			return nil, ErrInvalidDownload
+		}
+	}
+	if v := values.Get("end_date"); v != "" {
+		if req.Filter.EndDate, err = time.ParseInLocation(downloadDateLayout, v, time.Local); err != nil {
+			return nil, ErrInvalidDownload
+		}
+	}
+	return req, nil
+}
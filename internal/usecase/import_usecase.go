@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"github.com/wangyuheng/richman/internal/domain"
+	"io"
+	"time"
+)
+
+// ImportResult 导入结果，DryRun 时 Bills 为待导入的账单，未保存
+type ImportResult struct {
+	Source string
+	DryRun bool
+	Bills  []*domain.Bill
+	// Duplicated 交易单号已存在的记录数
+	Duplicated int
+	// Skipped 不计收支、交易关闭等忽略的记录数
+	Skipped int
+}
+
+type ImportUseCase interface {
+	// Import 导入支付宝、微信支付账单流水，按交易单号排重后批量保存
+	Import(ctx context.Context, ledger *domain.Ledger, operator domain.User, r io.Reader, dryRun bool) (*ImportResult, error)
+}
+
+type importUseCase struct {
+	billUseCase     BillUseCase
+	statementParser domain.StatementParser
+}
+
+func NewImportUseCase(billUseCase BillUseCase, statementParser domain.StatementParser) ImportUseCase {
+	return &importUseCase{
+		billUseCase:     billUseCase,
+		statementParser: statementParser,
+	}
+}
+
+func (i *importUseCase) Import(ctx context.Context, ledger *domain.Ledger, operator domain.User, r io.Reader, dryRun bool) (*ImportResult, error) {
+	st, err := i.statementParser.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	res := &ImportResult{
+		Source:  st.Source,
+		DryRun:  dryRun,
+		Bills:   make([]*domain.Bill, 0, len(st.Bills)),
+		Skipped: st.Skipped,
+	}
+	if len(st.Bills) == 0 {
+		return res, nil
+	}
+
+	// 退款与原交易可能使用相同的单号，按单号及收支排重
+	// 逐页查询流水日期范围内已有的账单，只保留交易单号
+	exists := make(map[string]bool)
+	err = i.billUseCase.Scan(ctx, ledger, i.dateRange(st.Bills), func(b *domain.Bill) error {
+		if b.TransactionID != "" {
+			exists[b.TransactionID+b.Expenses] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range st.Bills {
+		key := b.TransactionID + b.Expenses
+		if b.TransactionID != "" && exists[key] {
+			res.Duplicated++
+			continue
+		}
+		exists[key] = true
+		b.AuthorID = operator.UID
+		b.AuthorName = operator.Name
+		res.Bills = append(res.Bills, b)
+	}
+	if dryRun || len(res.Bills) == 0 {
+		return res, nil
+	}
+	if err = i.billUseCase.SaveBatch(ledger.AppToken, ledger.TableToken, res.Bills); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// dateRange 流水覆盖的日期范围，用于查询已导入的账单
+func (i *importUseCase) dateRange(bills []*domain.Bill) BillFilter {
+	start, end := bills[0].Date, bills[0].Date
+	for _, b := range bills {
+		if b.Date < start {
+			start = b.Date
+		}
+		if b.Date > end {
+			end = b.Date
+		}
+	}
+	return BillFilter{
+		StartDate: time.Unix(0, start*1e6),
+		EndDate:   time.Unix(0, end*1e6),
+	}
+}
@@ -2,37 +2,76 @@ package usecase
 
 import (
 	"context"
-	"fmt"
-	lark "github.com/larksuite/oapi-sdk-go/v3"
-	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
-	larkdrive "github.com/larksuite/oapi-sdk-go/v3/service/drive/v1"
+	"crypto/rand"
+	"errors"
 	"github.com/sirupsen/logrus"
-	"github.com/wangyuheng/richman/config"
 	"github.com/wangyuheng/richman/internal/domain"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// inviteTTL 邀请码有效期
+const inviteTTL = 24 * time.Hour
+
+// inviteAlphabet 邀请码字符，去掉了容易混淆的 0、O、1、I
+const inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var (
+	ErrOwnerRequired  = errors.New("only ledger owner can manage the ledger")
+	ErrInviteInvalid  = errors.New("invalid or expired invite code")
+	ErrLedgerNotFound = errors.New("ledger not found")
+)
+
+// UserLedger 用户的账本及其在账本中的成员信息
+type UserLedger struct {
+	Ledger *domain.Ledger
+	Member *domain.LedgerMember
+	// Active 是否为当前账本
+	Active bool
+}
+
 type LedgerUseCase interface {
 	Allocated(creator domain.User) (*domain.Ledger, error)
 	PreparedAllocated() []*domain.Ledger
 	Generate() (*domain.Ledger, error)
-	QueryByUID(UID string) (*domain.Ledger, bool)
+	// Active 用户的当前账本，即最近切换到、创建或加入的账本，没有成员记录时为用户创建的账本
+	Active(UID string) (*domain.Ledger, bool)
+	// Get 用户有权查看的账本，账本不存在或用户不是账本成员时返回 false
+	Get(UID, ledgerID string) (*domain.Ledger, bool)
+	// List 用户创建及加入的账本，按加入时间排序
+	List(UID string) []*UserLedger
+	// Create 创建账本并切换为当前账本
+	Create(ctx context.Context, operator domain.User, name string) (*domain.Ledger, error)
+	// Switch 切换当前账本，target 为 List 中的序号(从 1 开始)或账本名称
+	Switch(ctx context.Context, UID, target string) (*domain.Ledger, error)
+	// Rename 账本改名，只有账本创建者可以修改
+	Rename(ctx context.Context, ledger *domain.Ledger, operator domain.User, name string) error
+	// SetCurrency 设置账本本位币，只有账本创建者可以修改
+	SetCurrency(ctx context.Context, ledger *domain.Ledger, operator domain.User, currency string) error
+	// Member 用户在账本中的成员信息，不是账本成员时返回 false
+	Member(ledger *domain.Ledger, UID string) (*domain.LedgerMember, bool)
+	Members(ledger *domain.Ledger) []*domain.LedgerMember
+	// Invite 生成邀请码，只有账本创建者可以邀请，role 为 editor 或 viewer
+	Invite(ctx context.Context, ledger *domain.Ledger, operator domain.User, role string) (*domain.LedgerInvite, error)
+	// Join 凭邀请码加入账本，并切换为当前账本
+	Join(ctx context.Context, operator domain.User, code string) (*domain.Ledger, *domain.LedgerMember, error)
 }
 
 type ledgerUseCase struct {
-	ledgerRepository     domain.LedgerRepository
-	cli                  *lark.Client
-	templateAppToken     string
-	targetFolderAppToken string
-	unAllocated          []*domain.Ledger
+	ledgerRepository       domain.LedgerRepository
+	ledgerMemberRepository domain.LedgerMemberRepository
+	ledgerProvisioner      domain.LedgerProvisioner
+	unAllocated            []*domain.Ledger
 }
 
-func NewLedgerUseCase(cfg *config.Config, ledgerRepository domain.LedgerRepository, cli *lark.Client) LedgerUseCase {
+func NewLedgerUseCase(ledgerRepository domain.LedgerRepository, ledgerMemberRepository domain.LedgerMemberRepository, ledgerProvisioner domain.LedgerProvisioner) LedgerUseCase {
 	return &ledgerUseCase{
-		ledgerRepository:     ledgerRepository,
-		cli:                  cli,
-		templateAppToken:     cfg.LarkDBConfig.TemplateAppToken,
-		targetFolderAppToken: cfg.LarkDBConfig.TargetFolderAppToken,
+		ledgerRepository:       ledgerRepository,
+		ledgerMemberRepository: ledgerMemberRepository,
+		ledgerProvisioner:      ledgerProvisioner,
 	}
 }
 
@@ -59,125 +98,246 @@ func (l *ledgerUseCase) Allocated(creator domain.User) (*domain.Ledger, error) {
 	if len(l.unAllocated) > 0 {
 		ledger = l.unAllocated[len(l.unAllocated)-1]
 		l.unAllocated = l.unAllocated[:len(l.unAllocated)-1]
-		if err := l.ledgerRepository.UpdateUser(ledger.ID, creator); err != nil {
-			return nil, err
-		}
-		ledger.CreatorID = creator.UID
-		ledger.CreatorName = creator.Name
-		return ledger, nil
-	}
-	ls := l.ledgerRepository.QueryUnallocated()
-	if len(ls) == 0 {
-		ledger, err = l.Generate()
-		if err != nil {
-			return nil, err
-		}
-	} else {
+	} else if ls := l.ledgerRepository.QueryUnallocated(); len(ls) > 0 {
 		ledger = ls[0]
+	} else if ledger, err = l.Generate(); err != nil {
+		return nil, err
 	}
 	if err = l.ledgerRepository.UpdateUser(ledger.ID, creator); err != nil {
 		return nil, err
 	}
+	ledger.CreatorID = creator.UID
+	ledger.CreatorName = creator.Name
+	l.saveMember(ledger, creator, domain.LedgerOwner)
 	return ledger, nil
 }
 
 func (l *ledgerUseCase) Generate() (*domain.Ledger, error) {
-	ctx := context.Background()
-	// 根据模板复制到指定文件夹
-	copyFile, err := l.copyFromTemplate(ctx)
+	ledger, err := l.ledgerProvisioner.Provision(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	// copying 时调用其他接口可能导致失败
-	time.Sleep(2 * time.Second)
-	// 允许外部访问
-	err = l.setPermissionPublic(ctx, *copyFile.Token)
+	// 保存账本
+	_ = l.ledgerRepository.Save(ledger)
+	return ledger, nil
+}
+
+func (l *ledgerUseCase) Active(UID string) (*domain.Ledger, bool) {
+	for _, it := range l.ledgerMemberRepository.QueryByUID(UID) {
+		if ledger, ok := l.ledgerRepository.QueryByID(it.LedgerID); ok {
+			return ledger, true
+		}
+	}
+	ledger, ok := l.ledgerRepository.QueryByCreator(UID)
+	if ok {
+		// 成员表配置之前创建的账本，补充创建者的成员记录
+		l.saveMember(ledger, domain.User{UID: UID, Name: ledger.CreatorName}, domain.LedgerOwner)
+	}
+	return ledger, ok
+}
+
+func (l *ledgerUseCase) Get(UID, ledgerID string) (*domain.Ledger, bool) {
+	ledger, ok := l.ledgerRepository.QueryByID(ledgerID)
+	if !ok {
+		return nil, false
+	}
+	if _, ok = l.Member(ledger, UID); !ok {
+		return nil, false
+	}
+	return ledger, true
+}
+
+func (l *ledgerUseCase) List(UID string) []*UserLedger {
+	if _, ok := l.Active(UID); !ok {
+		return nil
+	}
+	res := make([]*UserLedger, 0)
+	for i, it := range l.ledgerMemberRepository.QueryByUID(UID) {
+		if ledger, ok := l.ledgerRepository.QueryByID(it.LedgerID); ok {
+			res = append(res, &UserLedger{Ledger: ledger, Member: it, Active: i == 0})
+		}
+	}
+	if len(res) == 0 {
+		// 未配置成员表时只有用户创建的账本
+		if ledger, ok := l.ledgerRepository.QueryByCreator(UID); ok {
+			member, _ := l.Member(ledger, UID)
+			res = append(res, &UserLedger{Ledger: ledger, Member: member, Active: true})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Member.JoinedAt < res[j].Member.JoinedAt
+	})
+	return res
+}
+
+func (l *ledgerUseCase) Create(ctx context.Context, operator domain.User, name string) (*domain.Ledger, error) {
+	ledger, err := l.Allocated(operator)
 	if err != nil {
 		return nil, err
 	}
-	// 获取账单表ID
-	tableID, err := l.getBillTableID(ctx, *copyFile.Token)
-	if err != nil {
+	if err = l.ledgerMemberRepository.Activate(ledger.ID, operator.UID, time.Now().UnixNano()/1e6); err != nil {
+		// 不支持多账本时归还账本
+		_ = l.ledgerRepository.UpdateUser(ledger.ID, domain.User{})
 		return nil, err
 	}
-	// 保存账本
-	ledger := &domain.Ledger{
-		AppToken:   *copyFile.Token,
-		TableToken: *tableID,
-		Name:       *copyFile.Name,
-		URL:        *copyFile.Url,
-		//CreatorID:   creator.UID,
-		//CreatorName: creator.Name,
+	if err = l.ledgerRepository.UpdateName(ledger.ID, name); err != nil {
+		return nil, err
 	}
-	_ = l.ledgerRepository.Save(ledger)
+	ledger.Name = name
+	logrus.WithContext(ctx).Infof("create ledger! ledger:%s uid:%s", ledger.ID, operator.UID)
 	return ledger, nil
 }
 
-func (l *ledgerUseCase) QueryByUID(UID string) (*domain.Ledger, bool) {
-	return l.ledgerRepository.QueryByUID(UID)
+func (l *ledgerUseCase) Switch(ctx context.Context, UID, target string) (*domain.Ledger, error) {
+	ledgers := l.List(UID)
+	var ledger *domain.Ledger
+	if i, err := strconv.Atoi(target); err == nil && i >= 1 && i <= len(ledgers) {
+		ledger = ledgers[i-1].Ledger
+	}
+	for _, it := range ledgers {
+		if ledger == nil && it.Ledger.Name == target {
+			ledger = it.Ledger
+		}
+	}
+	if ledger == nil {
+		return nil, ErrLedgerNotFound
+	}
+	if err := l.ledgerMemberRepository.Activate(ledger.ID, UID, time.Now().UnixNano()/1e6); err != nil {
+		return nil, err
+	}
+	return ledger, nil
+}
 
+func (l *ledgerUseCase) Rename(ctx context.Context, ledger *domain.Ledger, operator domain.User, name string) error {
+	if m, ok := l.Member(ledger, operator.UID); !ok || m.Role != domain.LedgerOwner {
+		return ErrOwnerRequired
+	}
+	if err := l.ledgerRepository.UpdateName(ledger.ID, name); err != nil {
+		return err
+	}
+	ledger.Name = name
+	return nil
 }
 
-func (l *ledgerUseCase) copyFromTemplate(ctx context.Context) (*larkdrive.File, error) {
-	resp, err := l.cli.Drive.File.Copy(ctx, larkdrive.NewCopyFileReqBuilder().
-		FileToken(l.templateAppToken).
-		Body(larkdrive.NewCopyFileReqBodyBuilder().
-			Type("bitable").
-			FolderToken(l.targetFolderAppToken).
-			Name("飞书记账").
-			Build()).
-		Build())
-	if err != nil {
-		logrus.WithContext(ctx).WithError(err).Errorf("copy file error! resp:%+v", resp)
-		return nil, err
+func (l *ledgerUseCase) SetCurrency(ctx context.Context, ledger *domain.Ledger, operator domain.User, currency string) error {
+	if m, ok := l.Member(ledger, operator.UID); !ok || m.Role != domain.LedgerOwner {
+		return ErrOwnerRequired
 	}
-	if !resp.Success() {
-		logrus.WithContext(ctx).WithError(err).Errorf("copy file fail! resp:%+v", resp)
-		return nil, fmt.Errorf(resp.Msg)
-	}
-	return resp.Data.File, nil
-}
-
-func (l *ledgerUseCase) setPermissionPublic(ctx context.Context, appToken string) error {
-	permissionResp, err := l.cli.Drive.PermissionPublic.Patch(ctx, larkdrive.NewPatchPermissionPublicReqBuilder().
-		Token(appToken).
-		Type("bitable").
-		PermissionPublicRequest(larkdrive.NewPermissionPublicRequestBuilder().
-			ExternalAccess(true).
-			SecurityEntity("anyone_can_view").
-			CommentEntity("anyone_can_view").
-			ShareEntity("anyone").
-			LinkShareEntity("anyone_editable").
-			InviteExternal(true).
-			Build()).
-		Build())
-	if err != nil || !permissionResp.Success() {
-		logrus.WithContext(ctx).WithError(err).Errorf("patch file permission fail! resp:%+v", permissionResp)
+	updated := *ledger
+	updated.Currency = currency
+	if err := l.ledgerRepository.UpdateCurrency(&updated); err != nil {
 		return err
 	}
+	ledger.Currency = currency
 	return nil
 }
 
-func (l *ledgerUseCase) getBillTableID(ctx context.Context, appToken string) (*string, error) {
-	logger := logrus.WithContext(ctx)
-	req := larkbitable.NewListAppTableReqBuilder().
-		AppToken(appToken).
-		PageSize(20).
-		Build()
+func (l *ledgerUseCase) Member(ledger *domain.Ledger, UID string) (*domain.LedgerMember, bool) {
+	for _, it := range l.Members(ledger) {
+		if it.UID == UID {
+			return it, true
+		}
+	}
+	return nil, false
+}
 
-	table, err := l.cli.Bitable.AppTable.List(ctx, req)
+func (l *ledgerUseCase) Members(ledger *domain.Ledger) []*domain.LedgerMember {
+	members := l.ledgerMemberRepository.QueryByLedger(ledger.ID)
+	for _, it := range members {
+		if it.UID == ledger.CreatorID {
+			return members
+		}
+	}
+	// 未配置成员表时账本只有创建者
+	if ledger.CreatorID != "" {
+		members = append([]*domain.LedgerMember{{
+			LedgerID: ledger.ID,
+			UID:      ledger.CreatorID,
+			Name:     ledger.CreatorName,
+			Role:     domain.LedgerOwner,
+		}}, members...)
+	}
+	return members
+}
+
+func (l *ledgerUseCase) Invite(ctx context.Context, ledger *domain.Ledger, operator domain.User, role string) (*domain.LedgerInvite, error) {
+	if m, ok := l.Member(ledger, operator.UID); !ok || m.Role != domain.LedgerOwner {
+		return nil, ErrOwnerRequired
+	}
+	if role != domain.LedgerViewer {
+		role = domain.LedgerEditor
+	}
+	code, err := inviteCode()
 	if err != nil {
-		logger.WithError(err).Errorf("list app table error! req: %+v, resp:%+v", req, table)
 		return nil, err
 	}
-	if !table.Success() {
-		logger.WithError(err).Errorf("list app table fail! req: %+v, resp:%+v", req, table)
-		return nil, fmt.Errorf(table.Msg)
+	it := &domain.LedgerInvite{
+		Code:      code,
+		LedgerID:  ledger.ID,
+		Role:      role,
+		InviterID: operator.UID,
+		ExpireAt:  time.Now().Add(inviteTTL).UnixNano() / 1e6,
+	}
+	if err = l.ledgerMemberRepository.SaveInvite(it); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+func (l *ledgerUseCase) Join(ctx context.Context, operator domain.User, code string) (*domain.Ledger, *domain.LedgerMember, error) {
+	invite, ok := l.ledgerMemberRepository.QueryInvite(strings.ToUpper(strings.TrimSpace(code)))
+	if !ok || invite.ExpireAt < time.Now().UnixNano()/1e6 {
+		return nil, nil, ErrInviteInvalid
+	}
+	ledger, ok := l.ledgerRepository.QueryByID(invite.LedgerID)
+	if !ok {
+		return nil, nil, ErrInviteInvalid
 	}
-	for _, t := range table.Data.Items {
-		if *t.Name == "个人账单记录" {
-			return t.TableId, nil
+	role := invite.Role
+	// 已经是账本成员时不降低权限
+	if m, ok := l.Member(ledger, operator.UID); ok && (m.Role == domain.LedgerOwner || role == domain.LedgerViewer) {
+		role = m.Role
+	}
+	now := time.Now().UnixNano() / 1e6
+	member := &domain.LedgerMember{
+		LedgerID: ledger.ID,
+		UID:      operator.UID,
+		Name:     operator.Name,
+		Role:     role,
+		JoinedAt: now,
+		ActiveAt: now,
+	}
+	if err := l.ledgerMemberRepository.Save(member); err != nil {
+		return nil, nil, err
+	}
+	logrus.WithContext(ctx).Infof("join ledger! ledger:%s member:%+v", ledger.ID, member)
+	return ledger, member, nil
+}
+
+// saveMember 保存成员记录，未配置成员表时忽略
+func (l *ledgerUseCase) saveMember(ledger *domain.Ledger, user domain.User, role string) {
+	now := time.Now().UnixNano() / 1e6
+	err := l.ledgerMemberRepository.Save(&domain.LedgerMember{
+		LedgerID: ledger.ID,
+		UID:      user.UID,
+		Name:     user.Name,
+		Role:     role,
+		JoinedAt: now,
+		ActiveAt: now,
+	})
+	if err != nil && err != domain.ErrMemberDisabled {
+		logrus.WithError(err).Warnf("save ledger member fail! ledger:%s uid:%s", ledger.ID, user.UID)
+	}
+}
+
+func inviteCode() (string, error) {
+	code := make([]byte, 6)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteAlphabet))))
+		if err != nil {
+			return "", err
 		}
+		code[i] = inviteAlphabet[n.Int64()]
 	}
-	return nil, nil
+	return string(code), nil
 }
@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/geeklubcn/feishu-bitable-db/db"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/internal/domain"
+	"time"
+)
+
+type RecurringUseCase interface {
+	// Add 添加周期账单规则，当月记账日已过时从下月开始，记账日为今天时立即记账
+	Add(ctx context.Context, ledger *domain.Ledger, rule *domain.RecurringBill) error
+	List(ctx context.Context, ledger *domain.Ledger) []*domain.RecurringBill
+	// Remove 删除指定名称的周期账单规则，已生成的账单保留
+	Remove(ctx context.Context, ledger *domain.Ledger, remark string) (*domain.RecurringBill, bool, error)
+	// Materialize 生成 now 之前到期的账单，返回新记录的笔数。
+	// 可重复执行，账单以幂等键作为交易单号，重启或多实例同时执行时同一规则同一周期只记一次
+	Materialize(ctx context.Context, now time.Time) int
+}
+
+type recurringUseCase struct {
+	recurringBillRepository domain.RecurringBillRepository
+	billRepository          domain.BillRepository
+}
+
+func NewRecurringUseCase(recurringBillRepository domain.RecurringBillRepository, billRepository domain.BillRepository) RecurringUseCase {
+	return &recurringUseCase{
+		recurringBillRepository: recurringBillRepository,
+		billRepository:          billRepository,
+	}
+}
+
+func (r *recurringUseCase) Add(ctx context.Context, ledger *domain.Ledger, rule *domain.RecurringBill) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	if rule.DueDate(period).Before(today) {
+		period = period.AddDate(0, 1, 0)
+	}
+	rule.AppToken = ledger.AppToken
+	rule.TableToken = ledger.TableToken
+	rule.NextPeriod = period.Format(domain.RecurringPeriodLayout)
+	if err := r.recurringBillRepository.Save(rule); err != nil {
+		return err
+	}
+	r.materialize(ctx, rule, now)
+	return nil
+}
+
+func (r *recurringUseCase) List(ctx context.Context, ledger *domain.Ledger) []*domain.RecurringBill {
+	return r.recurringBillRepository.QueryByLedger(ledger.AppToken, ledger.TableToken)
+}
+
+func (r *recurringUseCase) Remove(ctx context.Context, ledger *domain.Ledger, remark string) (*domain.RecurringBill, bool, error) {
+	for _, it := range r.List(ctx, ledger) {
+		if it.Remark == remark {
+			return it, true, r.recurringBillRepository.Delete(it.ID)
+		}
+	}
+	return nil, false, nil
+}
+
+func (r *recurringUseCase) Materialize(ctx context.Context, now time.Time) int {
+	var count int
+	for _, rule := range r.recurringBillRepository.QueryAll() {
+		count += r.materialize(ctx, rule, now)
+	}
+	return count
+}
+
+// materialize 依次补记规则到期的周期，先认领周期再记账，记账失败时归还周期，等待下次执行
+func (r *recurringUseCase) materialize(ctx context.Context, rule *domain.RecurringBill, now time.Time) int {
+	var count int
+	for {
+		due, err := rule.NextDueDate()
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Warnf("illegal recurring bill period! rule:%+v", rule)
+			return count
+		}
+		if due.After(now) {
+			return count
+		}
+		period := rule.NextPeriod
+		next := time.Date(due.Year(), due.Month()+1, 1, 0, 0, 0, 0, time.Local).Format(domain.RecurringPeriodLayout)
+		claimed, err := r.recurringBillRepository.ClaimNextPeriod(rule, next)
+		if err != nil || !claimed {
+			// 其他实例已认领该周期
+			return count
+		}
+		posted, err := r.post(ctx, rule, period, due)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("post recurring bill fail! rule:%+v", rule)
+			if _, err = r.recurringBillRepository.ClaimNextPeriod(rule, period); err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("release recurring bill period fail! rule:%+v", rule)
+			}
+			return count
+		}
+		if posted {
+			count++
+		}
+	}
+}
+
+// post 记入规则指定周期的账单，已存在相同幂等键的账单时跳过
+func (r *recurringUseCase) post(ctx context.Context, rule *domain.RecurringBill, period string, due time.Time) (bool, error) {
+	key := rule.IdempotencyKey(period)
+	exists, err := r.exists(rule, key)
+	if err != nil || exists {
+		return false, err
+	}
+	bill := &domain.Bill{
+		Remark:        rule.Remark,
+		Categories:    []string{rule.Category},
+		Amount:        rule.Amount,
+		Date:          due.UnixNano() / 1e6,
+		Expenses:      rule.Expenses,
+		AuthorID:      rule.AuthorID,
+		AuthorName:    rule.AuthorName,
+		TransactionID: key,
+	}
+	err = r.billRepository.Save(rule.AppToken, rule.TableToken, bill)
+	if errors.Is(err, domain.ErrDuplicateBill) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// exists 查询失败时返回错误，避免误判为不存在而重复记账
+func (r *recurringUseCase) exists(rule *domain.RecurringBill, key string) (bool, error) {
+	var found bool
+	err := r.billRepository.Scan(rule.AppToken, rule.TableToken, []db.SearchCmd{
+		{Key: domain.BillTableTransactionID, Operator: "=", Val: key},
+	}, func(bill *domain.Bill) error {
+		found = true
+		return nil
+	})
+	return found, err
+}
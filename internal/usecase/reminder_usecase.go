@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"sync"
+	"time"
+)
+
+type ReminderUseCase interface {
+	// Touch 记录用户使用的消息渠道，用户首次使用时开启提醒，同一用户只保存一次
+	Touch(ctx context.Context, UID, channel string)
+	// SetEnabled 开启或关闭记账提醒
+	SetEnabled(ctx context.Context, UID, channel string, enabled bool) error
+	// Remind 提醒超过 REMINDER_INACTIVE_DAYS 天没有记账的用户，返回提醒的人数。
+	// REMINDER_LOOKBACK 内没有账单（包括从未记账）的用户，每个 REMINDER_LOOKBACK 内只提醒一次
+	Remind(ctx context.Context, now time.Time) int
+}
+
+type reminderUseCase struct {
+	inactiveDays       int
+	interval           time.Duration
+	lookback           time.Duration
+	quietStart         int
+	quietEnd           int
+	reminderRepository domain.ReminderRepository
+	billUseCase        BillUseCase
+	ledgerUseCase      LedgerUseCase
+	messengers         domain.Messengers
+	touched            sync.Map
+}
+
+// defaultReminderLookback REMINDER_LOOKBACK 未配置或不合法时查询最近 30 天的账单
+const defaultReminderLookback = 30 * 24 * time.Hour
+
+func NewReminderUseCase(cfg *config.Config, reminderRepository domain.ReminderRepository, billUseCase BillUseCase, ledgerUseCase LedgerUseCase, messengers domain.Messengers) ReminderUseCase {
+	lookback := cfg.ReminderLookback
+	if lookback <= 0 {
+		lookback = defaultReminderLookback
+	}
+	return &reminderUseCase{
+		inactiveDays:       cfg.ReminderInactiveDays,
+		interval:           cfg.ReminderInterval,
+		lookback:           lookback,
+		quietStart:         cfg.ReminderQuietStart,
+		quietEnd:           cfg.ReminderQuietEnd,
+		reminderRepository: reminderRepository,
+		billUseCase:        billUseCase,
+		ledgerUseCase:      ledgerUseCase,
+		messengers:         messengers,
+	}
+}
+
+func (r *reminderUseCase) Touch(ctx context.Context, UID, channel string) {
+	if _, ok := r.touched.Load(UID); ok {
+		return
+	}
+	err := r.reminderRepository.Touch(UID, channel)
+	if err != nil && err != domain.ErrReminderDisabled {
+		logrus.WithContext(ctx).WithError(err).Warnf("touch reminder fail! uid:%s", UID)
+		return
+	}
+	r.touched.Store(UID, true)
+}
+
+func (r *reminderUseCase) SetEnabled(ctx context.Context, UID, channel string, enabled bool) error {
+	if err := r.reminderRepository.SetDisabled(UID, channel, !enabled); err != nil {
+		return err
+	}
+	r.touched.Store(UID, true)
+	return nil
+}
+
+// lastBillDate 账本最近一笔账单的日期，lookback 内没有账单时返回 false
+func (r *reminderUseCase) lastBillDate(ctx context.Context, ledger *domain.Ledger, now time.Time) (time.Time, bool) {
+	bills := r.billUseCase.List(ctx, ledger, BillFilter{StartDate: now.Add(-r.lookback)})
+	if len(bills) == 0 {
+		return time.Time{}, false
+	}
+	// List 按日期倒序
+	return time.Unix(0, bills[0].Date*1e6), true
+}
+
+func (r *reminderUseCase) Remind(ctx context.Context, now time.Time) int {
+	if r.inactiveDays <= 0 || r.quiet(now) {
+		return 0
+	}
+	var count int
+	for _, it := range r.reminderRepository.QueryEnabled() {
+		if now.Sub(time.Unix(0, it.LastRemindAt*1e6)) < r.interval {
+			continue
+		}
+		messenger, ok := r.messengers[it.Channel]
+		if !ok {
+			logrus.WithContext(ctx).Warnf("unknown reminder channel! reminder:%+v", it)
+			continue
+		}
+		ledger, ok := r.ledgerUseCase.Active(it.UID)
+		if !ok {
+			continue
+		}
+		msg := common.DormantReminder
+		if last, ok := r.lastBillDate(ctx, ledger, now); ok {
+			days := int(now.Sub(last).Hours() / 24)
+			if days < r.inactiveDays {
+				continue
+			}
+			msg = common.InactiveReminder(days)
+		} else if now.Sub(time.Unix(0, it.LastRemindAt*1e6)) < r.lookback {
+			// 长期未记账的用户已提醒过，不再重复打扰
+			continue
+		}
+		// 先占用提醒时间再推送，推送失败时等待下一个间隔，避免重复打扰用户
+		claimed, err := r.reminderRepository.ClaimRemind(it, now.UnixNano()/1e6)
+		if err != nil || !claimed {
+			continue
+		}
+		if err = messenger.SendText(ctx, it.UID, msg); err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("send reminder fail! reminder:%+v", it)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// quiet 是否处于免打扰时段，支持跨零点，如 22 点至次日 8 点
+func (r *reminderUseCase) quiet(now time.Time) bool {
+	h := now.Hour()
+	switch {
+	case r.quietStart < r.quietEnd:
+		return h >= r.quietStart && h < r.quietEnd
+	case r.quietStart > r.quietEnd:
+		return h >= r.quietStart || h < r.quietEnd
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/wangyuheng/richman/config"
+	"github.com/wangyuheng/richman/internal/common"
+	"github.com/wangyuheng/richman/internal/domain"
+	"time"
+)
+
+// reportTopCategories 报告中展示的支出分类数
+const reportTopCategories = 3
+
+var reportNames = map[string]string{
+	domain.ReportDaily:   "日报",
+	domain.ReportWeekly:  "周报",
+	domain.ReportMonthly: "月报",
+}
+
+// ReportName 报告频率的中文名称，频率不支持时返回 false
+func ReportName(frequency string) (string, bool) {
+	name, ok := reportNames[frequency]
+	return name, ok
+}
+
+type ReportUseCase interface {
+	// Subscribe 订阅报告，hour 小于 0 时使用默认推送时间，重复订阅时更新推送渠道及时间
+	Subscribe(ctx context.Context, operator domain.User, channel, frequency string, hour int) (*domain.Subscription, error)
+	Unsubscribe(ctx context.Context, UID, frequency string) (bool, error)
+	List(ctx context.Context, UID string) []*domain.Subscription
+	// Build 生成 now 所在的日、周（周一至周日）、月的收支报告
+	Build(ctx context.Context, ledger *domain.Ledger, frequency string, now time.Time) string
+	// Push 在周期的最后一天推送到期的报告，返回推送成功的数量
+	Push(ctx context.Context, now time.Time) int
+}
+
+type reportUseCase struct {
+	defaultHour            int
+	subscriptionRepository domain.SubscriptionRepository
+	billUseCase            BillUseCase
+	budgetUseCase          BudgetUseCase
+	ledgerUseCase          LedgerUseCase
+	messengers             domain.Messengers
+}
+
+func NewReportUseCase(cfg *config.Config, subscriptionRepository domain.SubscriptionRepository, billUseCase BillUseCase, budgetUseCase BudgetUseCase, ledgerUseCase LedgerUseCase, messengers domain.Messengers) ReportUseCase {
+	return &reportUseCase{
+		defaultHour:            cfg.ReportDefaultHour,
+		subscriptionRepository: subscriptionRepository,
+		billUseCase:            billUseCase,
+		budgetUseCase:          budgetUseCase,
+		ledgerUseCase:          ledgerUseCase,
+		messengers:             messengers,
+	}
+}
+
+func (r *reportUseCase) Subscribe(ctx context.Context, operator domain.User, channel, frequency string, hour int) (*domain.Subscription, error) {
+	if hour < 0 {
+		hour = r.defaultHour
+	}
+	it := &domain.Subscription{
+		UID:       operator.UID,
+		Channel:   channel,
+		Frequency: frequency,
+		Hour:      hour,
+	}
+	if err := r.subscriptionRepository.Save(it); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+func (r *reportUseCase) Unsubscribe(ctx context.Context, UID, frequency string) (bool, error) {
+	return r.subscriptionRepository.Delete(UID, frequency)
+}
+
+func (r *reportUseCase) List(ctx context.Context, UID string) []*domain.Subscription {
+	return r.subscriptionRepository.QueryByUID(UID)
+}
+
+func (r *reportUseCase) Build(ctx context.Context, ledger *domain.Ledger, frequency string, now time.Time) string {
+	start, end := reportPeriod(frequency, now)
+	summary := r.billUseCase.Query(ctx, ledger, BillFilter{StartDate: start, EndDate: end})
+
+	top := make([]string, 0, reportTopCategories)
+	for _, it := range summary.Categories {
+		if it.Expenses != common.Pay || len(top) == reportTopCategories {
+			continue
+		}
+		top = append(top, common.CategoryDetail(it.Category, it.Expenses, it.Amount, it.Count))
+	}
+	budgets := make([]string, 0)
+	for _, it := range r.budgetUseCase.Status(ctx, ledger) {
+		budgets = append(budgets, common.BudgetRemaining(it.Category, it.Remaining()))
+	}
+	name, _ := ReportName(frequency)
+	return common.Report(name, start.Format(queryDateLayout), end.Format(queryDateLayout), summary.Income, summary.Pay, top, budgets, summary.Unconverted)
+}
+
+func (r *reportUseCase) Push(ctx context.Context, now time.Time) int {
+	var count int
+	for _, it := range r.subscriptionRepository.QueryAll() {
+		if _, ok := ReportName(it.Frequency); !ok {
+			continue
+		}
+		start, end := reportPeriod(it.Frequency, now)
+		if now.Before(end.Add(time.Duration(it.Hour) * time.Hour)) {
+			continue
+		}
+		period := start.Format("2006-01-02")
+		if it.LastPeriod == period {
+			continue
+		}
+		messenger, ok := r.messengers[it.Channel]
+		if !ok {
+			logrus.WithContext(ctx).Warnf("unknown subscription channel! subscription:%+v", it)
+			continue
+		}
+		ledger, ok := r.ledgerUseCase.Active(it.UID)
+		if !ok {
+			continue
+		}
+		// 先占用周期再推送，推送失败时不重试，避免重复打扰用户
+		claimed, err := r.subscriptionRepository.ClaimPeriod(it, period)
+		if err != nil || !claimed {
+			continue
+		}
+		if err = messenger.SendText(ctx, it.UID, r.Build(ctx, ledger, it.Frequency, now)); err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("push report fail! subscription:%+v", it)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// reportPeriod now 所在周期的开始日期及最后一天
+func reportPeriod(frequency string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch frequency {
+	case domain.ReportWeekly:
+		start := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 6)
+	case domain.ReportMonthly:
+		start := today.AddDate(0, 0, 1-today.Day())
+		return start, start.AddDate(0, 1, -1)
+	}
+	return today, today
+}
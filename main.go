@@ -1,12 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"github.com/geeklubcn/feishu-bitable-db/db"
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	"github.com/wangyuheng/richman/internal/infrastructure/database"
-	"log"
+	"github.com/wangyuheng/richman/internal/infrastructure/database/sqlite"
 	"net/http"
+	"os"
 	"time"
 
 	ginzap "github.com/gin-contrib/zap"
@@ -25,24 +27,28 @@ func main() {
 
 	logrus.Debugf("load config. %+v", cfg)
 
-	bdb, err := db.NewDB(cfg.DbAppId, cfg.DbAppSecret)
-	if err != nil {
-		panic(err)
+	if err := run(cfg, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+}
 
-	larkCli := lark.NewClient(cfg.DbAppId, cfg.DbAppSecret,
-		lark.WithLogLevel(larkcore.LogLevelDebug),
-		lark.WithReqTimeout(100*time.Second),
-		lark.WithHttpClient(http.DefaultClient))
-
-	auditLogger := database.NewAuditLogService(cfg, bdb)
-
-	r, err := InitializeEngine(cfg, bdb, larkCli, auditLogger)
+// serve 启动 HTTP 服务及后台任务
+func serve(cfg *config.Config) error {
+	var s *Server
+	var err error
+	switch cfg.DBDriver {
+	case config.DriverSqlite:
+		s, err = initializeSqlite(cfg)
+	default:
+		s, err = initializeBitable(cfg)
+	}
 	if err != nil {
-		panic(err)
+		return err
 	}
-	t, _ := InitializeTask(cfg, bdb, larkCli)
-	t.Start()
+	s.Tasker.Start()
+
+	r := s.Engine
 
 	pprof.Register(r)
 	r.Use(requestid.New())
@@ -54,7 +60,58 @@ func main() {
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 
-	if err = r.Run(); err != nil {
-		log.Fatal(err)
+	return r.Run()
+}
+
+func initializeBitable(cfg *config.Config) (*Server, error) {
+	bdb, err := db.NewDB(cfg.DbAppId, cfg.DbAppSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	larkCli := newLarkClient(cfg)
+
+	auditLogger := database.NewAuditLogService(cfg, bdb)
+
+	return InitializeServer(cfg, bdb, larkCli, auditLogger)
+}
+
+func initializeSqlite(cfg *config.Config) (*Server, error) {
+	sdb, err := sqlite.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLogger := sqlite.NewAuditLogService(sdb)
+	// 本地存储不依赖飞书，仅用于飞书机器人回复及推送消息
+	larkCli := lark.NewClient(cfg.DbAppId, cfg.DbAppSecret,
+		lark.WithReqTimeout(10*time.Second),
+		lark.WithHttpClient(http.DefaultClient))
+
+	return InitializeSqliteServer(cfg, sdb, larkCli, auditLogger)
+}
+
+// initializeApp 按存储方式构建命令行使用的 use case
+func initializeApp(cfg *config.Config) (*App, error) {
+	switch cfg.DBDriver {
+	case config.DriverSqlite:
+		sdb, err := sqlite.Open(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return InitializeSqliteApp(cfg, sdb)
+	default:
+		bdb, err := db.NewDB(cfg.DbAppId, cfg.DbAppSecret)
+		if err != nil {
+			return nil, err
+		}
+		return InitializeApp(cfg, bdb, newLarkClient(cfg))
 	}
 }
+
+func newLarkClient(cfg *config.Config) *lark.Client {
+	return lark.NewClient(cfg.DbAppId, cfg.DbAppSecret,
+		lark.WithLogLevel(larkcore.LogLevelDebug),
+		lark.WithReqTimeout(100*time.Second),
+		lark.WithHttpClient(http.DefaultClient))
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"github.com/wangyuheng/richman/internal/domain"
+	"github.com/wangyuheng/richman/internal/infrastructure/feishu"
+	"github.com/wangyuheng/richman/internal/usecase"
+)
+
+// NewMessengers 汇总各渠道的主动推送，供定时任务按订阅渠道推送消息
+func NewMessengers(wechatMessenger domain.Messenger, feishuMessenger feishu.Messenger) domain.Messengers {
+	return domain.Messengers{
+		usecase.ChannelWechat: wechatMessenger,
+		usecase.ChannelFeishu: feishuMessenger,
+	}
+}
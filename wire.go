@@ -4,52 +4,107 @@
 package main
 
 import (
+	"database/sql"
+
 	"github.com/geeklubcn/feishu-bitable-db/db"
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	"github.com/wangyuheng/richman/config"
 	"github.com/wangyuheng/richman/internal/domain"
 	"github.com/wangyuheng/richman/internal/infrastructure/database"
+	"github.com/wangyuheng/richman/internal/infrastructure/database/sqlite"
+	"github.com/wangyuheng/richman/internal/infrastructure/export"
+	"github.com/wangyuheng/richman/internal/infrastructure/feishu"
 	"github.com/wangyuheng/richman/internal/infrastructure/openai"
+	"github.com/wangyuheng/richman/internal/infrastructure/rate"
+	"github.com/wangyuheng/richman/internal/infrastructure/rule"
+	"github.com/wangyuheng/richman/internal/infrastructure/statement"
+	"github.com/wangyuheng/richman/internal/infrastructure/wechat"
 	"github.com/wangyuheng/richman/internal/interfaces/http"
 	"github.com/wangyuheng/richman/internal/interfaces/http/handler"
 	"github.com/wangyuheng/richman/internal/task"
 	"github.com/wangyuheng/richman/internal/usecase"
 )
 
-func InitializeUserUseCase(db db.DB) (usecase.UserUseCase, error) {
-	wire.Build(usecase.NewUserUseCase, database.NewUserRepository)
-	return nil, nil
-}
+var BitableRepositorySet = wire.NewSet(
+	database.NewBillRepository,
+	database.NewLedgerRepository,
+	database.NewLedgerMemberRepository,
+	database.NewLedgerProvisioner,
+	database.NewUserRepository,
+	database.NewRecurringBillRepository,
+	database.NewSubscriptionRepository,
+	database.NewReminderRepository,
+)
 
-func InitializeLedgerUseCase(cfg *config.Config, db db.DB, larCli *lark.Client) (usecase.LedgerUseCase, error) {
-	wire.Build(usecase.NewLedgerUseCase, database.NewLedgerRepository)
-	return nil, nil
-}
+var SqliteRepositorySet = wire.NewSet(
+	sqlite.NewBillRepository,
+	sqlite.NewLedgerRepository,
+	sqlite.NewLedgerMemberRepository,
+	sqlite.NewLedgerProvisioner,
+	sqlite.NewUserRepository,
+	sqlite.NewRecurringBillRepository,
+	sqlite.NewSubscriptionRepository,
+	sqlite.NewReminderRepository,
+)
 
-func InitializeBillUseCase(cfg *config.Config, db db.DB, larCli *lark.Client) (usecase.BillUseCase, error) {
-	wire.Build(usecase.NewBillUseCase, database.NewBillRepository)
-	return nil, nil
-}
+var UseCaseSet = wire.NewSet(
+	usecase.NewAssistant,
+	usecase.NewBillUseCase,
+	usecase.NewUserUseCase,
+	usecase.NewLedgerUseCase,
+	usecase.NewAuthUseCase,
+	usecase.NewExportUseCase,
+	usecase.NewImportUseCase,
+	usecase.NewBudgetUseCase,
+	usecase.NewRecurringUseCase,
+	usecase.NewReportUseCase,
+	usecase.NewReminderUseCase,
+	openai.NewOpenAIService,
+	rule.NewParser,
+	rate.NewRateProvider,
+	export.NewBillExporter,
+	statement.NewParser,
+	NewMessengers,
+	wechat.NewMessenger,
+	feishu.NewMessenger,
+)
+
+var ServerSet = wire.NewSet(
+	wire.Struct(new(Server), "*"),
+	http.NewEngine,
+	handler.NewWechatHandler,
+	handler.NewFeishuHandler,
+	handler.NewAPIHandler,
+	handler.NewExportHandler,
+	handler.NewImportHandler,
+	handler.NewDevboxHandler,
+	task.NewTasks,
+	task.NewWarmTask,
+	task.NewRecurringTask,
+	task.NewReportTask,
+	task.NewReminderTask,
+)
 
-func InitializeWechatHandler(cfg *config.Config, db db.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (handler.WechatHandler, error) {
-	wire.Build(handler.NewWechatHandler, InitializeBillUseCase, InitializeUserUseCase, InitializeLedgerUseCase, openai.NewOpenAIService)
+func InitializeServer(cfg *config.Config, db db.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (*Server, error) {
+	wire.Build(ServerSet, UseCaseSet, BitableRepositorySet)
 	return nil, nil
 }
 
-func InitializeDevboxHandler(cfg *config.Config, db db.DB, larCli *lark.Client) (handler.DevboxHandler, error) {
-	wire.Build(handler.NewDevboxHandler, InitializeLedgerUseCase, InitializeUserUseCase)
+func InitializeApp(cfg *config.Config, db db.DB, larCli *lark.Client) (*App, error) {
+	wire.Build(wire.Struct(new(App), "*"), usecase.NewBillUseCase, usecase.NewLedgerUseCase, usecase.NewUserUseCase, usecase.NewAuthUseCase, usecase.NewExportUseCase, usecase.NewImportUseCase,
+		rate.NewRateProvider, export.NewBillExporter, statement.NewParser, BitableRepositorySet)
 	return nil, nil
 }
 
-func InitializeTask(cfg *config.Config, db db.DB, larCli *lark.Client) (task.Tasker, error) {
-	wire.Build(task.NewWarmTask, InitializeLedgerUseCase, database.NewLedgerRepository, database.NewUserRepository)
+func InitializeSqliteServer(cfg *config.Config, sdb *sql.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (*Server, error) {
+	wire.Build(ServerSet, UseCaseSet, SqliteRepositorySet)
 	return nil, nil
 }
 
-func InitializeEngine(cfg *config.Config, db db.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (*gin.Engine, error) {
-	wire.Build(http.NewEngine, InitializeWechatHandler, InitializeDevboxHandler)
+func InitializeSqliteApp(cfg *config.Config, sdb *sql.DB) (*App, error) {
+	wire.Build(wire.Struct(new(App), "*"), usecase.NewBillUseCase, usecase.NewLedgerUseCase, usecase.NewUserUseCase, usecase.NewAuthUseCase, usecase.NewExportUseCase, usecase.NewImportUseCase,
+		rate.NewRateProvider, export.NewBillExporter, statement.NewParser, SqliteRepositorySet)
 	return nil, nil
 }
 
@@ -7,13 +7,21 @@
 package main
 
 import (
+	"database/sql"
 	"github.com/geeklubcn/feishu-bitable-db/db"
-	"github.com/gin-gonic/gin"
+	"github.com/google/wire"
 	"github.com/larksuite/oapi-sdk-go/v3"
 	"github.com/wangyuheng/richman/config"
 	"github.com/wangyuheng/richman/internal/domain"
 	"github.com/wangyuheng/richman/internal/infrastructure/database"
+	"github.com/wangyuheng/richman/internal/infrastructure/database/sqlite"
+	"github.com/wangyuheng/richman/internal/infrastructure/export"
+	"github.com/wangyuheng/richman/internal/infrastructure/feishu"
 	"github.com/wangyuheng/richman/internal/infrastructure/openai"
+	"github.com/wangyuheng/richman/internal/infrastructure/rate"
+	"github.com/wangyuheng/richman/internal/infrastructure/rule"
+	"github.com/wangyuheng/richman/internal/infrastructure/statement"
+	"github.com/wangyuheng/richman/internal/infrastructure/wechat"
 	"github.com/wangyuheng/richman/internal/interfaces/http"
 	"github.com/wangyuheng/richman/internal/interfaces/http/handler"
 	"github.com/wangyuheng/richman/internal/task"
@@ -22,75 +30,192 @@ import (
 
 // Injectors from wire.go:
 
-func InitializeUserUseCase(db2 db.DB) (usecase.UserUseCase, error) {
-	userRepository := database.NewUserRepository(db2)
-	userUseCase := usecase.NewUserUseCase(userRepository)
-	return userUseCase, nil
-}
-
-func InitializeLedgerUseCase(cfg *config.Config, db2 db.DB, larCli *lark.Client) (usecase.LedgerUseCase, error) {
-	ledgerRepository := database.NewLedgerRepository(cfg, larCli, db2)
-	ledgerUseCase := usecase.NewLedgerUseCase(cfg, ledgerRepository, larCli)
-	return ledgerUseCase, nil
-}
-
-func InitializeBillUseCase(cfg *config.Config, db2 db.DB, larCli *lark.Client) (usecase.BillUseCase, error) {
-	billRepository := database.NewBillRepository(db2)
-	billUseCase := usecase.NewBillUseCase(billRepository)
-	return billUseCase, nil
-}
-
-func InitializeWechatHandler(cfg *config.Config, db2 db.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (handler.WechatHandler, error) {
-	billUseCase, err := InitializeBillUseCase(cfg, db2, larCli)
+func InitializeServer(cfg *config.Config, db2 db.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (*Server, error) {
+	billRepository := database.NewBillRepository(db2, larCli)
+	rateProvider, err := rate.NewRateProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	billUseCase := usecase.NewBillUseCase(billRepository, rateProvider)
+	aiService, err := openai.NewOpenAIService(cfg, auditLogger)
 	if err != nil {
 		return nil, err
 	}
-	aiService := openai.NewOpenAIService(cfg, auditLogger)
-	ledgerUseCase, err := InitializeLedgerUseCase(cfg, db2, larCli)
+	intentParser := rule.NewParser()
+	ledgerRepository := database.NewLedgerRepository(cfg, larCli, db2)
+	ledgerMemberRepository := database.NewLedgerMemberRepository(cfg, db2)
+	ledgerProvisioner := database.NewLedgerProvisioner(cfg, larCli)
+	ledgerUseCase := usecase.NewLedgerUseCase(ledgerRepository, ledgerMemberRepository, ledgerProvisioner)
+	userRepository := database.NewUserRepository(db2)
+	userUseCase := usecase.NewUserUseCase(userRepository)
+	authUseCase := usecase.NewAuthUseCase(cfg, userUseCase)
+	billExporter, err := export.NewBillExporter(cfg)
 	if err != nil {
 		return nil, err
 	}
-	userUseCase, err := InitializeUserUseCase(db2)
+	exportUseCase := usecase.NewExportUseCase(cfg, billUseCase, authUseCase, billExporter)
+	budgetUseCase := usecase.NewBudgetUseCase(cfg, billUseCase, ledgerRepository)
+	recurringBillRepository := database.NewRecurringBillRepository(cfg, larCli, db2)
+	recurringUseCase := usecase.NewRecurringUseCase(recurringBillRepository, billRepository)
+	subscriptionRepository := database.NewSubscriptionRepository(cfg, db2)
+	messenger := wechat.NewMessenger(cfg)
+	feishuMessenger := feishu.NewMessenger(larCli)
+	messengers := NewMessengers(messenger, feishuMessenger)
+	reportUseCase := usecase.NewReportUseCase(cfg, subscriptionRepository, billUseCase, budgetUseCase, ledgerUseCase, messengers)
+	reminderRepository := database.NewReminderRepository(cfg, db2)
+	reminderUseCase := usecase.NewReminderUseCase(cfg, reminderRepository, billUseCase, ledgerUseCase, messengers)
+	assistant := usecase.NewAssistant(cfg, billUseCase, aiService, intentParser, ledgerUseCase, userUseCase, authUseCase, exportUseCase, budgetUseCase, recurringUseCase, reportUseCase, reminderUseCase)
+	wechatHandler, err := handler.NewWechatHandler(cfg, assistant, messenger)
 	if err != nil {
 		return nil, err
 	}
-	wechatHandler := handler.NewWechatHandler(cfg, billUseCase, aiService, ledgerUseCase, userUseCase)
-	return wechatHandler, nil
+	feishuHandler := handler.NewFeishuHandler(cfg, assistant, feishuMessenger)
+	apiHandler := handler.NewAPIHandler(authUseCase, billUseCase, ledgerUseCase)
+	exportHandler := handler.NewExportHandler(exportUseCase, ledgerUseCase)
+	statementParser := statement.NewParser()
+	importUseCase := usecase.NewImportUseCase(billUseCase, statementParser)
+	importHandler := handler.NewImportHandler(importUseCase, ledgerUseCase)
+	devboxHandler := handler.NewDevboxHandler(cfg, userUseCase, ledgerUseCase)
+	engine := http.NewEngine(cfg, wechatHandler, feishuHandler, apiHandler, exportHandler, importHandler, devboxHandler)
+	warmTask := task.NewWarmTask(ledgerUseCase, userRepository, ledgerRepository)
+	recurringTask := task.NewRecurringTask(recurringUseCase)
+	reportTask := task.NewReportTask(reportUseCase)
+	reminderTask := task.NewReminderTask(reminderUseCase)
+	tasker := task.NewTasks(warmTask, recurringTask, reportTask, reminderTask)
+	server := &Server{
+		Engine: engine,
+		Tasker: tasker,
+	}
+	return server, nil
 }
 
-func InitializeDevboxHandler(cfg *config.Config, db2 db.DB, larCli *lark.Client) (handler.DevboxHandler, error) {
-	userUseCase, err := InitializeUserUseCase(db2)
+func InitializeApp(cfg *config.Config, db2 db.DB, larCli *lark.Client) (*App, error) {
+	billRepository := database.NewBillRepository(db2, larCli)
+	rateProvider, err := rate.NewRateProvider(cfg)
 	if err != nil {
 		return nil, err
 	}
-	ledgerUseCase, err := InitializeLedgerUseCase(cfg, db2, larCli)
+	billUseCase := usecase.NewBillUseCase(billRepository, rateProvider)
+	ledgerRepository := database.NewLedgerRepository(cfg, larCli, db2)
+	ledgerMemberRepository := database.NewLedgerMemberRepository(cfg, db2)
+	ledgerProvisioner := database.NewLedgerProvisioner(cfg, larCli)
+	ledgerUseCase := usecase.NewLedgerUseCase(ledgerRepository, ledgerMemberRepository, ledgerProvisioner)
+	userRepository := database.NewUserRepository(db2)
+	userUseCase := usecase.NewUserUseCase(userRepository)
+	authUseCase := usecase.NewAuthUseCase(cfg, userUseCase)
+	billExporter, err := export.NewBillExporter(cfg)
 	if err != nil {
 		return nil, err
 	}
-	devboxHandler := handler.NewDevboxHandler(cfg, userUseCase, ledgerUseCase)
-	return devboxHandler, nil
+	exportUseCase := usecase.NewExportUseCase(cfg, billUseCase, authUseCase, billExporter)
+	statementParser := statement.NewParser()
+	importUseCase := usecase.NewImportUseCase(billUseCase, statementParser)
+	app := &App{
+		BillUseCase:   billUseCase,
+		LedgerUseCase: ledgerUseCase,
+		UserUseCase:   userUseCase,
+		ExportUseCase: exportUseCase,
+		ImportUseCase: importUseCase,
+	}
+	return app, nil
 }
 
-func InitializeTask(cfg *config.Config, db2 db.DB, larCli *lark.Client) (task.Tasker, error) {
-	ledgerUseCase, err := InitializeLedgerUseCase(cfg, db2, larCli)
+func InitializeSqliteServer(cfg *config.Config, sdb *sql.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (*Server, error) {
+	billRepository := sqlite.NewBillRepository(sdb)
+	rateProvider, err := rate.NewRateProvider(cfg)
 	if err != nil {
 		return nil, err
 	}
-	userRepository := database.NewUserRepository(db2)
-	ledgerRepository := database.NewLedgerRepository(cfg, larCli, db2)
-	tasker := task.NewWarmTask(ledgerUseCase, userRepository, ledgerRepository)
-	return tasker, nil
+	billUseCase := usecase.NewBillUseCase(billRepository, rateProvider)
+	aiService, err := openai.NewOpenAIService(cfg, auditLogger)
+	if err != nil {
+		return nil, err
+	}
+	intentParser := rule.NewParser()
+	ledgerRepository := sqlite.NewLedgerRepository(sdb)
+	ledgerMemberRepository := sqlite.NewLedgerMemberRepository(sdb)
+	ledgerProvisioner := sqlite.NewLedgerProvisioner()
+	ledgerUseCase := usecase.NewLedgerUseCase(ledgerRepository, ledgerMemberRepository, ledgerProvisioner)
+	userRepository := sqlite.NewUserRepository(sdb)
+	userUseCase := usecase.NewUserUseCase(userRepository)
+	authUseCase := usecase.NewAuthUseCase(cfg, userUseCase)
+	billExporter, err := export.NewBillExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exportUseCase := usecase.NewExportUseCase(cfg, billUseCase, authUseCase, billExporter)
+	budgetUseCase := usecase.NewBudgetUseCase(cfg, billUseCase, ledgerRepository)
+	recurringBillRepository := sqlite.NewRecurringBillRepository(sdb)
+	recurringUseCase := usecase.NewRecurringUseCase(recurringBillRepository, billRepository)
+	subscriptionRepository := sqlite.NewSubscriptionRepository(sdb)
+	messenger := wechat.NewMessenger(cfg)
+	feishuMessenger := feishu.NewMessenger(larCli)
+	messengers := NewMessengers(messenger, feishuMessenger)
+	reportUseCase := usecase.NewReportUseCase(cfg, subscriptionRepository, billUseCase, budgetUseCase, ledgerUseCase, messengers)
+	reminderRepository := sqlite.NewReminderRepository(sdb)
+	reminderUseCase := usecase.NewReminderUseCase(cfg, reminderRepository, billUseCase, ledgerUseCase, messengers)
+	assistant := usecase.NewAssistant(cfg, billUseCase, aiService, intentParser, ledgerUseCase, userUseCase, authUseCase, exportUseCase, budgetUseCase, recurringUseCase, reportUseCase, reminderUseCase)
+	wechatHandler, err := handler.NewWechatHandler(cfg, assistant, messenger)
+	if err != nil {
+		return nil, err
+	}
+	feishuHandler := handler.NewFeishuHandler(cfg, assistant, feishuMessenger)
+	apiHandler := handler.NewAPIHandler(authUseCase, billUseCase, ledgerUseCase)
+	exportHandler := handler.NewExportHandler(exportUseCase, ledgerUseCase)
+	statementParser := statement.NewParser()
+	importUseCase := usecase.NewImportUseCase(billUseCase, statementParser)
+	importHandler := handler.NewImportHandler(importUseCase, ledgerUseCase)
+	devboxHandler := handler.NewDevboxHandler(cfg, userUseCase, ledgerUseCase)
+	engine := http.NewEngine(cfg, wechatHandler, feishuHandler, apiHandler, exportHandler, importHandler, devboxHandler)
+	warmTask := task.NewWarmTask(ledgerUseCase, userRepository, ledgerRepository)
+	recurringTask := task.NewRecurringTask(recurringUseCase)
+	reportTask := task.NewReportTask(reportUseCase)
+	reminderTask := task.NewReminderTask(reminderUseCase)
+	tasker := task.NewTasks(warmTask, recurringTask, reportTask, reminderTask)
+	server := &Server{
+		Engine: engine,
+		Tasker: tasker,
+	}
+	return server, nil
 }
 
-func InitializeEngine(cfg *config.Config, db2 db.DB, larCli *lark.Client, auditLogger domain.AuditLogService) (*gin.Engine, error) {
-	wechatHandler, err := InitializeWechatHandler(cfg, db2, larCli, auditLogger)
+func InitializeSqliteApp(cfg *config.Config, sdb *sql.DB) (*App, error) {
+	billRepository := sqlite.NewBillRepository(sdb)
+	rateProvider, err := rate.NewRateProvider(cfg)
 	if err != nil {
 		return nil, err
 	}
-	devboxHandler, err := InitializeDevboxHandler(cfg, db2, larCli)
+	billUseCase := usecase.NewBillUseCase(billRepository, rateProvider)
+	ledgerRepository := sqlite.NewLedgerRepository(sdb)
+	ledgerMemberRepository := sqlite.NewLedgerMemberRepository(sdb)
+	ledgerProvisioner := sqlite.NewLedgerProvisioner()
+	ledgerUseCase := usecase.NewLedgerUseCase(ledgerRepository, ledgerMemberRepository, ledgerProvisioner)
+	userRepository := sqlite.NewUserRepository(sdb)
+	userUseCase := usecase.NewUserUseCase(userRepository)
+	authUseCase := usecase.NewAuthUseCase(cfg, userUseCase)
+	billExporter, err := export.NewBillExporter(cfg)
 	if err != nil {
 		return nil, err
 	}
-	engine := http.NewEngine(wechatHandler, devboxHandler)
-	return engine, nil
+	exportUseCase := usecase.NewExportUseCase(cfg, billUseCase, authUseCase, billExporter)
+	statementParser := statement.NewParser()
+	importUseCase := usecase.NewImportUseCase(billUseCase, statementParser)
+	app := &App{
+		BillUseCase:   billUseCase,
+		LedgerUseCase: ledgerUseCase,
+		UserUseCase:   userUseCase,
+		ExportUseCase: exportUseCase,
+		ImportUseCase: importUseCase,
+	}
+	return app, nil
 }
+
+// wire.go:
+
+var BitableRepositorySet = wire.NewSet(database.NewBillRepository, database.NewLedgerRepository, database.NewLedgerMemberRepository, database.NewLedgerProvisioner, database.NewUserRepository, database.NewRecurringBillRepository, database.NewSubscriptionRepository, database.NewReminderRepository)
+
+var SqliteRepositorySet = wire.NewSet(sqlite.NewBillRepository, sqlite.NewLedgerRepository, sqlite.NewLedgerMemberRepository, sqlite.NewLedgerProvisioner, sqlite.NewUserRepository, sqlite.NewRecurringBillRepository, sqlite.NewSubscriptionRepository, sqlite.NewReminderRepository)
+
+var UseCaseSet = wire.NewSet(usecase.NewAssistant, usecase.NewBillUseCase, usecase.NewUserUseCase, usecase.NewLedgerUseCase, usecase.NewAuthUseCase, usecase.NewExportUseCase, usecase.NewImportUseCase, usecase.NewBudgetUseCase, usecase.NewRecurringUseCase, usecase.NewReportUseCase, usecase.NewReminderUseCase, openai.NewOpenAIService, rule.NewParser, rate.NewRateProvider, export.NewBillExporter, statement.NewParser, NewMessengers, wechat.NewMessenger, feishu.NewMessenger)
+
+var ServerSet = wire.NewSet(wire.Struct(new(Server), "*"), http.NewEngine, handler.NewWechatHandler, handler.NewFeishuHandler, handler.NewAPIHandler, handler.NewExportHandler, handler.NewImportHandler, handler.NewDevboxHandler, task.NewTasks, task.NewWarmTask, task.NewRecurringTask, task.NewReportTask, task.NewReminderTask)